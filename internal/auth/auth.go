@@ -3,8 +3,10 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
 	"strings"
 	"time"
 
@@ -15,8 +17,58 @@ import (
 )
 
 // openBrowser is the function used to open a URL in the user's browser.
-// It defaults to browser.OpenURL and can be overridden in tests.
-var openBrowser = browser.OpenURL
+// It defaults to openURL and can be overridden in tests.
+var openBrowser = openURL
+
+// openURL opens url with the command from SAWS_BROWSER or BROWSER if set,
+// falling back to the system default browser.
+func openURL(url string) error {
+	cmd := browserCommand(url)
+	if cmd == nil {
+		return browser.OpenURL(url)
+	}
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("cannot start browser %q: %w", cmd.Path, err)
+	}
+	// Don't block on browsers that stay in the foreground.
+	go cmd.Wait()
+	return nil
+}
+
+// browserCommand builds the command configured by SAWS_BROWSER (preferred) or
+// BROWSER. The URL replaces a "%s" argument if present, otherwise it is
+// appended. It returns nil when neither variable is set.
+func browserCommand(url string) *exec.Cmd {
+	spec := os.Getenv("SAWS_BROWSER")
+	if spec == "" {
+		spec = os.Getenv("BROWSER")
+	}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	args := fields[1:]
+	substituted := false
+	for i, a := range args {
+		if strings.Contains(a, "%s") {
+			args[i] = strings.ReplaceAll(a, "%s", url)
+			substituted = true
+		}
+	}
+	if !substituted {
+		args = append(args, url)
+	}
+	return exec.Command(fields[0], args...)
+}
+
+// OpenBrowser opens url in the user's browser, honoring SAWS_BROWSER and
+// BROWSER.
+func OpenBrowser(url string) error {
+	return openBrowser(url)
+}
 
 func init() {
 	// Redirect browser's output to stderr so it doesn't pollute stdout
@@ -39,20 +91,95 @@ type OIDCClient interface {
 }
 
 // TokenResult holds the access token obtained from SSO OIDC.
+// The client registration and refresh token are kept so the token can be
+// cached in the full AWS CLI schema; RefreshToken is empty unless SSO
+// issued one.
 type TokenResult struct {
 	AccessToken string
 	ExpiresAt   time.Time
+
+	ClientID              string
+	ClientSecret          string
+	RegistrationExpiresAt time.Time // zero if unknown
+	RefreshToken          string
 }
 
 // DeviceAuthInfo holds information displayed to the user during authorization.
+// VerificationURI is the URL to show and open: CompleteVerificationURI, or
+// PlainVerificationURI (where the code is typed in) with WithManualCode.
 type DeviceAuthInfo struct {
+	StartURL        string
 	VerificationURI string
 	UserCode        string
+
+	PlainVerificationURI    string
+	CompleteVerificationURI string
 }
 
 // StatusCallback is called during the auth flow to report status to the UI.
 type StatusCallback func(status string)
 
+// Option customizes the behavior of Authenticate.
+type Option func(*options)
+
+type options struct {
+	noBrowser  bool
+	manualCode bool
+	confirm    func() (bool, error)
+	timeout    time.Duration
+	waiter     Waiter
+}
+
+// Waiter runs poll, which waits for the user to approve the device
+// authorization, and returns its error. It lets a UI stay responsive while
+// polling, e.g. to reopen verificationURI on request.
+type Waiter func(ctx context.Context, verificationURI string, poll func(ctx context.Context) error) error
+
+// defaultTimeout bounds how long Authenticate waits for browser approval.
+const defaultTimeout = 5 * time.Minute
+
+// WithoutBrowser stops Authenticate from opening the verification URL in a
+// browser. Use it when another system or person will open the URL.
+func WithoutBrowser() Option {
+	return func(o *options) {
+		o.noBrowser = true
+	}
+}
+
+// WithBrowserConfirm makes Authenticate call confirm before opening the
+// browser and skip it if confirm returns false. A confirm error counts as
+// yes.
+func WithBrowserConfirm(confirm func() (bool, error)) Option {
+	return func(o *options) {
+		o.confirm = confirm
+	}
+}
+
+// WithManualCode shows and opens the bare verification URL, so the user
+// types the code in, for policies that discourage links with embedded codes.
+func WithManualCode() Option {
+	return func(o *options) {
+		o.manualCode = true
+	}
+}
+
+// WithTimeout sets how long Authenticate waits for the user to approve the
+// device authorization. Non-positive values keep the default of 5 minutes.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		if d > 0 {
+			o.timeout = d
+		}
+	}
+}
+
+// WithWaiter makes Authenticate poll for the token through w.
+func WithWaiter(w Waiter) Option {
+	return func(o *options) {
+		o.waiter = w
+	}
+}
+
 // NewOIDCClient creates a real SSO OIDC client for the given region.
 func NewOIDCClient(ctx context.Context, region string) (OIDCClient, error) {
 	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(region))
@@ -62,10 +189,31 @@ func NewOIDCClient(ctx context.Context, region string) (OIDCClient, error) {
 	return NewOIDCClientFromConfig(cfg), nil
 }
 
+// Endpoint overrides for the SSO OIDC API, for regulated environments.
+var (
+	// Endpoint replaces the resolved OIDC endpoint (e.g. a VPC endpoint URL).
+	Endpoint string
+	// UseFIPS selects the FIPS endpoint when Endpoint is not set.
+	UseFIPS bool
+	// HTTPClient, if set, replaces the SDK's default HTTP client.
+	HTTPClient aws.HTTPClient
+)
+
 // NewOIDCClientFromConfig creates a real SSO OIDC client from an existing AWS config.
 // Use this to share a single LoadDefaultConfig call across multiple clients.
+// Endpoint, UseFIPS, and HTTPClient are applied to the client.
 func NewOIDCClientFromConfig(cfg aws.Config) OIDCClient {
-	return ssooidc.NewFromConfig(cfg)
+	return ssooidc.NewFromConfig(cfg, func(o *ssooidc.Options) {
+		if Endpoint != "" {
+			o.BaseEndpoint = aws.String(Endpoint)
+		}
+		if UseFIPS {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		}
+		if HTTPClient != nil {
+			o.HTTPClient = HTTPClient
+		}
+	})
 }
 
 // Authenticate performs the full SSO OIDC device authorization flow.
@@ -80,7 +228,13 @@ func Authenticate(
 	startURL string,
 	onDeviceAuth func(DeviceAuthInfo),
 	onStatus StatusCallback,
+	opts ...Option,
 ) (*TokenResult, error) {
+	o := options{timeout: defaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// Step 1: Register client
 	onStatus("Registering client...")
 	registerOut, err := client.RegisterClient(ctx, &ssooidc.RegisterClientInput{
@@ -103,16 +257,30 @@ func Authenticate(
 	}
 
 	// Step 3: Notify caller and open browser
-	verificationURI := aws.ToString(deviceOut.VerificationUriComplete)
+	plainURI := aws.ToString(deviceOut.VerificationUri)
+	completeURI := aws.ToString(deviceOut.VerificationUriComplete)
+	verificationURI := completeURI
+	if o.manualCode && plainURI != "" {
+		verificationURI = plainURI
+	}
 	userCode := aws.ToString(deviceOut.UserCode)
 
 	onDeviceAuth(DeviceAuthInfo{
-		VerificationURI: verificationURI,
-		UserCode:        userCode,
+		StartURL:                startURL,
+		VerificationURI:         verificationURI,
+		UserCode:                userCode,
+		PlainVerificationURI:    plainURI,
+		CompleteVerificationURI: completeURI,
 	})
 
 	// Attempt to open browser (non-fatal if it fails)
-	_ = openBrowser(verificationURI)
+	if !o.noBrowser {
+		if shouldOpenBrowser(o.confirm) {
+			_ = openBrowser(verificationURI)
+		} else {
+			onStatus("Browser not opened; open the URL above to approve this login")
+		}
+	}
 
 	// Step 4: Poll for token
 	interval := deviceOut.Interval
@@ -121,14 +289,31 @@ func Authenticate(
 	}
 
 	onStatus("Waiting for browser authorization...")
-	token, err := pollForToken(ctx, client, registerOut, deviceOut, interval)
+	if o.waiter == nil {
+		return pollForToken(ctx, client, registerOut, deviceOut, interval, o.timeout)
+	}
+	var token *TokenResult
+	err = o.waiter(ctx, verificationURI, func(ctx context.Context) error {
+		var err error
+		token, err = pollForToken(ctx, client, registerOut, deviceOut, interval, o.timeout)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-
 	return token, nil
 }
 
+// shouldOpenBrowser reports whether to open the browser: always without a
+// confirm function, otherwise unless it answers no.
+func shouldOpenBrowser(confirm func() (bool, error)) bool {
+	if confirm == nil {
+		return true
+	}
+	ok, err := confirm()
+	return err != nil || ok
+}
+
 // pollForToken polls the CreateToken endpoint until authorization is complete.
 // It attempts one immediate poll before falling into the interval-based loop,
 // so users who approve quickly in the browser don't wait an extra interval.
@@ -138,13 +323,13 @@ func pollForToken(
 	register *ssooidc.RegisterClientOutput,
 	device *ssooidc.StartDeviceAuthorizationOutput,
 	intervalSecs int32,
+	timeout time.Duration,
 ) (*TokenResult, error) {
 	interval := time.Duration(intervalSecs) * time.Second
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	// Timeout after 5 minutes
-	timeout := time.After(5 * time.Minute)
+	deadline := time.After(timeout)
 
 	// Try once immediately, then fall into ticker loop
 	first := true
@@ -153,8 +338,8 @@ func pollForToken(
 			select {
 			case <-ctx.Done():
 				return nil, ctx.Err()
-			case <-timeout:
-				return nil, fmt.Errorf("authorization timed out after 5 minutes")
+			case <-deadline:
+				return nil, fmt.Errorf("authorization timed out after %s", timeout)
 			case <-ticker.C:
 			}
 		}
@@ -180,10 +365,17 @@ func pollForToken(
 		}
 
 		expiresAt := time.Now().Add(time.Duration(tokenOut.ExpiresIn) * time.Second)
-		return &TokenResult{
-			AccessToken: aws.ToString(tokenOut.AccessToken),
-			ExpiresAt:   expiresAt,
-		}, nil
+		result := &TokenResult{
+			AccessToken:  aws.ToString(tokenOut.AccessToken),
+			ExpiresAt:    expiresAt,
+			ClientID:     aws.ToString(register.ClientId),
+			ClientSecret: aws.ToString(register.ClientSecret),
+			RefreshToken: aws.ToString(tokenOut.RefreshToken),
+		}
+		if register.ClientSecretExpiresAt > 0 {
+			result.RegistrationExpiresAt = time.Unix(register.ClientSecretExpiresAt, 0)
+		}
+		return result, nil
 	}
 }
 
@@ -194,3 +386,18 @@ func isAuthPending(err error) bool {
 func isSlowDown(err error) bool {
 	return strings.Contains(err.Error(), "SlowDownException")
 }
+
+// IsEndpointError reports whether err means the SDK could not resolve an SSO
+// OIDC endpoint for the region, which during setup usually means the wrong
+// SSO region was chosen. DNS and dial failures are network problems, not a
+// bad region; see IsProxyRelated.
+func IsEndpointError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var notFound *aws.EndpointNotFoundError
+	if errors.As(err, &notFound) {
+		return true
+	}
+	return strings.Contains(err.Error(), "failed to resolve service endpoint")
+}
@@ -2,7 +2,12 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -80,6 +85,9 @@ func TestAuthenticate_Success(t *testing.T) {
 	if token.AccessToken != "test-access-token" {
 		t.Errorf("AccessToken = %q, want %q", token.AccessToken, "test-access-token")
 	}
+	if token.ClientID != "test-client-id" || token.ClientSecret != "test-client-secret" {
+		t.Errorf("client registration = %q/%q, want it kept for the SSO cache", token.ClientID, token.ClientSecret)
+	}
 
 	if gotDeviceAuth.UserCode != "TEST-CODE" {
 		t.Errorf("UserCode = %q, want %q", gotDeviceAuth.UserCode, "TEST-CODE")
@@ -206,6 +214,31 @@ func TestIsAuthPending(t *testing.T) {
 	}
 }
 
+func TestIsEndpointError(t *testing.T) {
+	dnsErr := &net.DNSError{Err: "no such host", Name: "oidc.eu-foo-1.amazonaws.com", IsNotFound: true}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dns failure", fmt.Errorf("failed to register client: %w", dnsErr), false},
+		{"dial failure", fmt.Errorf("failed to register client: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}), false},
+		{"endpoint not found", fmt.Errorf("failed to register client: %w", &aws.EndpointNotFoundError{Err: errors.New("unknown region")}), true},
+		{"endpoint resolution", errors.New("operation error SSO OIDC: RegisterClient, failed to resolve service endpoint, endpoint rule error"), true},
+		{"access denied", errors.New("failed to start device authorization: AccessDeniedException"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsEndpointError(tt.err); got != tt.want {
+			t.Errorf("%s: IsEndpointError() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	// Network failures get the proxy hint instead of a region prompt.
+	if !IsProxyRelated(fmt.Errorf("failed to register client: %w", dnsErr)) {
+		t.Error("IsProxyRelated() = false for a DNS failure, want true")
+	}
+}
+
 func TestIsSlowDown(t *testing.T) {
 	if !isSlowDown(fmt.Errorf("SlowDownException: too many requests")) {
 		t.Error("expected true for SlowDownException")
@@ -214,3 +247,280 @@ func TestIsSlowDown(t *testing.T) {
 		t.Error("expected false for AuthorizationPendingException")
 	}
 }
+
+func TestAuthenticate_WithoutBrowser(t *testing.T) {
+	orig := openBrowser
+	defer func() { openBrowser = orig }()
+
+	opened := false
+	openBrowser = func(url string) error {
+		opened = true
+		return nil
+	}
+
+	_, err := Authenticate(context.Background(), &mockOIDCClient{}, "https://test.awsapps.com/start",
+		func(DeviceAuthInfo) {}, func(string) {}, WithoutBrowser())
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if opened {
+		t.Error("browser was opened despite WithoutBrowser()")
+	}
+
+	_, err = Authenticate(context.Background(), &mockOIDCClient{}, "https://test.awsapps.com/start",
+		func(DeviceAuthInfo) {}, func(string) {})
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if !opened {
+		t.Error("browser was not opened by default")
+	}
+}
+
+func TestAuthenticate_BrowserConfirm(t *testing.T) {
+	orig := openBrowser
+	defer func() { openBrowser = orig }()
+
+	tests := []struct {
+		name       string
+		answer     bool
+		err        error
+		wantOpened bool
+	}{
+		{"accepted", true, nil, true},
+		{"declined", false, nil, false},
+		{"prompt failed defaults to yes", false, errors.New("no tty"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opened := false
+			openBrowser = func(url string) error {
+				opened = true
+				return nil
+			}
+
+			var statuses []string
+			_, err := Authenticate(context.Background(), &mockOIDCClient{}, "https://test.awsapps.com/start",
+				func(DeviceAuthInfo) {}, func(s string) { statuses = append(statuses, s) },
+				WithBrowserConfirm(func() (bool, error) { return tt.answer, tt.err }))
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if opened != tt.wantOpened {
+				t.Errorf("browser opened = %v, want %v", opened, tt.wantOpened)
+			}
+			manual := strings.Contains(strings.Join(statuses, "\n"), "open the URL above")
+			if manual == tt.wantOpened {
+				t.Errorf("statuses = %q, want the manual URL hint only when declined", statuses)
+			}
+		})
+	}
+}
+
+func TestAuthenticate_WithWaiter(t *testing.T) {
+	var gotURI string
+	token, err := Authenticate(context.Background(), &mockOIDCClient{}, "https://test.awsapps.com/start",
+		func(DeviceAuthInfo) {}, func(string) {},
+		WithWaiter(func(ctx context.Context, verificationURI string, poll func(context.Context) error) error {
+			gotURI = verificationURI
+			return poll(ctx)
+		}))
+	if err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+	if token == nil || token.AccessToken == "" {
+		t.Errorf("token = %+v, want the polled token", token)
+	}
+	if gotURI == "" {
+		t.Error("waiter was not called with the verification URI")
+	}
+}
+
+func TestAuthenticate_ManualCode(t *testing.T) {
+	orig := openBrowser
+	defer func() { openBrowser = orig }()
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"embedded code by default", nil, "https://device.sso.us-east-1.amazonaws.com/?user_code=TEST-CODE"},
+		{"bare URL with manual code", []Option{WithManualCode()}, "https://device.sso.us-east-1.amazonaws.com/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opened string
+			openBrowser = func(url string) error {
+				opened = url
+				return nil
+			}
+
+			var info DeviceAuthInfo
+			_, err := Authenticate(context.Background(), &mockOIDCClient{}, "https://test.awsapps.com/start",
+				func(i DeviceAuthInfo) { info = i }, func(string) {}, tt.opts...)
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if info.VerificationURI != tt.want {
+				t.Errorf("VerificationURI = %q, want %q", info.VerificationURI, tt.want)
+			}
+			if opened != tt.want {
+				t.Errorf("opened %q, want %q", opened, tt.want)
+			}
+			if info.PlainVerificationURI == "" || info.CompleteVerificationURI == "" {
+				t.Errorf("DeviceAuthInfo = %+v, want both URIs", info)
+			}
+		})
+	}
+}
+
+func TestBrowserCommand(t *testing.T) {
+	const url = "https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH"
+
+	tests := []struct {
+		name        string
+		sawsBrowser string
+		browser     string
+		wantArgs    []string
+	}{
+		{"unset", "", "", nil},
+		{"BROWSER appends url", "", "firefox", []string{"firefox", url}},
+		{"SAWS_BROWSER wins", "google-chrome --profile-directory=Work", "firefox", []string{"google-chrome", "--profile-directory=Work", url}},
+		{"placeholder substituted", "open -a Safari %s --new", "", []string{"open", "-a", "Safari", url, "--new"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SAWS_BROWSER", tt.sawsBrowser)
+			t.Setenv("BROWSER", tt.browser)
+
+			cmd := browserCommand(url)
+			if tt.wantArgs == nil {
+				if cmd != nil {
+					t.Fatalf("browserCommand() = %v, want nil", cmd.Args)
+				}
+				return
+			}
+			if cmd == nil {
+				t.Fatal("browserCommand() = nil, want a command")
+			}
+			if strings.Join(cmd.Args, " ") != strings.Join(tt.wantArgs, " ") {
+				t.Errorf("browserCommand() args = %q, want %q", cmd.Args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestOpenURLRunsConfiguredBrowser(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "opened")
+	script := filepath.Join(dir, "fake-browser")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$1\" > "+out+"\n"), 0755); err != nil {
+		t.Fatalf("cannot write fake browser: %v", err)
+	}
+	t.Setenv("SAWS_BROWSER", script)
+
+	const url = "https://device.sso.us-east-1.amazonaws.com/"
+	if err := openURL(url); err != nil {
+		t.Fatalf("openURL() error = %v", err)
+	}
+
+	// The command runs asynchronously; wait briefly for it to finish.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(out)
+		if err == nil && strings.TrimSpace(string(data)) == url {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("fake browser was not invoked with %q (got %q, err %v)", url, data, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIdPHint(t *testing.T) {
+	tests := []struct {
+		idp      string
+		wantSubs string
+	}{
+		{"okta", "Okta Verify"},
+		{"OKTA", "Okta Verify"},
+		{"azure", "Microsoft Authenticator"},
+		{"entra", "Microsoft Authenticator"},
+		{"jumpcloud", "JumpCloud Protect"},
+		{"unknown", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.idp, func(t *testing.T) {
+			got := IdPHint(tt.idp)
+			if tt.wantSubs == "" {
+				if got != "" {
+					t.Errorf("IdPHint(%q) = %q, want empty", tt.idp, got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.wantSubs) {
+				t.Errorf("IdPHint(%q) = %q, want it to mention %q", tt.idp, got, tt.wantSubs)
+			}
+		})
+	}
+}
+
+func TestDetectIdP(t *testing.T) {
+	tests := []struct {
+		name string
+		urls []string
+		want string
+	}{
+		{"okta alias", []string{"https://acme-okta.awsapps.com/start"}, "okta"},
+		{"entra alias", []string{"https://acme-entra.awsapps.com/start"}, "azure"},
+		{"generic start url", []string{"https://d-1234567890.awsapps.com/start", "https://device.sso.us-east-1.amazonaws.com/"}, ""},
+		{"unparseable", []string{"://bad"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectIdP(tt.urls...); got != tt.want {
+				t.Errorf("DetectIdP(%v) = %q, want %q", tt.urls, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerificationRegion(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH", "us-east-1"},
+		{"https://device.sso.eu-central-1.amazonaws.com/", "eu-central-1"},
+		{"https://device.sso-fips.us-gov-west-1.amazonaws.com/", "us-gov-west-1"},
+		{"https://device.sso.cn-north-1.amazonaws.com.cn/", "cn-north-1"},
+		{"https://d-1234567890.awsapps.com/start/#/device", ""},
+		{"https://oidc.us-east-1.amazonaws.com", ""},
+		{"://bad", ""},
+	}
+
+	for _, tt := range tests {
+		if got := VerificationRegion(tt.uri); got != tt.want {
+			t.Errorf("VerificationRegion(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestRegionMismatch(t *testing.T) {
+	uri := "https://device.sso.eu-west-1.amazonaws.com/"
+	if got := RegionMismatch(uri, "us-east-1"); got != "eu-west-1" {
+		t.Errorf("RegionMismatch(%q, us-east-1) = %q, want eu-west-1", uri, got)
+	}
+	if got := RegionMismatch(uri, "eu-west-1"); got != "" {
+		t.Errorf("RegionMismatch(%q, eu-west-1) = %q, want no mismatch", uri, got)
+	}
+	if got := RegionMismatch("https://d-1234567890.awsapps.com/start/#/device", "us-east-1"); got != "" {
+		t.Errorf("RegionMismatch() with no derivable region = %q, want no mismatch", got)
+	}
+}
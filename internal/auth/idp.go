@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// idpHints maps identity providers to the approval guidance shown in the
+// device authorization box.
+var idpHints = map[string]string{
+	"okta":      "Your sign-in goes through Okta: approve the push in Okta Verify if prompted.",
+	"azure":     "Your sign-in goes through Microsoft Entra ID: approve the request in Microsoft Authenticator if prompted.",
+	"jumpcloud": "Your sign-in goes through JumpCloud: approve the push in JumpCloud Protect if prompted.",
+	"google":    "Your sign-in goes through Google Workspace: confirm the prompt on your phone if asked.",
+	"onelogin":  "Your sign-in goes through OneLogin: approve the push in OneLogin Protect if prompted.",
+}
+
+// idpAliases maps alternative provider names to keys of idpHints.
+var idpAliases = map[string]string{
+	"entra":     "azure",
+	"azuread":   "azure",
+	"aad":       "azure",
+	"microsoft": "azure",
+	"gsuite":    "google",
+}
+
+// normalizeIdP resolves aliases and case, returning "" for unknown providers.
+func normalizeIdP(idp string) string {
+	idp = strings.ToLower(strings.TrimSpace(idp))
+	if canonical, ok := idpAliases[idp]; ok {
+		idp = canonical
+	}
+	if _, ok := idpHints[idp]; !ok {
+		return ""
+	}
+	return idp
+}
+
+// KnownIdPs returns the identity provider names accepted by IdPHint.
+func KnownIdPs() []string {
+	names := make([]string, 0, len(idpHints))
+	for name := range idpHints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// IdPHint returns approval guidance for the named identity provider, or ""
+// if it is not known.
+func IdPHint(idp string) string {
+	return idpHints[normalizeIdP(idp)]
+}
+
+// DetectIdP guesses the identity provider from the hosts of the given URLs,
+// e.g. a start URL alias like acme-okta.awsapps.com. It returns "" when no
+// host mentions a known provider.
+func DetectIdP(urls ...string) string {
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		host := strings.ToLower(u.Hostname())
+		for _, name := range KnownIdPs() {
+			if strings.Contains(host, name) {
+				return name
+			}
+		}
+		// Short aliases like "aad" match too many unrelated hosts.
+		if strings.Contains(host, "entra") {
+			return "azure"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OIDCEndpoint returns the SSO OIDC endpoint URL used for region, honoring
+// Endpoint and UseFIPS.
+func OIDCEndpoint(region string) string {
+	if Endpoint != "" {
+		return Endpoint
+	}
+	if UseFIPS {
+		return fmt.Sprintf("https://oidc-fips.%s.amazonaws.com", region)
+	}
+	return fmt.Sprintf("https://oidc.%s.amazonaws.com", region)
+}
+
+// CheckReachable sends a HEAD request to target and reports whether a
+// connection could be made. Any HTTP response counts as reachable, whatever
+// its status; only network failures are errors, described in terms of the
+// usual culprit (DNS, proxy, VPN, firewall).
+func CheckReachable(ctx context.Context, client *http.Client, target string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", target, err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return describeNetworkError(req.URL, err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// describeNetworkError turns a failed request into an actionable message.
+func describeNetworkError(u *url.URL, err error) error {
+	host := u.Hostname()
+
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("cannot resolve %s (check DNS, VPN, or the region): %w", host, err)
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority):
+		return fmt.Errorf("TLS certificate for %s is not trusted (a proxy may be intercepting HTTPS): %w", host, err)
+	case isTimeout(err):
+		return fmt.Errorf("timed out connecting to %s (check firewall or proxy settings): %w", host, err)
+	case errors.As(err, &opErr) && opErr.Op == "dial":
+		return fmt.Errorf("cannot connect to %s (check firewall or proxy settings): %w", host, err)
+	case strings.Contains(err.Error(), "proxyconnect"):
+		return fmt.Errorf("proxy refused the connection to %s (check HTTPS_PROXY): %w", host, err)
+	default:
+		return fmt.Errorf("cannot reach %s: %w", host, err)
+	}
+}
+
+// IsProxyRelated reports whether err is a network failure that a missing or
+// misconfigured corporate proxy would explain: an unresolvable host, a
+// timeout, a blocked connection, an intercepted TLS certificate, or a proxy
+// error.
+func IsProxyRelated(err error) bool {
+	if err == nil {
+		return false
+	}
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var opErr *net.OpError
+	return errors.As(err, &dnsErr) ||
+		errors.As(err, &certErr) || errors.As(err, &unknownAuthority) ||
+		isTimeout(err) ||
+		(errors.As(err, &opErr) && opErr.Op == "dial") ||
+		strings.Contains(err.Error(), "proxyconnect")
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc stubs an http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestIsProxyRelated(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dial refused", fmt.Errorf("send request: %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}), true},
+		{"proxy error", errors.New("proxyconnect tcp: dial tcp 10.0.0.1:3128: connection refused"), true},
+		{"dns failure", &net.DNSError{Err: "no such host", Name: "oidc.us-east-1.amazonaws.com"}, true},
+		{"access denied", errors.New("AccessDeniedException: not allowed"), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsProxyRelated(tt.err); got != tt.want {
+			t.Errorf("%s: IsProxyRelated() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckReachable(t *testing.T) {
+	tests := []struct {
+		name    string
+		rt      roundTripFunc
+		wantErr string
+	}{
+		{
+			name: "reachable with any status",
+			rt: func(r *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader(""))}, nil
+			},
+		},
+		{
+			name: "dns failure",
+			rt: func(r *http.Request) (*http.Response, error) {
+				return nil, &net.DNSError{Err: "no such host", Name: r.URL.Hostname(), IsNotFound: true}
+			},
+			wantErr: "cannot resolve oidc.us-east-1.amazonaws.com",
+		},
+		{
+			name: "connection refused",
+			rt: func(r *http.Request) (*http.Response, error) {
+				return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+			},
+			wantErr: "cannot connect to oidc.us-east-1.amazonaws.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &http.Client{Transport: tt.rt}
+			err := CheckReachable(context.Background(), client, OIDCEndpoint("us-east-1"))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("CheckReachable() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("CheckReachable() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOIDCEndpoint(t *testing.T) {
+	defer func() { Endpoint, UseFIPS = "", false }()
+
+	if got := OIDCEndpoint("eu-west-1"); got != "https://oidc.eu-west-1.amazonaws.com" {
+		t.Errorf("OIDCEndpoint() = %q", got)
+	}
+	UseFIPS = true
+	if got := OIDCEndpoint("us-east-1"); got != "https://oidc-fips.us-east-1.amazonaws.com" {
+		t.Errorf("OIDCEndpoint() with FIPS = %q", got)
+	}
+	Endpoint = "https://oidc.example.test"
+	if got := OIDCEndpoint("us-east-1"); got != Endpoint {
+		t.Errorf("OIDCEndpoint() with override = %q, want %q", got, Endpoint)
+	}
+}
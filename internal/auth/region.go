@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ssoHostRegion matches SSO hosts that embed their region, like
+// device.sso.us-east-1.amazonaws.com, capturing the region.
+var ssoHostRegion = regexp.MustCompile(`(?:^|\.)sso(?:-fips)?\.([a-z]{2}(?:-[a-z]+)+-\d+)\.amazonaws\.com(?:\.cn)?$`)
+
+// VerificationRegion returns the SSO region named in the host of a
+// verification URI, or "" when the host doesn't reveal one (e.g. an
+// awsapps.com URL).
+func VerificationRegion(verificationURI string) string {
+	u, err := url.Parse(verificationURI)
+	if err != nil {
+		return ""
+	}
+	m := ssoHostRegion.FindStringSubmatch(strings.ToLower(u.Hostname()))
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// RegionMismatch returns the region named by verificationURI when it differs
+// from region, and "" when they agree or the URI names no region.
+func RegionMismatch(verificationURI, region string) string {
+	derived := VerificationRegion(verificationURI)
+	if derived == "" || strings.EqualFold(derived, region) {
+		return ""
+	}
+	return derived
+}
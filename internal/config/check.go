@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/ini.v1"
+)
+
+// DriftReport describes where ~/.aws/credentials and the saws profiles in
+// ~/.aws/config disagree.
+type DriftReport struct {
+	// Orphaned are saws-written credentials sections without a matching
+	// profile. Standalone sections (--creds-profile targets and
+	// credentials-only logins) are never reported, nor is the default
+	// section, the usual --creds-profile target before standalone sections
+	// were marked.
+	Orphaned []string
+	// Missing are profiles with no credentials section.
+	Missing []string
+	// Expired are profiles whose recorded credentials have expired.
+	Expired []string
+}
+
+// Clean reports whether the files agree.
+func (r *DriftReport) Clean() bool {
+	return len(r.Orphaned) == 0 && len(r.Missing) == 0 && len(r.Expired) == 0
+}
+
+// CheckDrift cross-references the saws profiles with the credentials file.
+// Credentials without a recorded expiry are assumed to be current. It only
+// reads: missing files count as empty and nothing is created.
+func CheckDrift(now time.Time) (*DriftReport, error) {
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	path, err := CredentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	creds, err := loadINI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DriftReport{}
+	known := make(map[string]bool, len(profiles))
+	for _, p := range profiles {
+		known[p.Name] = true
+		sec, err := creds.GetSection(p.Name)
+		if err != nil {
+			report.Missing = append(report.Missing, p.Name)
+			continue
+		}
+		if !sec.HasKey(credentialsExpiresKey) {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, sec.Key(credentialsExpiresKey).String())
+		if err == nil && !expires.After(now) {
+			report.Expired = append(report.Expired, p.Name)
+		}
+	}
+
+	for _, sec := range creds.Sections() {
+		name := sec.Name()
+		if name == ini.DefaultSection || name == "default" || known[name] || !strings.Contains(sec.Comment, sawsMarker) || strings.Contains(sec.Comment, standaloneMarker) {
+			continue
+		}
+		report.Orphaned = append(report.Orphaned, name)
+	}
+
+	sort.Strings(report.Orphaned)
+	sort.Strings(report.Missing)
+	sort.Strings(report.Expired)
+	return report, nil
+}
+
+// PruneCredentials removes the named sections from the credentials file.
+func PruneCredentials(sections []string) error {
+	if len(sections) == 0 {
+		return nil
+	}
+	path, err := CredentialsPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	cfg, err := loadOrCreateINI(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range sections {
+		cfg.DeleteSection(name)
+	}
+	return saveINI(cfg, path, credentialsPerm)
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lvstb/saws/internal/profile"
+)
+
+func TestCheckDrift(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := SaveProfiles([]profile.SSOProfile{
+		{Name: "fresh", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "stale", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1", AccountID: "222222222222", RoleName: "Admin"},
+		{Name: "never", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1", AccountID: "333333333333", RoleName: "Admin"},
+	}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+	writes := []struct {
+		section string
+		expires time.Time
+	}{
+		{"fresh", now.Add(time.Hour)},
+		{"stale", now.Add(-time.Hour)},
+		{"deleted", now.Add(time.Hour)},
+		{"default", now.Add(-time.Hour)},
+	}
+	for _, w := range writes {
+		if err := WriteCredentials(w.section, "ASIA", "secret", "token", w.expires); err != nil {
+			t.Fatalf("WriteCredentials(%s) error = %v", w.section, err)
+		}
+	}
+	path, _ := CredentialsPath()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\n[static]\naws_access_key_id = AKIA\naws_secret_access_key = secret\n")
+	f.Close()
+
+	report, err := CheckDrift(now)
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	want := &DriftReport{
+		Orphaned: []string{"deleted"},
+		Missing:  []string{"never"},
+		Expired:  []string{"stale"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("CheckDrift() = %+v, want %+v", report, want)
+	}
+
+	if err := PruneCredentials(report.Orphaned); err != nil {
+		t.Fatalf("PruneCredentials() error = %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "[deleted]") {
+		t.Error("orphaned section was not pruned")
+	}
+	for _, keep := range []string{"[fresh]", "[stale]", "[default]", "[static]"} {
+		if !strings.Contains(string(data), keep) {
+			t.Errorf("pruning removed %s", keep)
+		}
+	}
+
+	report, err = CheckDrift(now)
+	if err != nil {
+		t.Fatalf("CheckDrift() after prune error = %v", err)
+	}
+	if len(report.Orphaned) != 0 {
+		t.Errorf("orphans after prune = %v, want none", report.Orphaned)
+	}
+}
+
+func TestCheckDriftClean(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	report, err := CheckDrift(time.Now())
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	if !report.Clean() {
+		t.Errorf("CheckDrift() with no files = %+v, want clean", report)
+	}
+}
+
+func TestCheckDriftCreatesNothing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".aws")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	report, err := CheckDrift(time.Now())
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	if !report.Clean() {
+		t.Errorf("CheckDrift() with no files = %+v, want clean", report)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("CheckDrift() created %s (stat error = %v)", dir, err)
+	}
+}
+
+func TestCheckDriftStandalone(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveProfiles([]profile.SSOProfile{
+		{Name: "dev", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin"},
+	}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+	// A --creds-profile target and a credentials-only login, neither named
+	// after a saved profile.
+	for _, section := range []string{"work", "222222222222-ReadOnly"} {
+		if err := WriteStandaloneCredentials(section, "ASIA", "secret", "token", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("WriteStandaloneCredentials(%s) error = %v", section, err)
+		}
+	}
+
+	report, err := CheckDrift(time.Now())
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	if len(report.Orphaned) != 0 {
+		t.Errorf("CheckDrift() orphans = %v, want standalone sections kept", report.Orphaned)
+	}
+
+	// Writing the section for a profile makes it an ordinary one again.
+	if err := WriteCredentials("work", "ASIA", "secret", "token", time.Time{}); err != nil {
+		t.Fatalf("WriteCredentials() error = %v", err)
+	}
+	report, err = CheckDrift(time.Now())
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	if !reflect.DeepEqual(report.Orphaned, []string{"work"}) {
+		t.Errorf("CheckDrift() orphans = %v, want [work]", report.Orphaned)
+	}
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lvstb/saws/internal/home"
+	"github.com/lvstb/saws/internal/profile"
+)
+
+// CLICacheEntry is a role session in the AWS CLI's credential cache format.
+// Stored at ~/.aws/cli/cache/{SHA1(key)}.json, where the key is the JSON of
+// the profile's account ID, role name, and sso-session name or start URL.
+type CLICacheEntry struct {
+	ProviderType string        `json:"ProviderType"`
+	Credentials  CLICacheCreds `json:"Credentials"`
+}
+
+// CLICacheCreds holds the credentials of a CLICacheEntry. Expiration uses
+// the CLI's UTC "2006-01-02T15:04:05Z" layout.
+type CLICacheCreds struct {
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+// cliCacheExpirationLayout is how the AWS CLI writes cached expirations.
+const cliCacheExpirationLayout = "2006-01-02T15:04:05Z"
+
+// cliCacheDir returns the path to the AWS CLI credential cache directory.
+func cliCacheDir() (string, error) {
+	homeDir, err := home.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".aws", "cli", "cache"), nil
+}
+
+// CLICacheFilepath returns the cache file path for p's role session. The
+// filename is the SHA1 hex hash of the sorted, compact JSON of its account
+// ID, role name, and either its sso-session name or, for profiles without
+// one, its start URL, matching the AWS CLI's SSO provider.
+func CLICacheFilepath(p *profile.SSOProfile) (string, error) {
+	dir, err := cliCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	// Maps marshal with sorted keys; HTML escaping is off to match Python's
+	// json.dumps.
+	var key bytes.Buffer
+	enc := json.NewEncoder(&key)
+	enc.SetEscapeHTML(false)
+	args := map[string]string{
+		"accountId": p.AccountID,
+		"roleName":  p.RoleName,
+	}
+	if p.SSOSession != "" {
+		args["sessionName"] = p.SSOSession
+	} else {
+		args["startUrl"] = p.StartURL
+	}
+	if err := enc.Encode(args); err != nil {
+		return "", fmt.Errorf("cannot build AWS CLI cache key: %w", err)
+	}
+
+	h := sha1.Sum(bytes.TrimSpace(key.Bytes()))
+	return filepath.Join(dir, hex.EncodeToString(h[:])+".json"), nil
+}
+
+// WriteCLICache writes p's role credentials to the AWS CLI credential cache,
+// for tools that read sessions from ~/.aws/cli/cache.
+func WriteCLICache(p *profile.SSOProfile, accessKeyID, secretAccessKey, sessionToken string, expiration time.Time) error {
+	path, err := CLICacheFilepath(p)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("cannot create AWS CLI cache directory: %w", err)
+	}
+
+	data, err := json.Marshal(CLICacheEntry{
+		ProviderType: "sso",
+		Credentials: CLICacheCreds{
+			AccessKeyID:     accessKeyID,
+			SecretAccessKey: secretAccessKey,
+			SessionToken:    sessionToken,
+			Expiration:      expiration.UTC().Format(cliCacheExpirationLayout),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("cannot marshal AWS CLI cache entry: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("cannot write AWS CLI cache file: %w", err)
+	}
+	return nil
+}
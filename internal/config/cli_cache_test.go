@@ -0,0 +1,116 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lvstb/saws/internal/profile"
+)
+
+func TestCLICacheFilepathMatchesAWSCLI(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+
+	p := &profile.SSOProfile{StartURL: "https://corp.awsapps.com/start", AccountID: "111111111111", RoleName: "Admin"}
+	got, err := CLICacheFilepath(p)
+	if err != nil {
+		t.Fatalf("CLICacheFilepath() error = %v", err)
+	}
+	// sha1 of {"accountId":"111111111111","roleName":"Admin","startUrl":"https://corp.awsapps.com/start"}
+	want := filepath.Join(dir, ".aws", "cli", "cache", "a9d8bd16212c2a18df720ec1422fb8460f283a1d.json")
+	if got != want {
+		t.Errorf("CLICacheFilepath() = %q, want %q", got, want)
+	}
+}
+
+func TestCLICacheFilepathSSOSession(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	// A profile as saws migrate leaves it: connection in an sso-session block.
+	path, _ := Path()
+	cfg := `[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region    = us-east-1
+
+# managed by saws
+[profile dev]
+sso_session    = corp
+sso_account_id = 111111111111
+sso_role_name  = Admin
+`
+	if err := os.WriteFile(path, []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := FindProfile("dev")
+	if err != nil || p == nil {
+		t.Fatalf("FindProfile() = %v, %v", p, err)
+	}
+	if p.SSOSession != "corp" {
+		t.Fatalf("SSOSession = %q, want corp", p.SSOSession)
+	}
+
+	got, err := CLICacheFilepath(p)
+	if err != nil {
+		t.Fatalf("CLICacheFilepath() error = %v", err)
+	}
+	// sha1 of {"accountId":"111111111111","roleName":"Admin","sessionName":"corp"}
+	want := filepath.Join(dir, ".aws", "cli", "cache", "d7467b2ce0aaa46d5fc3753e3bbaaf372cba5c68.json")
+	if got != want {
+		t.Errorf("CLICacheFilepath() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteCLICacheFormat(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	p := &profile.SSOProfile{StartURL: "https://corp.awsapps.com/start", AccountID: "111111111111", RoleName: "Admin"}
+	expiration := time.Date(2026, 3, 1, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+	if err := WriteCLICache(p, "ASIAEXAMPLE", "secret", "session-token", expiration); err != nil {
+		t.Fatalf("WriteCLICache() error = %v", err)
+	}
+
+	path, _ := CLICacheFilepath(p)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("cache file not written: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("cache file permissions = %04o, want 0600", perm)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("cache file is not JSON: %v", err)
+	}
+	if raw["ProviderType"] != "sso" {
+		t.Errorf("ProviderType = %v, want sso", raw["ProviderType"])
+	}
+	creds, ok := raw["Credentials"].(map[string]any)
+	if !ok {
+		t.Fatalf("Credentials = %v, want an object", raw["Credentials"])
+	}
+	want := map[string]string{
+		"AccessKeyId":     "ASIAEXAMPLE",
+		"SecretAccessKey": "secret",
+		"SessionToken":    "session-token",
+		"Expiration":      "2026-03-01T11:30:00Z",
+	}
+	for key, value := range want {
+		if creds[key] != value {
+			t.Errorf("Credentials.%s = %v, want %q", key, creds[key], value)
+		}
+	}
+	if len(creds) != len(want) {
+		t.Errorf("Credentials has keys %v, want exactly %v", creds, want)
+	}
+}
@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/lvstb/saws/internal/home"
 	"github.com/lvstb/saws/internal/profile"
 	"gopkg.in/ini.v1"
 )
@@ -15,29 +19,69 @@ const (
 	sawsMarker = "# managed by saws"
 )
 
+// File modes enforced after every write. The credentials file holds secrets
+// and must never be readable by other users, regardless of the process umask.
+const (
+	configPerm      os.FileMode = 0644
+	credentialsPerm os.FileMode = 0600
+)
+
+// ConfigFile and CredentialsFile override the AWS config and credentials
+// file paths when set, taking precedence over AWS_CONFIG_FILE and
+// AWS_SHARED_CREDENTIALS_FILE.
+var (
+	ConfigFile      string
+	CredentialsFile string
+)
+
+// LoosePermWarning, when set, is called before a write tightens the mode of
+// an existing file that was more permissive than saws allows.
+var LoosePermWarning func(path string, had, want os.FileMode)
+
 // Path returns the path to the AWS config file.
 func Path() (string, error) {
+	if ConfigFile != "" {
+		return ConfigFile, nil
+	}
 	// Respect AWS_CONFIG_FILE env var
 	if p := os.Getenv("AWS_CONFIG_FILE"); p != "" {
 		return p, nil
 	}
-	home, err := os.UserHomeDir()
+	homeDir, err := home.Dir()
 	if err != nil {
-		return "", fmt.Errorf("cannot determine home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(home, ".aws", "config"), nil
+	return filepath.Join(homeDir, ".aws", "config"), nil
 }
 
 // CredentialsPath returns the path to the AWS credentials file.
 func CredentialsPath() (string, error) {
+	if CredentialsFile != "" {
+		return CredentialsFile, nil
+	}
 	if p := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); p != "" {
 		return p, nil
 	}
-	home, err := os.UserHomeDir()
+	homeDir, err := home.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".aws", "credentials"), nil
+}
+
+// CheckParentDir returns an error unless the directory that would hold path
+// exists, so a mistyped --config-file or --credentials-file fails up front
+// instead of silently creating directories.
+func CheckParentDir(path string) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
 	if err != nil {
-		return "", fmt.Errorf("cannot determine home directory: %w", err)
+		return fmt.Errorf("parent directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("parent %s is not a directory", dir)
 	}
-	return filepath.Join(home, ".aws", "credentials"), nil
+	return nil
 }
 
 // ensureDir creates the parent directory for a file path if it doesn't exist.
@@ -46,12 +90,45 @@ func ensureDir(path string) error {
 	return os.MkdirAll(dir, 0700)
 }
 
-// loadOrCreateINI loads an INI file or creates a new empty one.
+// saveINI writes an INI file and forces its permissions to perm. New files
+// are created with perm, and existing ones are restricted before any data is
+// written, so contents never land in a file readable under the old mode.
+func saveINI(cfg *ini.File, path string, perm os.FileMode) error {
+	if err := ensureDir(path); err != nil {
+		return err
+	}
+	if info, err := os.Stat(path); err == nil {
+		if had := info.Mode().Perm(); had&^perm != 0 && LoosePermWarning != nil {
+			LoosePermWarning(path, had, perm)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot set permissions on %s: %w", path, err)
+	}
+	if _, err := cfg.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// loadOrCreateINI loads an INI file or creates a new empty one, creating
+// its directory so the result can be saved.
 func loadOrCreateINI(path string) (*ini.File, error) {
 	if err := ensureDir(path); err != nil {
 		return nil, fmt.Errorf("cannot create directory for %s: %w", path, err)
 	}
+	return loadINI(path)
+}
 
+// loadINI loads an INI file for reading, treating a missing file as empty.
+// Unlike loadOrCreateINI it never touches the filesystem.
+func loadINI(path string) (*ini.File, error) {
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		return ini.Empty(), nil
 	}
@@ -84,45 +161,278 @@ func profileNameFromSection(section string) string {
 	return strings.TrimPrefix(section, "profile ")
 }
 
-// isSawsProfile checks if a section has SSO config fields (indicating saws management).
-func isSawsProfile(sec *ini.Section) bool {
-	return sec.HasKey("sso_start_url") &&
-		sec.HasKey("sso_region") &&
-		sec.HasKey("sso_account_id") &&
-		sec.HasKey("sso_role_name")
+// ssoSessionKey is the profile key referencing an [sso-session <name>] block.
+const ssoSessionKey = "sso_session"
+
+// ssoSessionSection returns the sso-session block sec refers to, or nil if
+// it refers to none or the block does not exist.
+func ssoSessionSection(cfg *ini.File, sec *ini.Section) *ini.Section {
+	name := keyValue(sec, ssoSessionKey)
+	if name == "" {
+		return nil
+	}
+	s, err := cfg.GetSection("sso-session " + name)
+	if err != nil {
+		return nil
+	}
+	return s
+}
+
+// hasSSOKey reports whether sec has key, either directly or through its
+// sso-session block.
+func hasSSOKey(cfg *ini.File, sec *ini.Section, key string) bool {
+	if hasKey(sec, key) {
+		return true
+	}
+	s := ssoSessionSection(cfg, sec)
+	return s != nil && hasKey(s, key)
+}
+
+// ssoValue returns key from sec, falling back to its sso-session block.
+func ssoValue(cfg *ini.File, sec *ini.Section, key string) string {
+	if hasKey(sec, key) {
+		return keyValue(sec, key)
+	}
+	if s := ssoSessionSection(cfg, sec); s != nil {
+		return keyValue(s, key)
+	}
+	return ""
+}
+
+// Hand-edited and tool-generated configs sometimes spell keys in another
+// case (SSO_Start_URL). AWS tools accept that, so the helpers below match
+// key names case-insensitively and update existing keys in place, keeping
+// the casing the file was written with.
+
+// lookupKey returns the key in sec named name, ignoring case, or nil.
+func lookupKey(sec *ini.Section, name string) *ini.Key {
+	if sec.HasKey(name) {
+		return sec.Key(name)
+	}
+	for _, k := range sec.Keys() {
+		if strings.EqualFold(k.Name(), name) {
+			return k
+		}
+	}
+	return nil
+}
+
+// hasKey reports whether sec has a key named name, ignoring case.
+func hasKey(sec *ini.Section, name string) bool {
+	return lookupKey(sec, name) != nil
 }
 
-// LoadProfiles reads all SSO profiles from the AWS config file.
+// keyValue returns the value of the key named name, ignoring case, or "".
+func keyValue(sec *ini.Section, name string) string {
+	if k := lookupKey(sec, name); k != nil {
+		return k.String()
+	}
+	return ""
+}
+
+// setKey sets name to value, reusing an existing key of any casing.
+func setKey(sec *ini.Section, name, value string) {
+	if k := lookupKey(sec, name); k != nil {
+		k.SetValue(value)
+		return
+	}
+	sec.Key(name).SetValue(value)
+}
+
+// deleteKey removes every key named name, ignoring case.
+func deleteKey(sec *ini.Section, name string) {
+	for k := lookupKey(sec, name); k != nil; k = lookupKey(sec, name) {
+		sec.DeleteKey(k.Name())
+	}
+}
+
+// isSawsProfile checks if a section has SSO config fields (indicating saws
+// management). sso_region is not required: a profile whose sso-session
+// reference did not resolve still loads, with SSORegionMissing set, so its
+// region can be recovered from the SSO cache.
+func isSawsProfile(cfg *ini.File, sec *ini.Section) bool {
+	return hasSSOKey(cfg, sec, "sso_start_url") &&
+		hasKey(sec, "sso_account_id") &&
+		hasKey(sec, "sso_role_name")
+}
+
+// profileFromSection builds an SSOProfile from a config file section,
+// resolving the start URL and SSO region through an sso-session reference.
+func profileFromSection(cfg *ini.File, sec *ini.Section) profile.SSOProfile {
+	p := profile.SSOProfile{
+		Name:        profileNameFromSection(sec.Name()),
+		StartURL:    ssoValue(cfg, sec, "sso_start_url"),
+		Region:      keyValue(sec, "region"),
+		AccountID:   keyValue(sec, "sso_account_id"),
+		AccountName: keyValue(sec, "sso_account_name"),
+		RoleName:    keyValue(sec, "sso_role_name"),
+		SSORegion:   ssoValue(cfg, sec, "sso_region"),
+		Note:        keyValue(sec, noteKey),
+		Group:       keyValue(sec, "sso_account_group"),
+
+		AccountEmail: keyValue(sec, "sso_account_email"),
+	}
+	p.SSORegionMissing = p.SSORegion == ""
+	if ssoSessionSection(cfg, sec) != nil {
+		p.SSOSession = keyValue(sec, ssoSessionKey)
+	}
+	// sec.Key would add a missing key, which a later save then writes out.
+	if k := lookupKey(sec, "duration_seconds"); k != nil {
+		seconds, err := strconv.Atoi(strings.TrimSpace(k.String()))
+		if err != nil {
+			seconds = profile.MalformedDurationSeconds
+		}
+		p.DurationSeconds = seconds
+	}
+	// Profiles without an operational region run where SSO lives.
+	if p.Region == "" {
+		p.Region = p.SSORegion
+	}
+	return p
+}
+
+// LoadProfiles reads all SSO profiles from the AWS config file, followed by
+// any profiles from the files named in SAWS_EXTRA_CONFIG. When a name appears
+// more than once, the primary config file wins, then the earliest extra file.
 func LoadProfiles() ([]profile.SSOProfile, error) {
 	path, err := Path()
 	if err != nil {
 		return nil, err
 	}
 
-	cfg, err := loadOrCreateINI(path)
+	cfg, err := loadINI(path)
 	if err != nil {
 		return nil, err
 	}
 
 	var profiles []profile.SSOProfile
-	for _, sec := range cfg.Sections() {
-		if !isSawsProfile(sec) {
-			continue
+	seen := map[string]bool{}
+	add := func(cfg *ini.File) {
+		for _, sec := range cfg.Sections() {
+			if !isSawsProfile(cfg, sec) {
+				continue
+			}
+			p := profileFromSection(cfg, sec)
+			if seen[p.Name] {
+				continue
+			}
+			seen[p.Name] = true
+			profiles = append(profiles, p)
 		}
+	}
+	add(cfg)
 
-		p := profile.SSOProfile{
-			Name:        profileNameFromSection(sec.Name()),
-			StartURL:    sec.Key("sso_start_url").String(),
-			Region:      sec.Key("sso_region").String(),
-			AccountID:   sec.Key("sso_account_id").String(),
-			AccountName: sec.Key("sso_account_name").String(),
-			RoleName:    sec.Key("sso_role_name").String(),
+	extras, err := ExtraConfigPaths()
+	if err != nil {
+		return nil, err
+	}
+	for _, extra := range extras {
+		extraCfg, err := ini.LoadSources(ini.LoadOptions{SkipUnrecognizableLines: true}, extra)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", extra, err)
 		}
-		profiles = append(profiles, p)
+		add(extraCfg)
 	}
 	return profiles, nil
 }
 
+// AmbiguousProfileError reports a profile name defined by more than one
+// section of the config file, which the normal loader would silently merge.
+type AmbiguousProfileError struct {
+	Name    string
+	Matches []profile.SSOProfile
+}
+
+func (e *AmbiguousProfileError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "profile %q is ambiguous: [%s] appears %d times in ~/.aws/config", e.Name, sectionName(e.Name), len(e.Matches))
+	for _, p := range e.Matches {
+		account := p.AccountID
+		if p.AccountName != "" {
+			account = p.AccountName + " (" + p.AccountID + ")"
+		}
+		fmt.Fprintf(&b, "\n  - %s / %s", account, p.RoleName)
+	}
+	b.WriteString("\nRemove or rename the duplicates (saws validate lists them)")
+	return b.String()
+}
+
+// FindProfile returns the saved profile named name, or nil if there is none.
+// If the config file defines the profile in several sections it returns an
+// *AmbiguousProfileError instead of guessing.
+func FindProfile(name string) (*profile.SSOProfile, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err == nil {
+		cfg, err := ini.LoadSources(ini.LoadOptions{
+			AllowNonUniqueSections:  true,
+			SkipUnrecognizableLines: true,
+		}, path)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+		}
+		secs, _ := cfg.SectionsByName(sectionName(name))
+		var matches []profile.SSOProfile
+		for _, sec := range secs {
+			if isSawsProfile(cfg, sec) {
+				matches = append(matches, profileFromSection(cfg, sec))
+			}
+		}
+		if len(matches) > 1 {
+			return nil, &AmbiguousProfileError{Name: name, Matches: matches}
+		}
+	}
+
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range profiles {
+		if p.Name == name {
+			return &p, nil
+		}
+	}
+	return nil, nil
+}
+
+// ExtraConfigPaths returns the additional, read-only config files named by
+// SAWS_EXTRA_CONFIG. The variable holds a list of files or directories
+// separated like PATH; directories contribute their *.conf files in name
+// order. Entries that do not exist are skipped.
+func ExtraConfigPaths() ([]string, error) {
+	env := os.Getenv("SAWS_EXTRA_CONFIG")
+	if env == "" {
+		return nil, nil
+	}
+
+	var paths []string
+	for _, entry := range filepath.SplitList(env) {
+		if entry == "" {
+			continue
+		}
+		info, err := os.Stat(entry)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %w", entry, err)
+		}
+		if !info.IsDir() {
+			paths = append(paths, entry)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(entry, "*.conf"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
 // SaveProfile writes an SSO profile to the AWS config file.
 func SaveProfile(p profile.SSOProfile) error {
 	return SaveProfiles([]profile.SSOProfile{p})
@@ -150,22 +460,82 @@ func SaveProfiles(profiles []profile.SSOProfile) error {
 		}
 
 		sec.Comment = sawsMarker
-		sec.Key("sso_start_url").SetValue(p.StartURL)
-		sec.Key("sso_region").SetValue(p.Region)
-		sec.Key("sso_account_id").SetValue(p.AccountID)
+		// Keep an sso-session reference while it still matches; otherwise
+		// write the connection inline.
+		if s := ssoSessionSection(cfg, sec); s != nil &&
+			keyValue(s, "sso_start_url") == p.StartURL && keyValue(s, "sso_region") == p.SSOClientRegion() {
+			deleteKey(sec, "sso_start_url")
+			deleteKey(sec, "sso_region")
+		} else {
+			deleteKey(sec, ssoSessionKey)
+			setKey(sec, "sso_start_url", p.StartURL)
+			setKey(sec, "sso_region", p.SSOClientRegion())
+		}
+		if p.Region != "" && p.Region != p.SSOClientRegion() {
+			setKey(sec, "region", p.Region)
+		} else {
+			deleteKey(sec, "region")
+		}
+		setKey(sec, "sso_account_id", p.AccountID)
 		if p.AccountName != "" {
-			sec.Key("sso_account_name").SetValue(p.AccountName)
+			setKey(sec, "sso_account_name", p.AccountName)
+		}
+		setKey(sec, "sso_role_name", p.RoleName)
+		if p.AccountEmail != "" {
+			setKey(sec, "sso_account_email", p.AccountEmail)
+		}
+		if p.Group != "" {
+			setKey(sec, "sso_account_group", p.Group)
+		}
+		// Like the account name, an empty note keeps any existing one, so
+		// re-importing a profile doesn't drop it. Use SetProfileNote to clear.
+		if p.Note != "" {
+			sec.Key(noteKey).SetValue(p.Note)
+		}
+		// A malformed value loaded from the file is left for the user to fix.
+		switch {
+		case p.DurationSeconds > 0:
+			setKey(sec, "duration_seconds", strconv.Itoa(p.DurationSeconds))
+		case p.DurationSeconds == 0:
+			deleteKey(sec, "duration_seconds")
 		}
-		sec.Key("sso_role_name").SetValue(p.RoleName)
 	}
 
-	if err := ensureDir(path); err != nil {
+	return saveINI(cfg, path, configPerm)
+}
+
+// noteKey holds a profile's free-text note. AWS tools ignore unknown keys.
+const noteKey = "sso_account_description"
+
+// SetProfileNote sets the note of a saved profile, removing it if note is
+// empty. It fails if the profile does not exist.
+func SetProfileNote(name, note string) error {
+	path, err := Path()
+	if err != nil {
 		return err
 	}
-	return cfg.SaveTo(path)
+
+	cfg, err := loadOrCreateINI(path)
+	if err != nil {
+		return err
+	}
+
+	sec, err := cfg.GetSection(sectionName(name))
+	if err != nil || !isSawsProfile(cfg, sec) {
+		return fmt.Errorf("profile %q not found in %s", name, path)
+	}
+	if note == "" {
+		sec.DeleteKey(noteKey)
+	} else {
+		sec.Key(noteKey).SetValue(note)
+	}
+
+	return saveINI(cfg, path, configPerm)
 }
 
-// DeleteProfile removes an SSO profile from the AWS config file.
+// DeleteProfile removes an SSO profile from the AWS config file, along with
+// its credentials file section if saws wrote it. Credentials sections saws
+// does not manage are left alone.
 func DeleteProfile(name string) error {
 	path, err := Path()
 	if err != nil {
@@ -180,11 +550,58 @@ func DeleteProfile(name string) error {
 	secName := sectionName(name)
 	cfg.DeleteSection(secName)
 
-	return cfg.SaveTo(path)
+	if err := saveINI(cfg, path, configPerm); err != nil {
+		return err
+	}
+	return deleteManagedCredentials(name)
 }
 
+// deleteManagedCredentials removes the credentials file section of that name
+// if saws wrote it. A missing file or section is not an error.
+func deleteManagedCredentials(name string) error {
+	path, err := CredentialsPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	cfg, err := loadOrCreateINI(path)
+	if err != nil {
+		return err
+	}
+	sec, err := cfg.GetSection(name)
+	if err != nil || !strings.Contains(sec.Comment, sawsMarker) {
+		return nil
+	}
+	cfg.DeleteSection(name)
+	return saveINI(cfg, path, credentialsPerm)
+}
+
+// credentialsExpiresKey records when a section's temporary credentials expire.
+// AWS tools ignore it; saws reads it back to tell whether they are still valid.
+const credentialsExpiresKey = "x_security_token_expires"
+
+// standaloneMarker marks credentials sections that belong to no saved
+// profile: --creds-profile targets and credentials-only logins. It extends
+// sawsMarker, so they still count as saws-managed.
+const standaloneMarker = sawsMarker + ": standalone"
+
 // WriteCredentials writes temporary credentials to the AWS credentials file.
-func WriteCredentials(profileName, accessKeyID, secretAccessKey, sessionToken string) error {
+// A zero expiration leaves no expiry recorded for the section.
+func WriteCredentials(profileName, accessKeyID, secretAccessKey, sessionToken string, expiration time.Time) error {
+	return writeCredentials(sawsMarker, profileName, accessKeyID, secretAccessKey, sessionToken, expiration)
+}
+
+// WriteStandaloneCredentials is WriteCredentials for a section that is not
+// named after a saved profile, which CheckDrift then never reports as
+// orphaned.
+func WriteStandaloneCredentials(section, accessKeyID, secretAccessKey, sessionToken string, expiration time.Time) error {
+	return writeCredentials(standaloneMarker, section, accessKeyID, secretAccessKey, sessionToken, expiration)
+}
+
+func writeCredentials(marker, profileName, accessKeyID, secretAccessKey, sessionToken string, expiration time.Time) error {
 	path, err := CredentialsPath()
 	if err != nil {
 		return err
@@ -200,13 +617,78 @@ func WriteCredentials(profileName, accessKeyID, secretAccessKey, sessionToken st
 		sec = cfg.Section(profileName)
 	}
 
-	sec.Comment = sawsMarker
+	sec.Comment = marker
 	sec.Key("aws_access_key_id").SetValue(accessKeyID)
 	sec.Key("aws_secret_access_key").SetValue(secretAccessKey)
 	sec.Key("aws_session_token").SetValue(sessionToken)
+	if expiration.IsZero() {
+		sec.DeleteKey(credentialsExpiresKey)
+	} else {
+		sec.Key(credentialsExpiresKey).SetValue(expiration.UTC().Format(time.RFC3339))
+	}
 
-	if err := ensureDir(path); err != nil {
-		return err
+	return saveINI(cfg, path, credentialsPerm)
+}
+
+// CredentialsExpiration returns the recorded expiry of a credentials file
+// section. It returns false if the file, section, or expiry is missing.
+func CredentialsExpiration(section string) (time.Time, bool) {
+	path, err := CredentialsPath()
+	if err != nil {
+		return time.Time{}, false
+	}
+	cfg, err := ini.Load(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec, err := cfg.GetSection(section)
+	if err != nil || !sec.HasKey(credentialsExpiresKey) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, sec.Key(credentialsExpiresKey).String())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// SSOSession is a named [sso-session] block, as written by `aws configure sso`.
+type SSOSession struct {
+	Name     string
+	StartURL string
+	Region   string
+}
+
+// LoadSSOSession reads the [sso-session <name>] block from the AWS config
+// file. It returns an error if the block is missing or lacks a start URL or
+// region.
+func LoadSSOSession(name string) (*SSOSession, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("sso-session %q not found: %w", name, err)
+	}
+	cfg, err := ini.LoadSources(ini.LoadOptions{SkipUnrecognizableLines: true}, path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+	sec, err := cfg.GetSection("sso-session " + name)
+	if err != nil {
+		return nil, fmt.Errorf("sso-session %q not found in %s", name, path)
+	}
+
+	s := &SSOSession{
+		Name:     name,
+		StartURL: keyValue(sec, "sso_start_url"),
+		Region:   keyValue(sec, "sso_region"),
+	}
+	if s.StartURL == "" {
+		return nil, fmt.Errorf("sso-session %q has no sso_start_url", name)
+	}
+	if s.Region == "" {
+		return nil, fmt.Errorf("sso-session %q has no sso_region", name)
 	}
-	return cfg.SaveTo(path)
+	return s, nil
 }
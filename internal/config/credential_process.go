@@ -0,0 +1,147 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// credentialProcessKey makes AWS tools run a command for credentials.
+const credentialProcessKey = "credential_process"
+
+// credentialProcessFlag marks credential_process commands that saws wired,
+// so unwiring never touches commands users set up themselves.
+const credentialProcessFlag = "--credential-process"
+
+// processSuffix names the profile wired to run saws for a saws profile.
+const processSuffix = "-process"
+
+// ProcessProfileName returns the name of the credential_process profile that
+// WireCredentialProcess creates for the saws profile name.
+//
+// AWS tools resolve sso_* keys before credential_process, so the command
+// can't live in the SSO profile itself: it would never run.
+func ProcessProfileName(name string) string {
+	return name + processSuffix
+}
+
+// WireCredentialProcess adds a [profile <name>-process] section running the
+// command returned for it, for every saws-managed profile whose name match
+// accepts. Sections already set to that command are left alone, and a
+// section of that name that saws did not write is an error. It returns the
+// names of the profiles it added or changed, and only writes the file when
+// there are any.
+func WireCredentialProcess(command func(name string) string, match func(name string) bool) ([]string, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadOrCreateINI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	dirty := false
+	for _, sec := range sawsSections(cfg, match) {
+		name := profileNameFromSection(sec.Name())
+		// Older versions set the command in the SSO profile, where it is
+		// dead; drop it now that it moves to its own profile.
+		if isWiredCommand(sec) {
+			deleteKey(sec, credentialProcessKey)
+			dirty = true
+		}
+
+		processName := ProcessProfileName(name)
+		want := command(name)
+		target, err := cfg.GetSection(sectionName(processName))
+		if err != nil {
+			target, err = cfg.NewSection(sectionName(processName))
+			if err != nil {
+				return nil, err
+			}
+			target.Comment = sawsMarker
+		} else if !isWiredProcessSection(target) {
+			return nil, fmt.Errorf("profile %q already exists and was not written by saws; rename it or skip %q with --filter", processName, name)
+		}
+
+		region := profileFromSection(cfg, sec).Region
+		if keyValue(target, credentialProcessKey) == want && keyValue(target, "region") == region {
+			continue
+		}
+		setKey(target, credentialProcessKey, want)
+		if region != "" {
+			setKey(target, "region", region)
+		}
+		changed = append(changed, processName)
+	}
+	if len(changed) == 0 && !dirty {
+		return nil, nil
+	}
+	return changed, saveINI(cfg, path, configPerm)
+}
+
+// UnwireCredentialProcess removes the profiles added by WireCredentialProcess
+// for the saws profiles whose name match accepts, along with any command an
+// older version wrote into the profiles themselves. It returns the names of
+// the profiles it removed or changed.
+func UnwireCredentialProcess(match func(name string) bool) ([]string, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := loadOrCreateINI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for _, sec := range sawsSections(cfg, match) {
+		if isWiredCommand(sec) {
+			deleteKey(sec, credentialProcessKey)
+			changed = append(changed, profileNameFromSection(sec.Name()))
+		}
+	}
+	for _, sec := range cfg.Sections() {
+		name := profileNameFromSection(sec.Name())
+		source, ok := strings.CutSuffix(name, processSuffix)
+		if !ok || !isWiredProcessSection(sec) || (match != nil && !match(source)) {
+			continue
+		}
+		cfg.DeleteSection(sec.Name())
+		changed = append(changed, name)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	return changed, saveINI(cfg, path, configPerm)
+}
+
+// sawsSections returns the saws-managed profile sections whose profile name
+// match accepts (all of them when match is nil).
+func sawsSections(cfg *ini.File, match func(name string) bool) []*ini.Section {
+	var secs []*ini.Section
+	for _, sec := range cfg.Sections() {
+		if !strings.Contains(sec.Comment, sawsMarker) || !isSawsProfile(cfg, sec) {
+			continue
+		}
+		if match != nil && !match(profileNameFromSection(sec.Name())) {
+			continue
+		}
+		secs = append(secs, sec)
+	}
+	return secs
+}
+
+// isWiredCommand reports whether sec has a credential_process that saws
+// wired.
+func isWiredCommand(sec *ini.Section) bool {
+	return strings.Contains(keyValue(sec, credentialProcessKey), credentialProcessFlag)
+}
+
+// isWiredProcessSection reports whether sec is a credential_process profile
+// written by WireCredentialProcess.
+func isWiredProcessSection(sec *ini.Section) bool {
+	return strings.Contains(sec.Comment, sawsMarker) && isWiredCommand(sec) && !hasKey(sec, "sso_account_id")
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/lvstb/saws/internal/profile"
+)
+
+func TestWireAndUnwireCredentialProcess(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveProfiles([]profile.SSOProfile{
+		{Name: "dev", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "prod", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1", AccountID: "222222222222", RoleName: "Admin"},
+	}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+	path, _ := Path()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\n[profile custom]\ncredential_process = /usr/bin/my-helper\n")
+	f.Close()
+	original, _ := os.ReadFile(path)
+
+	command := func(name string) string { return "/usr/local/bin/saws --credential-process --profile " + name }
+	onlyDev := func(name string) bool { return name == "dev" }
+
+	changed, err := WireCredentialProcess(command, onlyDev)
+	if err != nil {
+		t.Fatalf("WireCredentialProcess() error = %v", err)
+	}
+	if strings.Join(changed, ",") != "dev-process" {
+		t.Errorf("WireCredentialProcess() changed %v, want [dev-process]", changed)
+	}
+
+	// A second run is a no-op.
+	changed, err = WireCredentialProcess(command, onlyDev)
+	if err != nil || len(changed) != 0 {
+		t.Errorf("second WireCredentialProcess() = %v, %v; want no changes", changed, err)
+	}
+	data, _ := os.ReadFile(path)
+	if n := strings.Count(string(data), "--credential-process --profile dev"); n != 1 {
+		t.Errorf("config has %d credential_process lines for dev, want 1:\n%s", n, data)
+	}
+	if strings.Contains(string(data), "--profile prod") {
+		t.Error("profile outside the filter was wired")
+	}
+
+	// The SSO profile itself is untouched, so saws can still log in to it.
+	p, err := FindProfile("dev")
+	if err != nil || p == nil || p.AccountID != "111111111111" {
+		t.Errorf("FindProfile(dev) = %+v, %v; want the SSO profile", p, err)
+	}
+
+	changed, err = UnwireCredentialProcess(nil)
+	if err != nil {
+		t.Fatalf("UnwireCredentialProcess() error = %v", err)
+	}
+	if strings.Join(changed, ",") != "dev-process" {
+		t.Errorf("UnwireCredentialProcess() changed %v, want [dev-process]", changed)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != string(original) {
+		t.Errorf("config after unwiring differs from the original:\n%s\nwant:\n%s", data, original)
+	}
+}
+
+func TestWiredProfileResolvesToProcessProvider(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveProfile(profile.SSOProfile{
+		Name: "dev", StartURL: "https://corp.awsapps.com/start", Region: "eu-west-1", AccountID: "111111111111", RoleName: "Admin",
+	}); err != nil {
+		t.Fatalf("SaveProfile() error = %v", err)
+	}
+	want := "/usr/local/bin/saws --credential-process --profile dev"
+	if _, err := WireCredentialProcess(func(string) string { return want }, nil); err != nil {
+		t.Fatalf("WireCredentialProcess() error = %v", err)
+	}
+
+	path, _ := Path()
+	sc, err := awsconfig.LoadSharedConfigProfile(context.Background(), ProcessProfileName("dev"), func(o *awsconfig.LoadSharedConfigOptions) {
+		o.ConfigFiles = []string{path}
+		o.CredentialsFiles = []string{}
+	})
+	if err != nil {
+		t.Fatalf("LoadSharedConfigProfile() error = %v", err)
+	}
+	if sc.CredentialProcess != want {
+		t.Errorf("CredentialProcess = %q, want %q", sc.CredentialProcess, want)
+	}
+	// Any SSO setting would win over credential_process in the SDKs.
+	if sc.SSOAccountID != "" || sc.SSORoleName != "" || sc.SSOStartURL != "" || sc.SSOSessionName != "" {
+		t.Errorf("wired profile has SSO settings that shadow credential_process: %+v", sc)
+	}
+	if sc.Region != "eu-west-1" {
+		t.Errorf("Region = %q, want eu-west-1", sc.Region)
+	}
+}
+
+func TestWireCredentialProcessKeepsUserProfile(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	if err := SaveProfile(profile.SSOProfile{
+		Name: "dev", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin",
+	}); err != nil {
+		t.Fatalf("SaveProfile() error = %v", err)
+	}
+	path, _ := Path()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\n[profile dev-process]\nCredential_Process = /usr/bin/my-helper\n")
+	f.Close()
+	original, _ := os.ReadFile(path)
+
+	if _, err := WireCredentialProcess(func(string) string { return "saws --credential-process --profile dev" }, nil); err == nil {
+		t.Error("WireCredentialProcess() should refuse to overwrite a profile saws did not write")
+	}
+	if data, _ := os.ReadFile(path); string(data) != string(original) {
+		t.Errorf("config changed:\n%s", data)
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// SessionMigration describes one sso-session block used by a migration.
+type SessionMigration struct {
+	Name     string
+	StartURL string
+	Region   string
+	Profiles []string // profiles rewritten to reference this session
+	Existing bool     // the block was already in the config file
+}
+
+// Migration is the result of MigrateToSSOSessions.
+type Migration struct {
+	Sessions []SessionMigration
+	// Before and After are the config file contents around the migration.
+	Before, After []byte
+	// Backup is the path of the pre-migration copy, set once applied.
+	Backup string
+}
+
+// Changed reports whether the migration rewrites any profile.
+func (m *Migration) Changed() bool {
+	for _, s := range m.Sessions {
+		if len(s.Profiles) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// MigrateToSSOSessions converts saws-managed profiles with an inline
+// sso_start_url and sso_region into references to shared [sso-session]
+// blocks, one per start URL and region, reusing matching blocks that already
+// exist. Everything else in the file is preserved. The file is only written
+// when apply is true, after copying the original to <config>.bak.
+func MigrateToSSOSessions(apply bool) (*Migration, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	cfg, err := loadOrCreateINI(path)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Migration{Before: before, Sessions: migrateSections(cfg)}
+
+	var buf strings.Builder
+	if _, err := cfg.WriteTo(&buf); err != nil {
+		return nil, fmt.Errorf("cannot render migrated config: %w", err)
+	}
+	m.After = []byte(buf.String())
+
+	if !apply || !m.Changed() {
+		return m, nil
+	}
+	m.Backup = path + ".bak"
+	if err := os.WriteFile(m.Backup, before, configPerm); err != nil {
+		return nil, fmt.Errorf("cannot write backup %s: %w", m.Backup, err)
+	}
+	if err := saveINI(cfg, path, configPerm); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// migrateSections rewrites inline saws profiles in cfg to reference
+// sso-session blocks and returns the sessions involved.
+func migrateSections(cfg *ini.File) []SessionMigration {
+	type key struct{ startURL, region string }
+
+	var sessions []*SessionMigration
+	byKey := map[key]*SessionMigration{}
+	taken := map[string]bool{}
+
+	for _, sec := range cfg.Sections() {
+		name, ok := strings.CutPrefix(sec.Name(), "sso-session ")
+		if !ok {
+			continue
+		}
+		taken[name] = true
+		k := key{keyValue(sec, "sso_start_url"), keyValue(sec, "sso_region")}
+		if _, dup := byKey[k]; !dup {
+			s := &SessionMigration{Name: name, StartURL: k.startURL, Region: k.region, Existing: true}
+			byKey[k] = s
+			sessions = append(sessions, s)
+		}
+	}
+
+	for _, sec := range cfg.Sections() {
+		if !strings.HasPrefix(sec.Name(), "profile ") && sec.Name() != "default" {
+			continue
+		}
+		if !strings.Contains(sec.Comment, sawsMarker) || hasKey(sec, ssoSessionKey) || !isSawsProfile(cfg, sec) || keyValue(sec, "sso_region") == "" {
+			continue
+		}
+
+		k := key{keyValue(sec, "sso_start_url"), keyValue(sec, "sso_region")}
+		s, ok := byKey[k]
+		if !ok {
+			s = &SessionMigration{Name: sessionName(k.startURL, k.region, taken), StartURL: k.startURL, Region: k.region}
+			taken[s.Name] = true
+			byKey[k] = s
+			sessions = append(sessions, s)
+
+			block := cfg.Section("sso-session " + s.Name)
+			block.Comment = sawsMarker
+			block.Key("sso_start_url").SetValue(s.StartURL)
+			block.Key("sso_region").SetValue(s.Region)
+			block.Key("sso_registration_scopes").SetValue("sso:account:access")
+		}
+
+		deleteKey(sec, "sso_start_url")
+		deleteKey(sec, "sso_region")
+		sec.Key(ssoSessionKey).SetValue(s.Name)
+		s.Profiles = append(s.Profiles, profileNameFromSection(sec.Name()))
+	}
+
+	out := make([]SessionMigration, len(sessions))
+	for i, s := range sessions {
+		out[i] = *s
+	}
+	return out
+}
+
+// sessionName picks an unused sso-session name for a start URL: its
+// awsapps.com subdomain (or host), then with the region appended, then with
+// a counter.
+func sessionName(startURL, region string, taken map[string]bool) string {
+	base := "sso"
+	if u, err := url.Parse(startURL); err == nil && u.Hostname() != "" {
+		base = strings.TrimSuffix(u.Hostname(), ".awsapps.com")
+		base = strings.ReplaceAll(base, ".", "-")
+	}
+
+	candidates := []string{base, base + "-" + region}
+	for _, c := range candidates {
+		if !taken[c] {
+			return c
+		}
+	}
+	for i := 2; ; i++ {
+		c := fmt.Sprintf("%s-%s-%d", base, region, i)
+		if !taken[c] {
+			return c
+		}
+	}
+}
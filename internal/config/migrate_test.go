@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lvstb/saws/internal/profile"
+	"gopkg.in/ini.v1"
+)
+
+func TestMigrateToSSOSessionsRoundTrip(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	profiles := []profile.SSOProfile{
+		{Name: "dev-admin", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "dev-eu", StartURL: "https://corp.awsapps.com/start", Region: "eu-west-1", SSORegion: "us-east-1", AccountID: "111111111111", RoleName: "ReadOnly"},
+		{Name: "partner", StartURL: "https://partner.awsapps.com/start", Region: "eu-central-1", AccountID: "222222222222", AccountName: "Partner", RoleName: "Admin"},
+	}
+	if err := SaveProfiles(profiles); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+	path, _ := Path()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\n[profile static]\nregion = us-west-2\noutput = json\n")
+	f.Close()
+
+	want, err := LoadProfiles()
+	if err != nil {
+		t.Fatalf("LoadProfiles() before error = %v", err)
+	}
+
+	// Dry run leaves the file alone.
+	before, _ := os.ReadFile(path)
+	m, err := MigrateToSSOSessions(false)
+	if err != nil {
+		t.Fatalf("MigrateToSSOSessions(false) error = %v", err)
+	}
+	if after, _ := os.ReadFile(path); string(after) != string(before) {
+		t.Error("dry run modified the config file")
+	}
+	if !m.Changed() || len(m.Sessions) != 2 {
+		t.Fatalf("dry run sessions = %+v, want 2 new sessions", m.Sessions)
+	}
+
+	m, err = MigrateToSSOSessions(true)
+	if err != nil {
+		t.Fatalf("MigrateToSSOSessions(true) error = %v", err)
+	}
+	if backup, err := os.ReadFile(m.Backup); err != nil || string(backup) != string(before) {
+		t.Errorf("backup %s does not hold the original config (err %v)", m.Backup, err)
+	}
+
+	got, err := LoadProfiles()
+	if err != nil {
+		t.Fatalf("LoadProfiles() after error = %v", err)
+	}
+	// Migrated profiles resolve the same, now through an sso-session.
+	for i := range got {
+		if got[i].SSOSession == "" {
+			t.Errorf("profile %s has no sso-session after migration", got[i].Name)
+		}
+		got[i].SSOSession = ""
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("profiles after migration = %+v\nwant %+v", got, want)
+	}
+
+	cfg, err := ini.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corp := cfg.Section("sso-session corp")
+	if corp.Key("sso_start_url").String() != "https://corp.awsapps.com/start" || corp.Key("sso_region").String() != "us-east-1" {
+		t.Errorf("sso-session corp = %v, want corp start URL in us-east-1", corp.KeysHash())
+	}
+	dev := cfg.Section("profile dev-admin")
+	if dev.Key("sso_session").String() != "corp" || dev.HasKey("sso_start_url") {
+		t.Errorf("profile dev-admin = %v, want sso_session = corp and no inline start URL", dev.KeysHash())
+	}
+	if cfg.Section("profile static").Key("output").String() != "json" {
+		t.Error("unrelated profile was not preserved")
+	}
+
+	// A second run has nothing left to do.
+	m, err = MigrateToSSOSessions(true)
+	if err != nil {
+		t.Fatalf("second MigrateToSSOSessions() error = %v", err)
+	}
+	if m.Changed() || m.Backup != "" {
+		t.Errorf("second migration changed = %v, backup = %q; want no-op", m.Changed(), m.Backup)
+	}
+}
+
+func TestSessionName(t *testing.T) {
+	taken := map[string]bool{"corp": true}
+	if got := sessionName("https://corp.awsapps.com/start", "eu-west-1", taken); got != "corp-eu-west-1" {
+		t.Errorf("sessionName() = %q, want corp-eu-west-1", got)
+	}
+	if got := sessionName("https://sso.example.com/start", "us-east-1", nil); !strings.HasPrefix(got, "sso-example-com") {
+		t.Errorf("sessionName() = %q, want host-based name", got)
+	}
+}
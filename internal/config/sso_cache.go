@@ -7,17 +7,30 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/lvstb/saws/internal/home"
+	"github.com/lvstb/saws/internal/profile"
 )
 
 // SSOToken represents a cached SSO access token in the standard AWS CLI format.
 // Stored at ~/.aws/sso/cache/{SHA1(startUrl)}.json.
+//
+// The client registration and refresh token fields are optional; when set
+// they complete the schema the AWS CLI writes, so it can refresh the token
+// itself.
 type SSOToken struct {
 	StartURL    string    `json:"startUrl"`
 	Region      string    `json:"region"`
 	AccessToken string    `json:"accessToken"`
 	ExpiresAt   time.Time `json:"-"` // custom marshal to RFC3339
+
+	ClientID              string    `json:"clientId,omitempty"`
+	ClientSecret          string    `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt time.Time `json:"-"` // zero if unknown
+	RefreshToken          string    `json:"refreshToken,omitempty"`
 }
 
 // ssoTokenJSON is the wire format for SSOToken (expiresAt as string).
@@ -26,16 +39,32 @@ type ssoTokenJSON struct {
 	Region      string `json:"region"`
 	AccessToken string `json:"accessToken"`
 	ExpiresAt   string `json:"expiresAt"`
+
+	ClientID              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
 }
 
+// legacyExpiresAtLayout is the expiresAt format of older AWS CLI versions,
+// e.g. "2020-06-17T10:02:08UTC".
+const legacyExpiresAtLayout = "2006-01-02T15:04:05UTC"
+
 // MarshalJSON implements json.Marshaler with RFC3339 expiresAt.
 func (t SSOToken) MarshalJSON() ([]byte, error) {
-	return json.Marshal(ssoTokenJSON{
-		StartURL:    t.StartURL,
-		Region:      t.Region,
-		AccessToken: t.AccessToken,
-		ExpiresAt:   t.ExpiresAt.UTC().Format(time.RFC3339),
-	})
+	raw := ssoTokenJSON{
+		StartURL:     t.StartURL,
+		Region:       t.Region,
+		AccessToken:  t.AccessToken,
+		ExpiresAt:    t.ExpiresAt.UTC().Format(time.RFC3339),
+		ClientID:     t.ClientID,
+		ClientSecret: t.ClientSecret,
+		RefreshToken: t.RefreshToken,
+	}
+	if !t.RegistrationExpiresAt.IsZero() {
+		raw.RegistrationExpiresAt = t.RegistrationExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return json.Marshal(raw)
 }
 
 // UnmarshalJSON implements json.Unmarshaler with RFC3339 expiresAt.
@@ -47,27 +76,49 @@ func (t *SSOToken) UnmarshalJSON(data []byte) error {
 
 	expiresAt, err := time.Parse(time.RFC3339, raw.ExpiresAt)
 	if err != nil {
-		// Also try the legacy AWS CLI format "2020-06-17T10:02:08UTC"
-		expiresAt, err = time.Parse("2006-01-02T15:04:05UTC", raw.ExpiresAt)
+		// Also try the legacy AWS CLI format
+		expiresAt, err = time.Parse(legacyExpiresAtLayout, raw.ExpiresAt)
 		if err != nil {
 			return fmt.Errorf("cannot parse expiresAt %q: %w", raw.ExpiresAt, err)
 		}
 	}
 
+	var registrationExpiresAt time.Time
+	if raw.RegistrationExpiresAt != "" {
+		registrationExpiresAt, err = time.Parse(time.RFC3339, raw.RegistrationExpiresAt)
+		if err != nil {
+			return fmt.Errorf("cannot parse registrationExpiresAt %q: %w", raw.RegistrationExpiresAt, err)
+		}
+	}
+
 	t.StartURL = raw.StartURL
 	t.Region = raw.Region
 	t.AccessToken = raw.AccessToken
 	t.ExpiresAt = expiresAt
+	t.ClientID = raw.ClientID
+	t.ClientSecret = raw.ClientSecret
+	t.RegistrationExpiresAt = registrationExpiresAt
+	t.RefreshToken = raw.RefreshToken
 	return nil
 }
 
 // ssoCacheDir returns the path to the SSO cache directory.
 func ssoCacheDir() (string, error) {
-	home, err := os.UserHomeDir()
+	homeDir, err := home.Dir()
 	if err != nil {
-		return "", fmt.Errorf("cannot determine home directory: %w", err)
+		return "", err
 	}
-	return filepath.Join(home, ".aws", "sso", "cache"), nil
+	return filepath.Join(homeDir, ".aws", "sso", "cache"), nil
+}
+
+// SSOCacheDir returns the path to the SSO cache directory.
+func SSOCacheDir() (string, error) {
+	return ssoCacheDir()
+}
+
+// SSOCacheFilepath returns the cache file path for a given start URL.
+func SSOCacheFilepath(startURL string) (string, error) {
+	return ssoCacheFilepath(startURL)
 }
 
 // ssoCacheFilepath returns the cache file path for a given start URL.
@@ -88,7 +139,18 @@ func ssoCacheFilepath(startURL string) (string, error) {
 // WriteSSOCache writes an SSO access token to the standard AWS SSO cache.
 // This allows other AWS tools (CLI, SDKs) to use the cached token via AWS_PROFILE.
 func WriteSSOCache(startURL, region, accessToken string, expiresAt time.Time) error {
-	path, err := ssoCacheFilepath(startURL)
+	return WriteSSOToken(SSOToken{
+		StartURL:    startURL,
+		Region:      region,
+		AccessToken: accessToken,
+		ExpiresAt:   expiresAt,
+	})
+}
+
+// WriteSSOToken writes token to the standard AWS SSO cache, including any
+// client registration and refresh token fields it carries.
+func WriteSSOToken(token SSOToken) error {
+	path, err := ssoCacheFilepath(token.StartURL)
 	if err != nil {
 		return err
 	}
@@ -97,13 +159,6 @@ func WriteSSOCache(startURL, region, accessToken string, expiresAt time.Time) er
 		return fmt.Errorf("cannot create SSO cache directory: %w", err)
 	}
 
-	token := SSOToken{
-		StartURL:    startURL,
-		Region:      region,
-		AccessToken: accessToken,
-		ExpiresAt:   expiresAt,
-	}
-
 	data, err := json.Marshal(token)
 	if err != nil {
 		return fmt.Errorf("cannot marshal SSO token: %w", err)
@@ -116,9 +171,9 @@ func WriteSSOCache(startURL, region, accessToken string, expiresAt time.Time) er
 	return nil
 }
 
-// ReadSSOCache reads a cached SSO access token for the given start URL.
-// Returns nil if the cache file doesn't exist or the token is expired.
-func ReadSSOCache(startURL string) *SSOToken {
+// readSSOCacheFile reads and parses the cache file for the given start URL
+// without checking expiry. Returns nil if the file is missing or invalid.
+func readSSOCacheFile(startURL string) *SSOToken {
 	path, err := ssoCacheFilepath(startURL)
 	if err != nil {
 		return nil
@@ -133,6 +188,72 @@ func ReadSSOCache(startURL string) *SSOToken {
 	if err := json.Unmarshal(data, &token); err != nil {
 		return nil
 	}
+	upgradeLegacyCache(path, data)
+	return &token
+}
+
+// upgradeLegacyCache rewrites the cache file at path, whose contents are
+// data, with expiresAt in RFC3339 if it still uses legacyExpiresAtLayout,
+// since tools other than saws may not understand the legacy form. Every
+// other field is kept as is, including ones saws does not know. It is best
+// effort: on failure the file is left alone, as saws can still read it.
+func upgradeLegacyCache(path string, data []byte) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return
+	}
+	var expiresAt string
+	if err := json.Unmarshal(fields["expiresAt"], &expiresAt); err != nil {
+		return
+	}
+	if _, err := time.Parse(time.RFC3339, expiresAt); err == nil {
+		return
+	}
+	t, err := time.Parse(legacyExpiresAtLayout, expiresAt)
+	if err != nil {
+		return
+	}
+
+	canonical, err := json.Marshal(t.UTC().Format(time.RFC3339))
+	if err != nil {
+		return
+	}
+	fields["expiresAt"] = canonical
+	upgraded, err := json.Marshal(fields)
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(path, upgraded, 0600)
+}
+
+// CachedSSORegion returns the SSO region recorded in the cache for the given
+// start URL, even if the cached token has expired. Returns "" if unknown.
+func CachedSSORegion(startURL string) string {
+	token := readSSOCacheFile(startURL)
+	if token == nil {
+		return ""
+	}
+	return token.Region
+}
+
+// ReadSSOCacheForProfile returns the valid cached token for the profile's own
+// start URL, or nil. Tokens are keyed by start URL alone, so profiles that
+// share an account ID across organizations must always be resolved through
+// their start URL, never through the account.
+func ReadSSOCacheForProfile(p *profile.SSOProfile) *SSOToken {
+	if p == nil || p.StartURL == "" {
+		return nil
+	}
+	return ReadSSOCache(p.StartURL)
+}
+
+// ReadSSOCache reads a cached SSO access token for the given start URL.
+// Returns nil if the cache file doesn't exist or the token is expired.
+func ReadSSOCache(startURL string) *SSOToken {
+	token := readSSOCacheFile(startURL)
+	if token == nil {
+		return nil
+	}
 
 	// Verify the token has required fields and is not expired.
 	// Add a 5-minute buffer to avoid using tokens that are about to expire.
@@ -140,5 +261,64 @@ func ReadSSOCache(startURL string) *SSOToken {
 		return nil
 	}
 
-	return &token
+	return token
+}
+
+// DeleteSSOCache removes the cached SSO access token for the given start URL.
+// It is not an error if no cache file exists.
+func DeleteSSOCache(startURL string) error {
+	path, err := ssoCacheFilepath(startURL)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove SSO cache file: %w", err)
+	}
+	return nil
+}
+
+// ListSSOCache returns every cached SSO access token in the cache directory,
+// including expired ones, sorted by start URL. Files that are not SSO tokens
+// (e.g. AWS CLI client registrations) or cannot be parsed are skipped.
+func ListSSOCache() ([]SSOToken, error) {
+	dir, err := ssoCacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot read SSO cache directory: %w", err)
+	}
+
+	var tokens []SSOToken
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+
+		var token SSOToken
+		if err := json.Unmarshal(data, &token); err != nil {
+			continue
+		}
+		if token.StartURL == "" || token.AccessToken == "" {
+			continue
+		}
+		upgradeLegacyCache(filepath.Join(dir, e.Name()), data)
+		tokens = append(tokens, token)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].StartURL < tokens[j].StartURL
+	})
+	return tokens, nil
 }
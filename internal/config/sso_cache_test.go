@@ -9,6 +9,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/lvstb/saws/internal/profile"
 )
 
 func TestWriteAndReadSSOCache(t *testing.T) {
@@ -99,6 +101,61 @@ func TestWriteAndReadSSOCache(t *testing.T) {
 	}
 }
 
+func TestWriteSSOTokenFullSchema(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	startURL := "https://mycompany.awsapps.com/start"
+	want := SSOToken{
+		StartURL:              startURL,
+		Region:                "eu-west-1",
+		AccessToken:           "access-token",
+		ExpiresAt:             time.Now().Add(8 * time.Hour).Truncate(time.Second),
+		ClientID:              "client-id",
+		ClientSecret:          "client-secret",
+		RegistrationExpiresAt: time.Now().Add(90 * 24 * time.Hour).Truncate(time.Second),
+		RefreshToken:          "refresh-token",
+	}
+	if err := WriteSSOToken(want); err != nil {
+		t.Fatalf("WriteSSOToken() error = %v", err)
+	}
+
+	path, _ := ssoCacheFilepath(startURL)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("cannot read cache file: %v", err)
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("cannot parse cache file JSON: %v", err)
+	}
+	// The AWS CLI's key names.
+	for _, key := range []string{"startUrl", "region", "accessToken", "expiresAt", "clientId", "clientSecret", "registrationExpiresAt", "refreshToken"} {
+		if raw[key] == "" {
+			t.Errorf("cache file is missing %s: %s", key, data)
+		}
+	}
+
+	got := ReadSSOCache(startURL)
+	if got == nil {
+		t.Fatal("ReadSSOCache() returned nil")
+	}
+	if got.ClientID != want.ClientID || got.ClientSecret != want.ClientSecret || got.RefreshToken != want.RefreshToken {
+		t.Errorf("ReadSSOCache() = %+v, want the registration and refresh token kept", got)
+	}
+	if !got.RegistrationExpiresAt.Equal(want.RegistrationExpiresAt) {
+		t.Errorf("RegistrationExpiresAt = %v, want %v", got.RegistrationExpiresAt, want.RegistrationExpiresAt)
+	}
+
+	// The four-field subset still omits the optional keys.
+	if err := WriteSSOCache(startURL, "eu-west-1", "access-token", want.ExpiresAt); err != nil {
+		t.Fatalf("WriteSSOCache() error = %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if strings.Contains(string(data), "clientId") || strings.Contains(string(data), "registrationExpiresAt") {
+		t.Errorf("WriteSSOCache() wrote optional fields: %s", data)
+	}
+}
+
 func TestReadSSOCacheMissing(t *testing.T) {
 	tmpHome := t.TempDir()
 	t.Setenv("HOME", tmpHome)
@@ -203,6 +260,63 @@ func TestReadSSOCacheLegacyFormat(t *testing.T) {
 	}
 }
 
+func TestReadSSOCacheUpgradesLegacyFormat(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	startURL := "https://legacy.awsapps.com/start"
+	path, err := ssoCacheFilepath(startURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	expires := time.Now().Add(8 * time.Hour).UTC().Truncate(time.Second)
+	legacyJSON := `{
+		"startUrl": "https://legacy.awsapps.com/start",
+		"region": "us-east-1",
+		"accessToken": "legacy-token",
+		"expiresAt": "` + expires.Format("2006-01-02T15:04:05UTC") + `",
+		"refreshToken": "refresh",
+		"scopes": ["sso:account:access"]
+	}`
+	if err := os.WriteFile(path, []byte(legacyJSON), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if token := ReadSSOCache(startURL); token == nil {
+		t.Fatal("ReadSSOCache() returned nil for legacy format token")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("upgraded file is not JSON: %v\n%s", err, data)
+	}
+	if got := fields["expiresAt"]; got != expires.Format(time.RFC3339) {
+		t.Errorf("expiresAt on disk = %v, want RFC3339 %s", got, expires.Format(time.RFC3339))
+	}
+	for key, want := range map[string]string{
+		"startUrl":     "https://legacy.awsapps.com/start",
+		"region":       "us-east-1",
+		"accessToken":  "legacy-token",
+		"refreshToken": "refresh",
+	} {
+		if fields[key] != want {
+			t.Errorf("%s on disk = %v, want %q", key, fields[key], want)
+		}
+	}
+	// Fields saws does not model survive the rewrite.
+	if scopes, ok := fields["scopes"].([]any); !ok || len(scopes) != 1 || scopes[0] != "sso:account:access" {
+		t.Errorf("scopes on disk = %v, want them preserved", fields["scopes"])
+	}
+}
+
 func TestSSOCacheFilepathDeterministic(t *testing.T) {
 	tmpHome := t.TempDir()
 	t.Setenv("HOME", tmpHome)
@@ -262,3 +376,125 @@ func TestSSOCacheOverwrite(t *testing.T) {
 		t.Errorf("Region = %q, want %q", token.Region, "eu-west-1")
 	}
 }
+
+func TestDeleteSSOCache(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	startURL := "https://delete.awsapps.com/start"
+	if err := WriteSSOCache(startURL, "us-east-1", "token", time.Now().Add(8*time.Hour)); err != nil {
+		t.Fatalf("WriteSSOCache() error = %v", err)
+	}
+
+	if err := DeleteSSOCache(startURL); err != nil {
+		t.Fatalf("DeleteSSOCache() error = %v", err)
+	}
+	if ReadSSOCache(startURL) != nil {
+		t.Error("ReadSSOCache() returned a token after DeleteSSOCache()")
+	}
+
+	// Deleting a missing entry is not an error
+	if err := DeleteSSOCache(startURL); err != nil {
+		t.Errorf("DeleteSSOCache() on missing file error = %v", err)
+	}
+}
+
+func TestListSSOCache(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	if err := WriteSSOCache("https://b.awsapps.com/start", "eu-west-1", "token-b", time.Now().Add(8*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSSOCache("https://a.awsapps.com/start", "us-east-1", "token-a", time.Now().Add(-1*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ssoCacheDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Malformed JSON and a client registration file should both be skipped
+	if err := os.WriteFile(filepath.Join(dir, "malformed.json"), []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	registration := `{"clientId": "abc", "clientSecret": "def", "expiresAt": "2030-01-01T00:00:00Z"}`
+	if err := os.WriteFile(filepath.Join(dir, "botocore-client-id-us-east-1.json"), []byte(registration), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := ListSSOCache()
+	if err != nil {
+		t.Fatalf("ListSSOCache() error = %v", err)
+	}
+
+	if len(tokens) != 2 {
+		t.Fatalf("ListSSOCache() returned %d tokens, want 2", len(tokens))
+	}
+	if tokens[0].StartURL != "https://a.awsapps.com/start" || tokens[1].StartURL != "https://b.awsapps.com/start" {
+		t.Errorf("tokens not sorted by start URL: %q, %q", tokens[0].StartURL, tokens[1].StartURL)
+	}
+	if !tokens[0].ExpiresAt.Before(time.Now()) {
+		t.Error("expired token should be listed with its past expiry")
+	}
+	if tokens[1].Region != "eu-west-1" {
+		t.Errorf("Region = %q, want %q", tokens[1].Region, "eu-west-1")
+	}
+}
+
+func TestListSSOCacheMissingDir(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	tokens, err := ListSSOCache()
+	if err != nil {
+		t.Fatalf("ListSSOCache() error = %v", err)
+	}
+	if len(tokens) != 0 {
+		t.Errorf("ListSSOCache() returned %d tokens, want 0", len(tokens))
+	}
+}
+
+func TestCachedSSORegion(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	startURL := "https://region.awsapps.com/start"
+
+	if got := CachedSSORegion(startURL); got != "" {
+		t.Errorf("CachedSSORegion() with no cache = %q, want empty", got)
+	}
+
+	// Region is still returned for an expired token
+	if err := WriteSSOCache(startURL, "ap-southeast-2", "token", time.Now().Add(-1*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := CachedSSORegion(startURL); got != "ap-southeast-2" {
+		t.Errorf("CachedSSORegion() = %q, want %q", got, "ap-southeast-2")
+	}
+}
+
+func TestReadSSOCacheForProfileSharedAccountID(t *testing.T) {
+	tmpHome := t.TempDir()
+	t.Setenv("HOME", tmpHome)
+
+	orgA := profile.SSOProfile{Name: "a-admin", StartURL: "https://org-a.awsapps.com/start", Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin"}
+	orgB := profile.SSOProfile{Name: "b-admin", StartURL: "https://org-b.awsapps.com/start", Region: "eu-west-1", AccountID: "111111111111", RoleName: "Admin"}
+
+	if err := WriteSSOCache(orgA.StartURL, orgA.Region, "token-a", time.Now().Add(8*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteSSOCache(orgB.StartURL, orgB.Region, "token-b", time.Now().Add(8*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	if tok := ReadSSOCacheForProfile(&orgA); tok == nil || tok.AccessToken != "token-a" {
+		t.Errorf("ReadSSOCacheForProfile(orgA) = %+v, want token-a", tok)
+	}
+	if tok := ReadSSOCacheForProfile(&orgB); tok == nil || tok.AccessToken != "token-b" {
+		t.Errorf("ReadSSOCacheForProfile(orgB) = %+v, want token-b", tok)
+	}
+	if tok := ReadSSOCacheForProfile(nil); tok != nil {
+		t.Error("ReadSSOCacheForProfile(nil) should return nil")
+	}
+}
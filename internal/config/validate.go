@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/ini.v1"
+)
+
+// requiredSSOKeys are the keys a saws-managed profile section must have.
+// sso_region is not among them: saws falls back to the region cached for the
+// start URL, or the profile's region, as a login does.
+var requiredSSOKeys = []string{"sso_start_url", "sso_account_id", "sso_role_name"}
+
+// Issue describes a problem found by ValidateConfig.
+type Issue struct {
+	Profile string
+	Problem string
+}
+
+// ValidateConfig checks saws-managed profiles in the AWS config file without
+// modifying it. A section is checked if it carries the saws marker or has any
+// of the required SSO keys. It reports duplicate profile names, missing SSO
+// keys, and profiles that fail SSOProfile.Validate. A missing file has no
+// issues.
+func ValidateConfig() ([]Issue, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	// Keep duplicate sections separate so they can be reported; the normal
+	// loader silently merges them.
+	cfg, err := ini.LoadSources(ini.LoadOptions{
+		AllowNonUniqueSections:  true,
+		SkipUnrecognizableLines: true,
+	}, path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s: %w", path, err)
+	}
+
+	var issues []Issue
+	seen := map[string]bool{}
+	for _, sec := range cfg.Sections() {
+		if sec.Name() != "default" && !strings.HasPrefix(sec.Name(), "profile ") {
+			continue
+		}
+		if !strings.Contains(sec.Comment, sawsMarker) && !hasAnyKey(sec, requiredSSOKeys) {
+			continue
+		}
+
+		name := profileNameFromSection(sec.Name())
+		if seen[name] {
+			issues = append(issues, Issue{Profile: name, Problem: "duplicate profile name"})
+			continue
+		}
+		seen[name] = true
+
+		if session := keyValue(sec, ssoSessionKey); session != "" && ssoSessionSection(cfg, sec) == nil {
+			issues = append(issues, Issue{Profile: name, Problem: fmt.Sprintf("sso-session %q not found", session)})
+			continue
+		}
+
+		var missing []string
+		for _, k := range requiredSSOKeys {
+			if !hasSSOKey(cfg, sec, k) {
+				missing = append(missing, k)
+			}
+		}
+		if len(missing) > 0 {
+			issues = append(issues, Issue{Profile: name, Problem: "missing " + strings.Join(missing, ", ")})
+			continue
+		}
+
+		p := profileFromSection(cfg, sec)
+		if p.SSORegionMissing {
+			if r := CachedSSORegion(p.StartURL); r != "" {
+				p.SSORegion = r
+			}
+			if p.Region == "" {
+				p.Region = p.SSORegion
+			}
+			if p.Region == "" {
+				issues = append(issues, Issue{Profile: name, Problem: "missing sso_region, and no region or cached login supplies one"})
+				continue
+			}
+		}
+		if err := p.Validate(); err != nil {
+			issues = append(issues, Issue{Profile: name, Problem: err.Error()})
+		}
+	}
+	return issues, nil
+}
+
+// hasAnyKey reports whether sec has at least one of keys.
+func hasAnyKey(sec *ini.Section, keys []string) bool {
+	for _, k := range keys {
+		if hasKey(sec, k) {
+			return true
+		}
+	}
+	return false
+}
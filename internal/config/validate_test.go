@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestValidateConfig(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	content := `[profile good]
+sso_start_url = https://test.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 111111111111
+sso_role_name = Admin
+
+[profile bad-account]
+sso_start_url = https://test.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123
+sso_role_name = Admin
+
+[profile partial]
+sso_start_url = https://test.awsapps.com/start
+sso_region = us-east-1
+
+[profile good]
+sso_start_url = https://other.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 222222222222
+sso_role_name = Admin
+
+[profile bad-duration]
+sso_start_url = https://test.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 333333333333
+sso_role_name = Admin
+duration_seconds = 8h
+
+[profile static]
+aws_access_key_id = AKIAEXAMPLE
+
+[sso-session corp]
+sso_start_url = https://test.awsapps.com/start
+sso_region = us-east-1
+`
+	configPath, _ := Path()
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+
+	issues, err := ValidateConfig()
+	if err != nil {
+		t.Fatalf("ValidateConfig() error = %v", err)
+	}
+
+	byProfile := map[string]string{}
+	for _, is := range issues {
+		byProfile[is.Profile] = is.Problem
+	}
+
+	if len(issues) != 4 {
+		t.Errorf("got %d issues, want 4: %+v", len(issues), issues)
+	}
+	if !contains(byProfile["bad-account"], "account ID") {
+		t.Errorf("bad-account issue = %q, want an account ID problem", byProfile["bad-account"])
+	}
+	if !contains(byProfile["partial"], "sso_account_id") || !contains(byProfile["partial"], "sso_role_name") {
+		t.Errorf("partial issue = %q, want missing sso_account_id and sso_role_name", byProfile["partial"])
+	}
+	if !contains(byProfile["bad-duration"], "duration_seconds") {
+		t.Errorf("bad-duration issue = %q, want a duration_seconds problem", byProfile["bad-duration"])
+	}
+	if byProfile["good"] != "duplicate profile name" {
+		t.Errorf("good issue = %q, want duplicate profile name", byProfile["good"])
+	}
+	if _, ok := byProfile["static"]; ok {
+		t.Error("non-SSO profile should not be checked")
+	}
+	if _, ok := byProfile["corp"]; ok {
+		t.Error("sso-session section should not be checked")
+	}
+
+	// Validation is read-only.
+	after, _ := os.ReadFile(configPath)
+	if string(after) != content {
+		t.Error("ValidateConfig() modified the config file")
+	}
+}
+
+func TestValidateConfigMissingFile(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+
+	issues, err := ValidateConfig()
+	if err != nil || len(issues) != 0 {
+		t.Errorf("ValidateConfig() = (%v, %v), want no issues for a missing file", issues, err)
+	}
+}
+
+func TestValidateConfigWithoutSSORegion(t *testing.T) {
+	cleanup := setupTestConfig(t)
+	defer cleanup()
+	t.Setenv("HOME", t.TempDir())
+
+	content := `# managed by saws
+[profile cached]
+sso_start_url = https://cached.awsapps.com/start
+sso_account_id = 111111111111
+sso_role_name = Admin
+
+# managed by saws
+[profile regional]
+sso_start_url = https://regional.awsapps.com/start
+region = eu-west-1
+sso_account_id = 222222222222
+sso_role_name = Admin
+
+# managed by saws
+[profile nowhere]
+sso_start_url = https://nowhere.awsapps.com/start
+sso_account_id = 333333333333
+sso_role_name = Admin
+`
+	configPath, _ := Path()
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	if err := WriteSSOCache("https://cached.awsapps.com/start", "us-east-2", "token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("WriteSSOCache() error = %v", err)
+	}
+
+	issues, err := ValidateConfig()
+	if err != nil {
+		t.Fatalf("ValidateConfig() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].Profile != "nowhere" || !contains(issues[0].Problem, "sso_region") {
+		t.Errorf("ValidateConfig() = %+v, want only nowhere reported for its missing region", issues)
+	}
+}
@@ -0,0 +1,76 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// federationEndpoint is the AWS sign-in federation endpoint. It is a variable
+// so tests can point it at a local server.
+var federationEndpoint = "https://signin.aws.amazon.com/federation"
+
+// consoleIssuer identifies saws to the sign-in page.
+const consoleIssuer = "saws"
+
+// ConsoleDestination returns the console page to land on: destination if
+// set, otherwise the console home for region.
+func ConsoleDestination(destination, region string) string {
+	if destination != "" {
+		return destination
+	}
+	if region == "" {
+		return "https://console.aws.amazon.com/"
+	}
+	return fmt.Sprintf("https://%s.console.aws.amazon.com/console/home?region=%s", region, url.QueryEscape(region))
+}
+
+// ConsoleURL exchanges temporary credentials for a sign-in token and returns
+// a federated console login URL that lands on destination.
+func ConsoleURL(ctx context.Context, client *http.Client, creds *AWSCredentials, destination string) (string, error) {
+	session, err := json.Marshal(map[string]string{
+		"sessionId":    creds.AccessKeyID,
+		"sessionKey":   creds.SecretAccessKey,
+		"sessionToken": creds.SessionToken,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("Action", "getSigninToken")
+	q.Set("Session", string(session))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, federationEndpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get console sign-in token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read console sign-in token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get console sign-in token: %s", resp.Status)
+	}
+	var out struct {
+		SigninToken string
+	}
+	if err := json.Unmarshal(body, &out); err != nil || out.SigninToken == "" {
+		return "", fmt.Errorf("unexpected console sign-in response")
+	}
+
+	q = url.Values{}
+	q.Set("Action", "login")
+	q.Set("Issuer", consoleIssuer)
+	q.Set("Destination", destination)
+	q.Set("SigninToken", out.SigninToken)
+	return federationEndpoint + "?" + q.Encode(), nil
+}
@@ -0,0 +1,82 @@
+package credentials
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/sso/types"
+)
+
+func TestConsoleURLFromFetchedCredentials(t *testing.T) {
+	client := &mockSSOClient{
+		getRoleCredentials: func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+			return &sso.GetRoleCredentialsOutput{
+				RoleCredentials: &types.RoleCredentials{
+					AccessKeyId:     aws.String("AKIAEXAMPLE"),
+					SecretAccessKey: aws.String("secret"),
+					SessionToken:    aws.String("session"),
+					Expiration:      1700000000000,
+				},
+			}, nil
+		},
+	}
+	creds, err := GetCredentials(context.Background(), client, "token", "123456789012", "Admin")
+	if err != nil {
+		t.Fatalf("GetCredentials() error = %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("Action"); got != "getSigninToken" {
+			t.Errorf("Action = %q, want getSigninToken", got)
+		}
+		var session map[string]string
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("Session")), &session); err != nil {
+			t.Fatalf("Session is not JSON: %v", err)
+		}
+		if session["sessionId"] != "AKIAEXAMPLE" || session["sessionKey"] != "secret" || session["sessionToken"] != "session" {
+			t.Errorf("Session = %v, want the fetched credentials", session)
+		}
+		w.Write([]byte(`{"SigninToken":"signin-123"}`))
+	}))
+	defer srv.Close()
+	orig := federationEndpoint
+	federationEndpoint = srv.URL
+	defer func() { federationEndpoint = orig }()
+
+	dest := ConsoleDestination("", "eu-west-1")
+	got, err := ConsoleURL(context.Background(), srv.Client(), creds, dest)
+	if err != nil {
+		t.Fatalf("ConsoleURL() error = %v", err)
+	}
+
+	u, err := url.Parse(got)
+	if err != nil {
+		t.Fatalf("ConsoleURL() returned invalid URL %q: %v", got, err)
+	}
+	if !strings.HasPrefix(got, srv.URL+"?") {
+		t.Errorf("ConsoleURL() = %q, want federation endpoint", got)
+	}
+	q := u.Query()
+	if q.Get("Action") != "login" || q.Get("SigninToken") != "signin-123" {
+		t.Errorf("query = %v, want login with the sign-in token", q)
+	}
+	if q.Get("Destination") != "https://eu-west-1.console.aws.amazon.com/console/home?region=eu-west-1" {
+		t.Errorf("Destination = %q, want eu-west-1 console home", q.Get("Destination"))
+	}
+}
+
+func TestConsoleDestination(t *testing.T) {
+	if got := ConsoleDestination("https://console.aws.amazon.com/s3/", "eu-west-1"); got != "https://console.aws.amazon.com/s3/" {
+		t.Errorf("explicit destination = %q, want it unchanged", got)
+	}
+	if got := ConsoleDestination("", ""); got != "https://console.aws.amazon.com/" {
+		t.Errorf("no region = %q, want global console", got)
+	}
+}
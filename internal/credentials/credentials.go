@@ -3,13 +3,19 @@ package credentials
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/sso"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/smithy-go"
 	"github.com/lvstb/saws/internal/ui"
 )
 
@@ -52,9 +58,20 @@ func NewSSOClient(ctx context.Context, region string) (SSOClient, error) {
 	return NewSSOClientFromConfig(cfg), nil
 }
 
+// Endpoint overrides for the SSO portal API, for regulated environments.
+var (
+	// Endpoint replaces the resolved SSO endpoint (e.g. a VPC endpoint URL).
+	Endpoint string
+	// UseFIPS selects the FIPS endpoint when Endpoint is not set.
+	UseFIPS bool
+	// HTTPClient, if set, replaces the SDK's default HTTP client.
+	HTTPClient aws.HTTPClient
+)
+
 // NewSSOClientFromConfig creates a real SSO client from a pre-loaded AWS config.
 // It configures adaptive retry mode with up to 10 attempts to handle API rate
-// limiting (HTTP 429) when discovering roles across many accounts.
+// limiting (HTTP 429) when discovering roles across many accounts, and
+// applies Endpoint, UseFIPS, and HTTPClient.
 func NewSSOClientFromConfig(cfg aws.Config) SSOClient {
 	return sso.NewFromConfig(cfg, func(o *sso.Options) {
 		o.Retryer = retry.NewAdaptiveMode(func(ao *retry.AdaptiveModeOptions) {
@@ -62,10 +79,21 @@ func NewSSOClientFromConfig(cfg aws.Config) SSOClient {
 				so.MaxAttempts = 10
 			})
 		})
+		if Endpoint != "" {
+			o.BaseEndpoint = aws.String(Endpoint)
+		}
+		if UseFIPS {
+			o.EndpointOptions.UseFIPSEndpoint = aws.FIPSEndpointStateEnabled
+		}
+		if HTTPClient != nil {
+			o.HTTPClient = HTTPClient
+		}
 	})
 }
 
 // GetCredentials fetches temporary AWS credentials for the given account and role.
+// Throttling and server errors are retried a few times with backoff; any
+// other error, including a rejected token, is returned immediately.
 func GetCredentials(
 	ctx context.Context,
 	client SSOClient,
@@ -73,33 +101,310 @@ func GetCredentials(
 	accountID string,
 	roleName string,
 ) (*AWSCredentials, error) {
-	out, err := client.GetRoleCredentials(ctx, &sso.GetRoleCredentialsInput{
-		AccessToken: aws.String(accessToken),
-		AccountId:   aws.String(accountID),
-		RoleName:    aws.String(roleName),
+	out, err := retryTransient(ctx, func() (*sso.GetRoleCredentialsOutput, error) {
+		return client.GetRoleCredentials(ctx, &sso.GetRoleCredentialsInput{
+			AccessToken: aws.String(accessToken),
+			AccountId:   aws.String(accountID),
+			RoleName:    aws.String(roleName),
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get role credentials: %w", err)
 	}
 
-	creds := out.RoleCredentials
-	return &AWSCredentials{
-		AccessKeyID:     aws.ToString(creds.AccessKeyId),
-		SecretAccessKey: aws.ToString(creds.SecretAccessKey),
-		SessionToken:    aws.ToString(creds.SessionToken),
-		Expiration:      time.UnixMilli(creds.Expiration),
-	}, nil
+	if out.RoleCredentials == nil {
+		return nil, fmt.Errorf("SSO returned no role credentials for %s/%s", accountID, roleName)
+	}
+	creds := &AWSCredentials{
+		AccessKeyID:     aws.ToString(out.RoleCredentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(out.RoleCredentials.SecretAccessKey),
+		SessionToken:    aws.ToString(out.RoleCredentials.SessionToken),
+		Expiration:      time.UnixMilli(out.RoleCredentials.Expiration),
+	}
+	if err := validateCredentials(creds); err != nil {
+		return nil, fmt.Errorf("SSO returned invalid credentials for %s/%s: %w", accountID, roleName, err)
+	}
+	return creds, nil
 }
 
-// FormatExportCommands returns shell export commands for the credentials.
-func FormatExportCommands(creds *AWSCredentials, profileName string) string {
-	return fmt.Sprintf(
-		"export AWS_ACCESS_KEY_ID=%s\nexport AWS_SECRET_ACCESS_KEY=%s\nexport AWS_SESSION_TOKEN=%s\nexport AWS_PROFILE=%s",
-		creds.AccessKeyID,
-		creds.SecretAccessKey,
-		creds.SessionToken,
-		profileName,
-	)
+// validateCredentials rejects obviously malformed credentials, so a partial
+// SSO response is never written to ~/.aws/credentials.
+func validateCredentials(creds *AWSCredentials) error {
+	switch {
+	case creds.AccessKeyID == "":
+		return fmt.Errorf("access key ID is empty")
+	case !strings.HasPrefix(creds.AccessKeyID, "ASIA") && !strings.HasPrefix(creds.AccessKeyID, "AKIA"):
+		return fmt.Errorf("access key ID %q does not start with ASIA or AKIA", TruncateToken(creds.AccessKeyID))
+	case creds.SecretAccessKey == "":
+		return fmt.Errorf("secret access key is empty")
+	case creds.SessionToken == "":
+		return fmt.Errorf("session token is empty")
+	}
+	return nil
+}
+
+// ReloginFunc obtains a fresh SSO access token after the current one was rejected.
+type ReloginFunc func(ctx context.Context) (string, error)
+
+// GetCredentialsWithRelogin fetches credentials like GetCredentials, but if SSO
+// rejects the access token it calls relogin once to obtain a fresh token and
+// retries. It never retries more than once, so a token that keeps failing
+// cannot cause a login loop. A nil relogin disables the retry.
+func GetCredentialsWithRelogin(
+	ctx context.Context,
+	client SSOClient,
+	accessToken string,
+	accountID string,
+	roleName string,
+	relogin ReloginFunc,
+) (*AWSCredentials, error) {
+	creds, err := GetCredentials(ctx, client, accessToken, accountID, roleName)
+	if err == nil || relogin == nil || !IsAuthError(err) {
+		return creds, err
+	}
+
+	freshToken, err := relogin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return GetCredentials(ctx, client, freshToken, accountID, roleName)
+}
+
+// IsAuthError reports whether err means SSO rejected the access token
+// (expired, revoked, or otherwise invalid) and a new login is required.
+func IsAuthError(err error) bool {
+	var unauthorized *ssotypes.UnauthorizedException
+	if errors.As(err, &unauthorized) {
+		return true
+	}
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ForbiddenException"
+}
+
+// ExportKeys lists the variables saws can export, in output order.
+var ExportKeys = []string{
+	"AWS_ACCESS_KEY_ID",
+	"AWS_SECRET_ACCESS_KEY",
+	"AWS_SESSION_TOKEN",
+	"AWS_PROFILE",
+	"AWS_REGION",
+	"AWS_DEFAULT_REGION",
+	"AWS_CREDENTIAL_EXPIRATION",
+}
+
+// Variables each output prints, in output order. The shell and dotenv
+// formats carry credentials only; the env file adds region and expiration.
+var (
+	ShellKeys   = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE"}
+	DotenvKeys  = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN"}
+	EnvFileKeys = []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_REGION", "AWS_DEFAULT_REGION", "AWS_CREDENTIAL_EXPIRATION"}
+)
+
+// FormatKeys returns the variables the export format prints, or nil for
+// JSON, which --only does not apply to.
+func FormatKeys(format ExportFormat) []string {
+	switch format {
+	case ExportJSON:
+		return nil
+	case ExportDotenv:
+		return DotenvKeys
+	default:
+		return ShellKeys
+	}
+}
+
+// KeySet restricts export output to some of ExportKeys. A nil KeySet allows
+// every key.
+type KeySet map[string]bool
+
+// Has reports whether key may be exported.
+func (s KeySet) Has(key string) bool {
+	return s == nil || s[key]
+}
+
+// Overlaps reports whether s allows at least one of keys, i.e. whether an
+// output printing keys has anything left to print.
+func (s KeySet) Overlaps(keys []string) bool {
+	return slices.ContainsFunc(keys, s.Has)
+}
+
+// Outside returns the keys of s that are not among keys, in ExportKeys order.
+func (s KeySet) Outside(keys []string) []string {
+	var out []string
+	for _, key := range ExportKeys {
+		if s[key] && !slices.Contains(keys, key) {
+			out = append(out, key)
+		}
+	}
+	return out
+}
+
+// ParseKeySet parses a comma-separated --only value, rejecting keys not in
+// ExportKeys. Empty means every key.
+func ParseKeySet(s string) (KeySet, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	set := KeySet{}
+	for _, key := range strings.Split(s, ",") {
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if !slices.Contains(ExportKeys, key) {
+			return nil, fmt.Errorf("unknown variable %q (known: %s)", key, strings.Join(ExportKeys, ", "))
+		}
+		set[key] = true
+	}
+	return set, nil
+}
+
+// formatVars renders the allowed variables of vars, each through line.
+func formatVars(vars [][2]string, only KeySet, line func(key, value string) string) string {
+	var lines []string
+	for _, v := range vars {
+		if only.Has(v[0]) {
+			lines = append(lines, line(v[0], v[1]))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ExportedProfileVar records the AWS_PROFILE that saws exported, so a later
+// run can tell a profile the user chose from one saws set itself.
+const ExportedProfileVar = "SAWS_EXPORTED_PROFILE"
+
+// FormatExportCommands returns shell export commands for the credentials,
+// limited to the keys in only. With legacyTokenVar, the session token is also
+// exported as AWS_SECURITY_TOKEN for tools from the AWS CLI v1 era.
+// Exporting AWS_PROFILE also exports ExportedProfileVar.
+func FormatExportCommands(creds *AWSCredentials, profileName string, only KeySet, legacyTokenVar bool) string {
+	line := func(key, value string) string {
+		return "export " + key + "=" + value
+	}
+	out := formatVars([][2]string{
+		{"AWS_ACCESS_KEY_ID", creds.AccessKeyID},
+		{"AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", creds.SessionToken},
+		{"AWS_PROFILE", profileName},
+	}, only, line)
+	// AWS_SECURITY_TOKEN is not one of ExportKeys; it follows the session token.
+	if legacyTokenVar && only.Has("AWS_SESSION_TOKEN") {
+		out += "\n" + line("AWS_SECURITY_TOKEN", creds.SessionToken)
+	}
+	if only.Has("AWS_PROFILE") {
+		out += "\n" + line(ExportedProfileVar, profileName)
+	}
+	return out
+}
+
+// ExportFormat selects how credentials are printed in export mode.
+type ExportFormat string
+
+// Supported export formats.
+const (
+	ExportShell  ExportFormat = "shell"
+	ExportJSON   ExportFormat = "json"
+	ExportDotenv ExportFormat = "dotenv"
+)
+
+// ParseExportFormat validates an --export-format value. Empty means shell.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch ExportFormat(s) {
+	case "", ExportShell:
+		return ExportShell, nil
+	case ExportJSON, ExportDotenv:
+		return ExportFormat(s), nil
+	default:
+		return "", fmt.Errorf("unknown export format %q (want shell, json, or dotenv)", s)
+	}
+}
+
+// FormatExport renders credentials in the given format. only limits the
+// variables of the shell and dotenv formats; JSON always has every field.
+// legacyTokenVar applies to the shell format, as in FormatExportCommands.
+// It fails if only leaves nothing to print, rather than printing nothing for
+// an eval to silently accept.
+func FormatExport(creds *AWSCredentials, profileName string, format ExportFormat, only KeySet, legacyTokenVar bool) (string, error) {
+	var out string
+	switch format {
+	case ExportJSON:
+		if only != nil {
+			return "", fmt.Errorf("--only does not apply to the json format")
+		}
+		return FormatJSON(creds)
+	case ExportDotenv:
+		out = FormatDotenv(creds, only)
+	default:
+		out = FormatExportCommands(creds, profileName, only, legacyTokenVar)
+	}
+	if out == "" {
+		return "", fmt.Errorf("--only leaves nothing for the %s format to print (it prints %s)", format, strings.Join(FormatKeys(format), ", "))
+	}
+	return out, nil
+}
+
+// credentialsJSON is the JSON export shape, matching the key names AWS uses
+// for credential_process output.
+type credentialsJSON struct {
+	Version         int    `json:"Version,omitempty"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+// FormatJSON returns the credentials as an indented JSON object.
+func FormatJSON(creds *AWSCredentials) (string, error) {
+	data, err := json.MarshalIndent(credentialsJSON{
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.Expiration.UTC().Format(time.RFC3339),
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// FormatCredentialProcess returns the credentials in the JSON shape AWS
+// tools expect from a credential_process command.
+func FormatCredentialProcess(creds *AWSCredentials) (string, error) {
+	data, err := json.Marshal(credentialsJSON{
+		Version:         1,
+		AccessKeyID:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.Expiration.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// FormatDotenv returns KEY=value lines for .env files and docker --env-file.
+// Values are written bare, since docker does not strip quotes; only values
+// that a dotenv parser would otherwise misread are double-quoted. Only the
+// keys in only are written.
+func FormatDotenv(creds *AWSCredentials, only KeySet) string {
+	return formatVars([][2]string{
+		{"AWS_ACCESS_KEY_ID", creds.AccessKeyID},
+		{"AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", creds.SessionToken},
+	}, only, dotenvLine)
+}
+
+func dotenvLine(key, value string) string {
+	return key + "=" + dotenvValue(value)
+}
+
+// dotenvValue double-quotes v, with escapes, if it contains whitespace,
+// quotes, '#', '$', '`', or a backslash.
+func dotenvValue(v string) string {
+	if !strings.ContainsAny(v, " \t\n\r\"'#$\\`") {
+		return v
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(v) + `"`
 }
 
 // FormatDisplay returns a styled string showing credentials in a readable format.
@@ -107,29 +412,54 @@ func FormatDisplay(creds *AWSCredentials, profileName string) string {
 	content := ui.FormatKeyValue("Profile:          ", profileName) + "\n" +
 		ui.FormatKeyValue("Access Key ID:    ", creds.AccessKeyID) + "\n" +
 		ui.FormatKeyValue("Secret Access Key:", creds.SecretAccessKey) + "\n" +
-		ui.FormatKeyValue("Session Token:    ", truncateToken(creds.SessionToken)) + "\n" +
+		ui.FormatKeyValue("Session Token:    ", TruncateToken(creds.SessionToken)) + "\n" +
 		ui.FormatKeyValue("Expires:          ", creds.Expiration.Format(time.RFC3339))
 
 	return ui.CredentialBoxStyle.Render(content)
 }
 
-// truncateToken shortens a session token for display.
-func truncateToken(token string) string {
+// TruncateToken shortens a token for display, keeping the first and last 20
+// characters. Tokens of 40 characters or fewer are returned unchanged.
+func TruncateToken(token string) string {
 	if len(token) <= 40 {
 		return token
 	}
 	return token[:20] + "..." + token[len(token)-20:]
 }
 
+// SSO ListAccounts and ListAccountRoles accept MaxResults between 1 and 100.
+const (
+	minPageSize = 1
+	maxPageSize = 100
+)
+
+// PageSize is the MaxResults requested per ListAccounts/ListAccountRoles page.
+// Larger pages mean fewer round-trips in big organizations. Values outside
+// the range the API accepts are clamped.
+var PageSize = maxPageSize
+
+// pageSize returns PageSize clamped to the API's allowed range.
+func pageSize() *int32 {
+	n := min(max(PageSize, minPageSize), maxPageSize)
+	return aws.Int32(int32(n))
+}
+
 // ListAccounts discovers all AWS accounts accessible with the given SSO token.
 // It handles pagination automatically, returning all accounts in a single slice.
+// It checks ctx before each page and returns ctx's error once it is done.
 func ListAccounts(ctx context.Context, client SSOClient, accessToken string) ([]DiscoveredAccount, error) {
 	var accounts []DiscoveredAccount
 	var nextToken *string
 
 	for {
+		// Stop between pages once the caller gives up (e.g. a sibling
+		// discovery call failed and cancelled the shared context).
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list accounts: %w", err)
+		}
 		out, err := client.ListAccounts(ctx, &sso.ListAccountsInput{
 			AccessToken: aws.String(accessToken),
+			MaxResults:  pageSize(),
 			NextToken:   nextToken,
 		})
 		if err != nil {
@@ -155,14 +485,19 @@ func ListAccounts(ctx context.Context, client SSOClient, accessToken string) ([]
 
 // ListAccountRoles discovers all roles available for the given account.
 // It handles pagination automatically, returning all roles in a single slice.
+// It checks ctx before each page and returns ctx's error once it is done.
 func ListAccountRoles(ctx context.Context, client SSOClient, accessToken string, accountID string) ([]DiscoveredRole, error) {
 	var roles []DiscoveredRole
 	var nextToken *string
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list account roles: %w", err)
+		}
 		out, err := client.ListAccountRoles(ctx, &sso.ListAccountRolesInput{
 			AccessToken: aws.String(accessToken),
 			AccountId:   aws.String(accountID),
+			MaxResults:  pageSize(),
 			NextToken:   nextToken,
 		})
 		if err != nil {
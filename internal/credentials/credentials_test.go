@@ -2,6 +2,7 @@ package credentials
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
@@ -10,8 +11,14 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sso"
 	"github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/smithy-go"
 )
 
+// unauthorized returns the error SSO gives for a rejected access token.
+func unauthorized(msg string) error {
+	return &types.UnauthorizedException{Message: aws.String(msg)}
+}
+
 // mockSSOClient implements SSOClient for testing.
 type mockSSOClient struct {
 	getRoleCredentials func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error)
@@ -72,7 +79,7 @@ func TestGetCredentials_Success(t *testing.T) {
 func TestGetCredentials_Failure(t *testing.T) {
 	mock := &mockSSOClient{
 		getRoleCredentials: func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
-			return nil, fmt.Errorf("UnauthorizedException: token expired")
+			return nil, unauthorized("token expired")
 		},
 	}
 
@@ -82,6 +89,38 @@ func TestGetCredentials_Failure(t *testing.T) {
 	}
 }
 
+func TestGetCredentials_RejectsInvalidCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		creds   *types.RoleCredentials
+		wantErr string
+	}{
+		{"missing credentials", nil, "no role credentials"},
+		{"empty access key", &types.RoleCredentials{SecretAccessKey: aws.String("secret"), SessionToken: aws.String("token")}, "access key ID is empty"},
+		{"bad access key prefix", &types.RoleCredentials{AccessKeyId: aws.String("garbage"), SecretAccessKey: aws.String("secret"), SessionToken: aws.String("token")}, "does not start with ASIA or AKIA"},
+		{"empty secret", &types.RoleCredentials{AccessKeyId: aws.String("ASIAEXAMPLE"), SessionToken: aws.String("token")}, "secret access key is empty"},
+		{"empty session token", &types.RoleCredentials{AccessKeyId: aws.String("ASIAEXAMPLE"), SecretAccessKey: aws.String("secret")}, "session token is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockSSOClient{
+				getRoleCredentials: func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+					return &sso.GetRoleCredentialsOutput{RoleCredentials: tt.creds}, nil
+				},
+			}
+
+			creds, err := GetCredentials(context.Background(), mock, "test-token", "123456789012", "TestRole")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("GetCredentials() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if creds != nil {
+				t.Errorf("GetCredentials() returned credentials %+v alongside the error", creds)
+			}
+		})
+	}
+}
+
 func TestGetCredentials_PassesCorrectParams(t *testing.T) {
 	var gotToken, gotAccountID, gotRoleName string
 
@@ -125,7 +164,7 @@ func TestFormatExportCommands(t *testing.T) {
 		Expiration:      time.Now().Add(time.Hour),
 	}
 
-	result := FormatExportCommands(creds, "my-profile")
+	result := FormatExportCommands(creds, "my-profile", nil, false)
 
 	expected := []string{
 		"export AWS_ACCESS_KEY_ID=AKIAEXAMPLE",
@@ -141,6 +180,212 @@ func TestFormatExportCommands(t *testing.T) {
 	}
 }
 
+func TestFormatExportCommandsLegacyTokenVar(t *testing.T) {
+	creds := &AWSCredentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "SECRETEXAMPLE",
+		SessionToken:    "TOKENEXAMPLE",
+		Expiration:      time.Now().Add(time.Hour),
+	}
+
+	if result := FormatExportCommands(creds, "my-profile", nil, false); strings.Contains(result, "AWS_SECURITY_TOKEN") {
+		t.Errorf("FormatExportCommands() without legacy var exported AWS_SECURITY_TOKEN:\n%s", result)
+	}
+
+	result := FormatExportCommands(creds, "my-profile", nil, true)
+	if !strings.Contains(result, "export AWS_SECURITY_TOKEN=TOKENEXAMPLE") {
+		t.Errorf("FormatExportCommands() missing AWS_SECURITY_TOKEN equal to the session token\ngot: %s", result)
+	}
+	if !strings.Contains(result, "export AWS_SESSION_TOKEN=TOKENEXAMPLE") {
+		t.Errorf("FormatExportCommands() missing AWS_SESSION_TOKEN\ngot: %s", result)
+	}
+
+	// --only without the session token leaves out its legacy alias too.
+	only := KeySet{"AWS_ACCESS_KEY_ID": true}
+	if result := FormatExportCommands(creds, "my-profile", only, true); strings.Contains(result, "AWS_SECURITY_TOKEN") {
+		t.Errorf("FormatExportCommands() exported AWS_SECURITY_TOKEN without AWS_SESSION_TOKEN:\n%s", result)
+	}
+}
+
+func TestExportOnly(t *testing.T) {
+	creds := &AWSCredentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "SECRETEXAMPLE",
+		SessionToken:    "TOKENEXAMPLE",
+	}
+	only, err := ParseKeySet("aws_access_key_id, AWS_SECRET_ACCESS_KEY")
+	if err != nil {
+		t.Fatalf("ParseKeySet() error = %v", err)
+	}
+
+	shell, err := FormatExport(creds, "p", ExportShell, only, false)
+	if err != nil {
+		t.Fatalf("FormatExport() error = %v", err)
+	}
+	want := "export AWS_ACCESS_KEY_ID=AKIAEXAMPLE\nexport AWS_SECRET_ACCESS_KEY=SECRETEXAMPLE"
+	if shell != want {
+		t.Errorf("shell output =\n%s\nwant\n%s", shell, want)
+	}
+	dotenv, _ := FormatExport(creds, "p", ExportDotenv, only, false)
+	if dotenv != "AWS_ACCESS_KEY_ID=AKIAEXAMPLE\nAWS_SECRET_ACCESS_KEY=SECRETEXAMPLE" {
+		t.Errorf("dotenv output = %q", dotenv)
+	}
+	if _, err := FormatExport(creds, "p", ExportJSON, only, false); err == nil {
+		t.Error("FormatExport(json) with --only should fail")
+	}
+
+	envFile := FormatEnvFile(creds, "eu-west-1", KeySet{"AWS_REGION": true})
+	if envFile != "AWS_REGION=eu-west-1\n" {
+		t.Errorf("FormatEnvFile() = %q, want only AWS_REGION", envFile)
+	}
+
+	if _, err := ParseKeySet("AWS_ACCESS_KEY_ID,AWS_SECRET"); err == nil {
+		t.Error("ParseKeySet() accepted an unknown variable")
+	}
+	if set, err := ParseKeySet(""); set != nil || err != nil {
+		t.Errorf("ParseKeySet(\"\") = %v, %v; want nil, nil", set, err)
+	}
+}
+
+func TestExportOnlyLeavesNothing(t *testing.T) {
+	creds := &AWSCredentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "SECRETEXAMPLE", SessionToken: "TOKENEXAMPLE"}
+
+	if out, err := FormatExport(creds, "p", ExportShell, KeySet{"AWS_REGION": true}, false); err == nil {
+		t.Errorf("FormatExport(shell, AWS_REGION) = %q, want an error instead of empty output", out)
+	}
+	if out, err := FormatExport(creds, "p", ExportDotenv, KeySet{"AWS_PROFILE": true}, false); err == nil {
+		t.Errorf("FormatExport(dotenv, AWS_PROFILE) = %q, want an error instead of empty output", out)
+	}
+
+	only := KeySet{"AWS_PROFILE": true, "AWS_REGION": true}
+	if !only.Overlaps(ShellKeys) || only.Overlaps(DotenvKeys) {
+		t.Errorf("Overlaps() = %v (shell), %v (dotenv); want true, false", only.Overlaps(ShellKeys), only.Overlaps(DotenvKeys))
+	}
+	if got := only.Outside(ShellKeys); len(got) != 1 || got[0] != "AWS_REGION" {
+		t.Errorf("Outside(ShellKeys) = %v, want [AWS_REGION]", got)
+	}
+	if FormatKeys(ExportJSON) != nil {
+		t.Error("FormatKeys(json) should be nil")
+	}
+}
+
+func TestParseExportFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ExportFormat
+		wantErr bool
+	}{
+		{"", ExportShell, false},
+		{"shell", ExportShell, false},
+		{"json", ExportJSON, false},
+		{"dotenv", ExportDotenv, false},
+		{"yaml", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseExportFormat(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseExportFormat(%q) = (%q, %v), want (%q, wantErr %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	creds := &AWSCredentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "SECRET/EXAMPLE+KEY",
+		SessionToken:    "TOKEN=EXAMPLE",
+		Expiration:      time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	got, err := FormatJSON(creds)
+	if err != nil {
+		t.Fatalf("FormatJSON() error = %v", err)
+	}
+	want := `{
+  "AccessKeyId": "AKIAEXAMPLE",
+  "SecretAccessKey": "SECRET/EXAMPLE+KEY",
+  "SessionToken": "TOKEN=EXAMPLE",
+  "Expiration": "2030-01-02T03:04:05Z"
+}`
+	if got != want {
+		t.Errorf("FormatJSON() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatCredentialProcess(t *testing.T) {
+	creds := &AWSCredentials{
+		AccessKeyID:     "ASIAEXAMPLE",
+		SecretAccessKey: "SECRET",
+		SessionToken:    "TOKEN",
+		Expiration:      time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	got, err := FormatCredentialProcess(creds)
+	if err != nil {
+		t.Fatalf("FormatCredentialProcess() error = %v", err)
+	}
+	want := `{"Version":1,"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"SECRET","SessionToken":"TOKEN","Expiration":"2030-01-02T03:04:05Z"}`
+	if got != want {
+		t.Errorf("FormatCredentialProcess() = %s, want %s", got, want)
+	}
+}
+
+func TestFormatDotenv(t *testing.T) {
+	creds := &AWSCredentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "SECRET/EXAMPLE+KEY",
+		SessionToken:    "TOKEN=EXAMPLE==",
+	}
+
+	got := FormatDotenv(creds, nil)
+	want := "AWS_ACCESS_KEY_ID=AKIAEXAMPLE\n" +
+		"AWS_SECRET_ACCESS_KEY=SECRET/EXAMPLE+KEY\n" +
+		"AWS_SESSION_TOKEN=TOKEN=EXAMPLE=="
+	if got != want {
+		t.Errorf("FormatDotenv() =\n%s\nwant\n%s", got, want)
+	}
+	if strings.Contains(got, "export ") {
+		t.Error("dotenv output must not use export")
+	}
+}
+
+func TestDotenvValueQuoting(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain/+=", "plain/+="},
+		{"has space", `"has space"`},
+		{"has#hash", `"has#hash"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{"cost $5", `"cost \$5"`},
+		{`back\slash`, `"back\\slash"`},
+		{"two\nlines", `"two\nlines"`},
+	}
+	for _, tt := range tests {
+		if got := dotenvValue(tt.in); got != tt.want {
+			t.Errorf("dotenvValue(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatExportDispatch(t *testing.T) {
+	creds := &AWSCredentials{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "S", SessionToken: "T"}
+
+	shell, _ := FormatExport(creds, "p", ExportShell, nil, false)
+	if !strings.HasPrefix(shell, "export AWS_ACCESS_KEY_ID=") {
+		t.Errorf("shell format = %q", shell)
+	}
+	dotenv, _ := FormatExport(creds, "p", ExportDotenv, nil, false)
+	if !strings.HasPrefix(dotenv, "AWS_ACCESS_KEY_ID=") {
+		t.Errorf("dotenv format = %q", dotenv)
+	}
+	js, _ := FormatExport(creds, "p", ExportJSON, nil, false)
+	if !strings.HasPrefix(js, "{") {
+		t.Errorf("json format = %q", js)
+	}
+}
+
 func TestFormatDisplay(t *testing.T) {
 	creds := &AWSCredentials{
 		AccessKeyID:     "AKIAEXAMPLE",
@@ -172,9 +417,9 @@ func TestTruncateToken(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := truncateToken(tt.token)
+			got := TruncateToken(tt.token)
 			if got != tt.want {
-				t.Errorf("truncateToken() = %q, want %q", got, tt.want)
+				t.Errorf("TruncateToken() = %q, want %q", got, tt.want)
 			}
 		})
 	}
@@ -251,7 +496,7 @@ func TestListAccounts_Pagination(t *testing.T) {
 func TestListAccounts_Failure(t *testing.T) {
 	mock := &mockSSOClient{
 		listAccounts: func(ctx context.Context, params *sso.ListAccountsInput, optFns ...func(*sso.Options)) (*sso.ListAccountsOutput, error) {
-			return nil, fmt.Errorf("UnauthorizedException: token expired")
+			return nil, unauthorized("token expired")
 		},
 	}
 
@@ -337,6 +582,122 @@ func TestListAccountRoles_Pagination(t *testing.T) {
 	}
 }
 
+func TestListAccountRoles_StopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	callCount := 0
+	mock := &mockSSOClient{
+		listAccountRoles: func(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
+			callCount++
+			// A sibling failure cancels the context while this page is in flight.
+			cancel()
+			return &sso.ListAccountRolesOutput{
+				RoleList:  []types.RoleInfo{{AccountId: aws.String("111111111111"), RoleName: aws.String("Admin")}},
+				NextToken: aws.String("more"),
+			}, nil
+		},
+	}
+
+	_, err := ListAccountRoles(ctx, mock, "test-token", "111111111111")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ListAccountRoles() error = %v, want context.Canceled", err)
+	}
+	if callCount != 1 {
+		t.Errorf("expected pagination to stop after 1 call, got %d", callCount)
+	}
+}
+
+func TestListAccounts_StopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	callCount := 0
+	mock := &mockSSOClient{
+		listAccounts: func(ctx context.Context, params *sso.ListAccountsInput, optFns ...func(*sso.Options)) (*sso.ListAccountsOutput, error) {
+			callCount++
+			cancel()
+			return &sso.ListAccountsOutput{
+				AccountList: []types.AccountInfo{{AccountId: aws.String("111111111111")}},
+				NextToken:   aws.String("more"),
+			}, nil
+		},
+	}
+
+	_, err := ListAccounts(ctx, mock, "test-token")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ListAccounts() error = %v, want context.Canceled", err)
+	}
+	if callCount != 1 {
+		t.Errorf("expected pagination to stop after 1 call, got %d", callCount)
+	}
+}
+
+func TestListAccounts_SetsMaxResults(t *testing.T) {
+	var sizes []int32
+	mock := &mockSSOClient{
+		listAccounts: func(ctx context.Context, params *sso.ListAccountsInput, optFns ...func(*sso.Options)) (*sso.ListAccountsOutput, error) {
+			sizes = append(sizes, aws.ToInt32(params.MaxResults))
+			if params.NextToken == nil {
+				return &sso.ListAccountsOutput{
+					AccountList: []types.AccountInfo{{AccountId: aws.String("111111111111")}},
+					NextToken:   aws.String("page2"),
+				}, nil
+			}
+			return &sso.ListAccountsOutput{
+				AccountList: []types.AccountInfo{{AccountId: aws.String("222222222222")}},
+			}, nil
+		},
+	}
+
+	accounts, err := ListAccounts(context.Background(), mock, "test-token")
+	if err != nil {
+		t.Fatalf("ListAccounts() error = %v", err)
+	}
+	if len(accounts) != 2 {
+		t.Errorf("ListAccounts() returned %d accounts, want 2", len(accounts))
+	}
+	for i, n := range sizes {
+		if n != maxPageSize {
+			t.Errorf("page %d MaxResults = %d, want %d", i, n, maxPageSize)
+		}
+	}
+}
+
+func TestListAccountRoles_SetsMaxResults(t *testing.T) {
+	var got int32
+	mock := &mockSSOClient{
+		listAccountRoles: func(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
+			got = aws.ToInt32(params.MaxResults)
+			return &sso.ListAccountRolesOutput{}, nil
+		},
+	}
+
+	if _, err := ListAccountRoles(context.Background(), mock, "test-token", "111111111111"); err != nil {
+		t.Fatalf("ListAccountRoles() error = %v", err)
+	}
+	if got != maxPageSize {
+		t.Errorf("MaxResults = %d, want %d", got, maxPageSize)
+	}
+}
+
+func TestPageSizeClamped(t *testing.T) {
+	orig := PageSize
+	defer func() { PageSize = orig }()
+
+	tests := []struct {
+		set  int
+		want int32
+	}{
+		{0, 1},
+		{-5, 1},
+		{50, 50},
+		{1000, 100},
+	}
+	for _, tt := range tests {
+		PageSize = tt.set
+		if got := aws.ToInt32(pageSize()); got != tt.want {
+			t.Errorf("PageSize %d: pageSize() = %d, want %d", tt.set, got, tt.want)
+		}
+	}
+}
+
 func TestListAccountRoles_Failure(t *testing.T) {
 	mock := &mockSSOClient{
 		listAccountRoles: func(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
@@ -387,3 +748,119 @@ func TestListAccounts_Empty(t *testing.T) {
 		t.Errorf("ListAccounts() returned %d accounts, want 0", len(accounts))
 	}
 }
+
+func TestGetCredentialsWithRelogin_RetriesWithFreshToken(t *testing.T) {
+	var tokens []string
+	mock := &mockSSOClient{
+		getRoleCredentials: func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+			tokens = append(tokens, aws.ToString(params.AccessToken))
+			if aws.ToString(params.AccessToken) == "stale-token" {
+				return nil, fmt.Errorf("operation error SSO: GetRoleCredentials, %w", unauthorized("Session token not found or invalid"))
+			}
+			return &sso.GetRoleCredentialsOutput{
+				RoleCredentials: &types.RoleCredentials{
+					AccessKeyId:     aws.String("ASIAFRESH"),
+					SecretAccessKey: aws.String("secret"),
+					SessionToken:    aws.String("token"),
+					Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+				},
+			}, nil
+		},
+	}
+
+	reloginCalls := 0
+	relogin := func(ctx context.Context) (string, error) {
+		reloginCalls++
+		return "fresh-token", nil
+	}
+
+	creds, err := GetCredentialsWithRelogin(context.Background(), mock, "stale-token", "123456789012", "TestRole", relogin)
+	if err != nil {
+		t.Fatalf("GetCredentialsWithRelogin() error = %v", err)
+	}
+	if creds.AccessKeyID != "ASIAFRESH" {
+		t.Errorf("AccessKeyID = %q, want %q", creds.AccessKeyID, "ASIAFRESH")
+	}
+	if reloginCalls != 1 {
+		t.Errorf("relogin called %d times, want 1", reloginCalls)
+	}
+	if len(tokens) != 2 || tokens[0] != "stale-token" || tokens[1] != "fresh-token" {
+		t.Errorf("tokens used = %v, want [stale-token fresh-token]", tokens)
+	}
+}
+
+func TestGetCredentialsWithRelogin_RetriesOnlyOnce(t *testing.T) {
+	calls := 0
+	mock := &mockSSOClient{
+		getRoleCredentials: func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+			calls++
+			return nil, unauthorized("token expired")
+		},
+	}
+
+	relogin := func(ctx context.Context) (string, error) { return "fresh-token", nil }
+
+	_, err := GetCredentialsWithRelogin(context.Background(), mock, "stale-token", "123456789012", "TestRole", relogin)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if calls != 2 {
+		t.Errorf("GetRoleCredentials called %d times, want 2", calls)
+	}
+}
+
+func TestGetCredentialsWithRelogin_NonAuthErrorNotRetried(t *testing.T) {
+	mock := &mockSSOClient{
+		getRoleCredentials: func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+			return nil, fmt.Errorf("ResourceNotFoundException: role not found")
+		},
+	}
+
+	relogin := func(ctx context.Context) (string, error) {
+		t.Error("relogin should not be called for non-auth errors")
+		return "", nil
+	}
+
+	if _, err := GetCredentialsWithRelogin(context.Background(), mock, "token", "123456789012", "TestRole", relogin); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestIsAuthError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{unauthorized("expired"), true},
+		{fmt.Errorf("failed to get role credentials: %w", unauthorized("expired")), true},
+		{&smithy.GenericAPIError{Code: "ForbiddenException", Message: "denied"}, true},
+		{&types.TooManyRequestsException{Message: aws.String("slow down")}, false},
+		// Only SDK errors count, not text that merely mentions one.
+		{errors.New("UnauthorizedException: expired"), false},
+	}
+	for _, tt := range tests {
+		if got := IsAuthError(tt.err); got != tt.want {
+			t.Errorf("IsAuthError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNewSSOClientFromConfigEndpoint(t *testing.T) {
+	origEndpoint, origFIPS := Endpoint, UseFIPS
+	defer func() { Endpoint, UseFIPS = origEndpoint, origFIPS }()
+	Endpoint = "https://vpce-123.portal.sso.us-east-1.vpce.amazonaws.com"
+	UseFIPS = true
+
+	client, ok := NewSSOClientFromConfig(aws.Config{Region: "us-east-1"}).(*sso.Client)
+	if !ok {
+		t.Fatal("NewSSOClientFromConfig() did not return an *sso.Client")
+	}
+	opts := client.Options()
+	if got := aws.ToString(opts.BaseEndpoint); got != Endpoint {
+		t.Errorf("BaseEndpoint = %q, want %q", got, Endpoint)
+	}
+	if opts.EndpointOptions.UseFIPSEndpoint != aws.FIPSEndpointStateEnabled {
+		t.Errorf("UseFIPSEndpoint = %v, want enabled", opts.EndpointOptions.UseFIPSEndpoint)
+	}
+}
@@ -0,0 +1,47 @@
+package credentials
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// FormatEnvFile returns the credentials, region, and expiration as KEY=value
+// lines, in the dotenv format, limited to the keys in only. Region lines are
+// omitted when region is empty.
+func FormatEnvFile(creds *AWSCredentials, region string, only KeySet) string {
+	vars := [][2]string{
+		{"AWS_ACCESS_KEY_ID", creds.AccessKeyID},
+		{"AWS_SECRET_ACCESS_KEY", creds.SecretAccessKey},
+		{"AWS_SESSION_TOKEN", creds.SessionToken},
+	}
+	if region != "" {
+		vars = append(vars, [2]string{"AWS_REGION", region}, [2]string{"AWS_DEFAULT_REGION", region})
+	}
+	vars = append(vars, [2]string{"AWS_CREDENTIAL_EXPIRATION", creds.Expiration.UTC().Format(time.RFC3339)})
+
+	out := formatVars(vars, only, dotenvLine)
+	if out == "" {
+		return ""
+	}
+	return out + "\n"
+}
+
+// AppendEnvFile appends FormatEnvFile output to path, creating it with mode
+// 0600 if missing, so it can feed $GITHUB_ENV or a docker --env-file. The
+// lines go out in a single O_APPEND write, so concurrent writers each land
+// whole at the end of the file instead of interleaving.
+func AppendEnvFile(path string, creds *AWSCredentials, region string, only KeySet) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open env file: %w", err)
+	}
+	if _, err := f.WriteString(FormatEnvFile(creds, region, only)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write env file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write env file: %w", err)
+	}
+	return nil
+}
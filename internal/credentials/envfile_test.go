@@ -0,0 +1,79 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppendEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "github_env")
+	if err := os.WriteFile(path, []byte("EXISTING=1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	creds := &AWSCredentials{
+		AccessKeyID:     "ASIAEXAMPLE",
+		SecretAccessKey: "SECRET/EXAMPLE+KEY",
+		SessionToken:    "TOKEN=EXAMPLE==",
+		Expiration:      time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+
+	if err := AppendEnvFile(path, creds, "eu-west-1", nil); err != nil {
+		t.Fatalf("AppendEnvFile() error: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "EXISTING=1\n" +
+		"AWS_ACCESS_KEY_ID=ASIAEXAMPLE\n" +
+		"AWS_SECRET_ACCESS_KEY=SECRET/EXAMPLE+KEY\n" +
+		"AWS_SESSION_TOKEN=TOKEN=EXAMPLE==\n" +
+		"AWS_REGION=eu-west-1\n" +
+		"AWS_DEFAULT_REGION=eu-west-1\n" +
+		"AWS_CREDENTIAL_EXPIRATION=2026-01-02T03:04:05Z\n"
+	if string(data) != want {
+		t.Errorf("env file =\n%s\nwant\n%s", data, want)
+	}
+}
+
+func TestAppendEnvFileCreatesAndAppendsConcurrently(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds.env")
+	creds := &AWSCredentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "token"}
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := AppendEnvFile(path, creds, "", nil); err != nil {
+				t.Errorf("AppendEnvFile() error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("mode = %04o, want 0600", perm)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := FormatEnvFile(creds, "", nil)
+	if got := strings.Repeat(block, writers); string(data) != got {
+		t.Errorf("concurrent appends interleaved:\n%s", data)
+	}
+	if strings.Contains(block, "AWS_REGION") {
+		t.Error("region lines should be omitted without a region")
+	}
+}
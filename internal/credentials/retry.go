@@ -0,0 +1,50 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+)
+
+// retryDelays are the waits between app-level attempts at a transient
+// failure. The SDK retries individual requests already; this covers the
+// brief SSO hiccups that outlast those, such as right after authentication.
+var retryDelays = []time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second}
+
+// throttles recognises the SDK's throttling error codes.
+var throttles = retry.IsErrorThrottles(retry.DefaultThrottles)
+
+// IsTransientError reports whether err is throttling or a server-side (HTTP
+// 5xx) failure that may succeed on retry. Auth errors are never transient, so
+// they still reach the cache-invalidation path immediately.
+func IsTransientError(err error) bool {
+	if err == nil || IsAuthError(err) {
+		return false
+	}
+	if throttles.IsErrorThrottle(err).Bool() {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() >= 500
+}
+
+// retryTransient calls fn until it succeeds, fails with a non-transient error,
+// runs out of retryDelays, or ctx is done.
+func retryTransient[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	v, err := fn()
+	for _, d := range retryDelays {
+		if err == nil || !IsTransientError(err) {
+			return v, err
+		}
+		select {
+		case <-ctx.Done():
+			return v, err
+		case <-time.After(d):
+		}
+		v, err = fn()
+	}
+	return v, err
+}
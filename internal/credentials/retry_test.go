@@ -0,0 +1,125 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/sso/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// noRetryDelay removes backoff waits for the duration of a test.
+func noRetryDelay(t *testing.T) {
+	t.Helper()
+	orig := retryDelays
+	retryDelays = []time.Duration{0, 0, 0}
+	t.Cleanup(func() { retryDelays = orig })
+}
+
+// tooManyRequests returns the error SSO gives when it throttles a call.
+func tooManyRequests() error {
+	return &types.TooManyRequestsException{Message: aws.String("Rate exceeded")}
+}
+
+// responseError returns an SDK HTTP response error with the given status.
+func responseError(status int) error {
+	return &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+		Err:      errors.New("server error"),
+	}}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{fmt.Errorf("operation error SSO: GetRoleCredentials, %w", tooManyRequests()), true},
+		{responseError(503), true},
+		{responseError(500), true},
+		{responseError(404), false},
+		{unauthorized("Session token not found or invalid"), false},
+		{&types.ResourceNotFoundException{Message: aws.String("no such role")}, false},
+		// Only SDK errors count, not text that merely looks like one.
+		{errors.New("https response error StatusCode: 503"), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientError(tt.err); got != tt.want {
+			t.Errorf("IsTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestGetCredentials_RetriesThrottling(t *testing.T) {
+	noRetryDelay(t)
+
+	calls := 0
+	base := &mockSSOClient{}
+	mock := &mockSSOClient{
+		getRoleCredentials: func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+			calls++
+			if calls == 1 {
+				return nil, tooManyRequests()
+			}
+			return base.GetRoleCredentials(ctx, params, optFns...)
+		},
+	}
+
+	creds, err := GetCredentials(context.Background(), mock, "token", "123456789012", "Admin")
+	if err != nil {
+		t.Fatalf("GetCredentials() error = %v", err)
+	}
+	if creds.AccessKeyID == "" {
+		t.Error("GetCredentials() returned empty credentials")
+	}
+	if calls != 2 {
+		t.Errorf("GetRoleCredentials called %d times, want 2", calls)
+	}
+}
+
+func TestGetCredentials_DoesNotRetryAuthErrors(t *testing.T) {
+	noRetryDelay(t)
+
+	calls := 0
+	mock := &mockSSOClient{
+		getRoleCredentials: func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+			calls++
+			return nil, unauthorized("Session token not found or invalid")
+		},
+	}
+
+	_, err := GetCredentials(context.Background(), mock, "token", "123456789012", "Admin")
+	if !IsAuthError(err) {
+		t.Fatalf("GetCredentials() error = %v, want an auth error", err)
+	}
+	if calls != 1 {
+		t.Errorf("GetRoleCredentials called %d times, want 1", calls)
+	}
+}
+
+func TestGetCredentials_GivesUpAfterRetries(t *testing.T) {
+	noRetryDelay(t)
+
+	calls := 0
+	mock := &mockSSOClient{
+		getRoleCredentials: func(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+			calls++
+			return nil, responseError(500)
+		},
+	}
+
+	if _, err := GetCredentials(context.Background(), mock, "token", "123456789012", "Admin"); err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if want := len(retryDelays) + 1; calls != want {
+		t.Errorf("GetRoleCredentials called %d times, want %d", calls, want)
+	}
+}
@@ -0,0 +1,267 @@
+// Package discovery finds every account and role an SSO user can access and
+// turns them into saws profiles.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/lvstb/saws/internal/credentials"
+	"github.com/lvstb/saws/internal/profile"
+)
+
+// roleConcurrency caps parallel ListAccountRoles calls to stay below SSO API
+// rate limits.
+const roleConcurrency = 5
+
+var (
+	// ErrNoAccounts is returned when the SSO user has no accounts assigned.
+	ErrNoAccounts = errors.New("no AWS accounts found for this SSO user")
+
+	// ErrNoRoles is returned when none of the accounts have any roles.
+	ErrNoRoles = errors.New("no roles found across any accounts")
+)
+
+// Options configures Discover.
+type Options struct {
+	// OnAccounts, if set, is called with the full account list before any
+	// MaxAccounts cap and before roles are fetched, so callers can report
+	// progress.
+	OnAccounts func(accounts []credentials.DiscoveredAccount)
+
+	// MaxAccounts caps how many accounts are searched for roles, in the order
+	// SSO returns them. Zero means no limit.
+	MaxAccounts int
+
+	// Concurrency caps parallel ListAccountRoles calls. Zero means the
+	// default of roleConcurrency.
+	Concurrency int
+
+	// NameTemplate, if set, names profiles from a template such as
+	// "{role}.{account_id}" (see profile.RenderProfileName) instead of the
+	// default account-role names.
+	NameTemplate string
+}
+
+// Result holds the profiles built by Discover.
+type Result struct {
+	// Profiles has one entry per account/role pair, in account order, with
+	// unique names already assigned.
+	Profiles []profile.SSOProfile
+
+	// AccountCount is the number of accounts that were searched for roles.
+	AccountCount int
+
+	// EmptyAccounts is how many of the searched accounts had no roles
+	// assigned to the user, and so contributed no profiles.
+	EmptyAccounts int
+
+	// TotalAccounts is the number of accounts SSO returned. It exceeds
+	// AccountCount when MaxAccounts truncated the search.
+	TotalAccounts int
+}
+
+// Truncated reports whether MaxAccounts left some accounts unsearched.
+func (r *Result) Truncated() bool {
+	return r.TotalAccounts > r.AccountCount
+}
+
+// Discover lists every account visible to accessToken, fetches the roles for
+// each in parallel, and returns a profile for every account/role pair.
+func Discover(ctx context.Context, client credentials.SSOClient, accessToken, startURL, region string, opts Options) (*Result, error) {
+	accounts, err := credentials.ListAccounts(ctx, client, accessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover accounts: %w", err)
+	}
+	if len(accounts) == 0 {
+		return nil, ErrNoAccounts
+	}
+	if opts.OnAccounts != nil {
+		opts.OnAccounts(accounts)
+	}
+	total := len(accounts)
+	if opts.MaxAccounts > 0 && total > opts.MaxAccounts {
+		accounts = accounts[:opts.MaxAccounts]
+	}
+
+	roles := make([][]credentials.DiscoveredRole, len(accounts))
+	g, gctx := errgroup.WithContext(ctx)
+	limit := opts.Concurrency
+	if limit <= 0 {
+		limit = roleConcurrency
+	}
+	g.SetLimit(limit)
+
+	for i, acct := range accounts {
+		g.Go(func() error {
+			r, err := credentials.ListAccountRoles(gctx, client, accessToken, acct.AccountID)
+			if err != nil {
+				return fmt.Errorf("failed to discover roles for account %s: %w", acct.AccountID, err)
+			}
+			roles[i] = r
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	var profiles []profile.SSOProfile
+	empty := 0
+	for i, acct := range accounts {
+		if len(roles[i]) == 0 {
+			empty++
+		}
+		for _, role := range roles[i] {
+			profiles = append(profiles, profile.SSOProfile{
+				StartURL:    startURL,
+				Region:      region,
+				AccountID:   acct.AccountID,
+				AccountName: acct.AccountName,
+				RoleName:    role.RoleName,
+
+				AccountEmail: acct.Email,
+			})
+		}
+	}
+	if len(profiles) == 0 {
+		return nil, ErrNoRoles
+	}
+
+	names := profile.GenerateUniqueProfileNames(profiles)
+	if opts.NameTemplate != "" {
+		names, err = profile.GenerateProfileNames(profiles, opts.NameTemplate)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := range profiles {
+		profiles[i].Name = names[i]
+	}
+
+	return &Result{Profiles: profiles, AccountCount: len(accounts), EmptyAccounts: empty, TotalAccounts: total}, nil
+}
+
+// roleKey identifies an account/role pair independent of profile name.
+type roleKey struct {
+	startURL, accountID, roleName string
+}
+
+func keyOf(p profile.SSOProfile) roleKey {
+	return roleKey{p.StartURL, p.AccountID, p.RoleName}
+}
+
+// NewProfiles returns the discovered profiles whose start URL, account ID,
+// and role are not already among existing, for --merge. Existing names are
+// left alone: a new profile whose name is taken gets a numeric suffix.
+func NewProfiles(discovered, existing []profile.SSOProfile) []profile.SSOProfile {
+	have := make(map[roleKey]bool, len(existing))
+	taken := make(map[string]bool, len(existing)+len(discovered))
+	for _, p := range existing {
+		have[keyOf(p)] = true
+		taken[p.Name] = true
+	}
+
+	var out []profile.SSOProfile
+	for _, p := range discovered {
+		if have[keyOf(p)] {
+			continue
+		}
+		name := p.Name
+		for i := 2; taken[name]; i++ {
+			name = fmt.Sprintf("%s-%d", p.Name, i)
+		}
+		taken[name] = true
+		p.Name = name
+		out = append(out, p)
+	}
+	return out
+}
+
+// Diff classifies account/role pairs for saws diff.
+type Diff struct {
+	// New are discovered pairs with no saved profile.
+	New []profile.SSOProfile
+	// Revoked are saved profiles whose pair was not discovered.
+	Revoked []profile.SSOProfile
+	// Unchanged are saved profiles whose pair was discovered.
+	Unchanged []profile.SSOProfile
+}
+
+// Empty reports whether discovery and the saved profiles agree.
+func (d Diff) Empty() bool {
+	return len(d.New) == 0 && len(d.Revoked) == 0
+}
+
+// Compare matches discovered pairs against saved profiles by start URL,
+// account ID, and role. saved should only hold profiles for the start URLs
+// that were discovered, or they all count as revoked.
+func Compare(discovered, saved []profile.SSOProfile) Diff {
+	found := make(map[roleKey]bool, len(discovered))
+	for _, p := range discovered {
+		found[keyOf(p)] = true
+	}
+
+	var d Diff
+	have := make(map[roleKey]bool, len(saved))
+	for _, p := range saved {
+		have[keyOf(p)] = true
+		if found[keyOf(p)] {
+			d.Unchanged = append(d.Unchanged, p)
+		} else {
+			d.Revoked = append(d.Revoked, p)
+		}
+	}
+	for _, p := range discovered {
+		if !have[keyOf(p)] {
+			d.New = append(d.New, p)
+		}
+	}
+	return d
+}
+
+// RoleExcluded reports whether a role name matches any of the glob patterns
+// (as in path.Match), for the exclude_roles setting.
+func RoleExcluded(roleName string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, roleName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ExcludeRoles returns the profiles whose role name matches none of the
+// patterns.
+func ExcludeRoles(profiles []profile.SSOProfile, patterns []string) []profile.SSOProfile {
+	if len(patterns) == 0 {
+		return profiles
+	}
+	var out []profile.SSOProfile
+	for _, p := range profiles {
+		if !RoleExcluded(p.RoleName, patterns) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// FilterRoles returns the profiles whose role name matches re, for
+// --role-filter. A nil re keeps every profile.
+func FilterRoles(profiles []profile.SSOProfile, re *regexp.Regexp) []profile.SSOProfile {
+	if re == nil {
+		return profiles
+	}
+	var out []profile.SSOProfile
+	for _, p := range profiles {
+		if re.MatchString(p.RoleName) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,365 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/sso/types"
+
+	"github.com/lvstb/saws/internal/credentials"
+	"github.com/lvstb/saws/internal/profile"
+)
+
+// fakeSSOClient serves a fixed set of accounts and roles.
+type fakeSSOClient struct {
+	accounts []types.AccountInfo
+	roles    map[string][]string // account ID -> role names
+	rolesErr error
+
+	mu          sync.Mutex
+	roleLookups []string // account IDs passed to ListAccountRoles
+}
+
+func (f *fakeSSOClient) GetRoleCredentials(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *fakeSSOClient) ListAccounts(ctx context.Context, params *sso.ListAccountsInput, optFns ...func(*sso.Options)) (*sso.ListAccountsOutput, error) {
+	return &sso.ListAccountsOutput{AccountList: f.accounts}, nil
+}
+
+func (f *fakeSSOClient) ListAccountRoles(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
+	id := aws.ToString(params.AccountId)
+	f.mu.Lock()
+	f.roleLookups = append(f.roleLookups, id)
+	f.mu.Unlock()
+
+	if f.rolesErr != nil {
+		return nil, f.rolesErr
+	}
+	var out []types.RoleInfo
+	for _, name := range f.roles[id] {
+		out = append(out, types.RoleInfo{AccountId: aws.String(id), RoleName: aws.String(name)})
+	}
+	return &sso.ListAccountRolesOutput{RoleList: out}, nil
+}
+
+func account(id, name string) types.AccountInfo {
+	return types.AccountInfo{AccountId: aws.String(id), AccountName: aws.String(name)}
+}
+
+const (
+	testStartURL = "https://test.awsapps.com/start"
+	testRegion   = "us-east-1"
+)
+
+func TestDiscoverMultiAccountMultiRole(t *testing.T) {
+	client := &fakeSSOClient{
+		accounts: []types.AccountInfo{
+			account("111111111111", "Production"),
+			account("222222222222", "Staging"),
+		},
+		roles: map[string][]string{
+			"111111111111": {"AdministratorAccess", "ReadOnly"},
+			"222222222222": {"Developer"},
+		},
+	}
+
+	var seen int
+	result, err := Discover(context.Background(), client, "token", testStartURL, testRegion, Options{
+		OnAccounts: func(accounts []credentials.DiscoveredAccount) { seen = len(accounts) },
+	})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	if seen != 2 {
+		t.Errorf("OnAccounts saw %d accounts, want 2", seen)
+	}
+	if result.AccountCount != 2 {
+		t.Errorf("AccountCount = %d, want 2", result.AccountCount)
+	}
+
+	want := []struct{ accountID, accountName, role string }{
+		{"111111111111", "Production", "AdministratorAccess"},
+		{"111111111111", "Production", "ReadOnly"},
+		{"222222222222", "Staging", "Developer"},
+	}
+	if len(result.Profiles) != len(want) {
+		t.Fatalf("got %d profiles, want %d", len(result.Profiles), len(want))
+	}
+
+	names := map[string]bool{}
+	for i, w := range want {
+		p := result.Profiles[i]
+		if p.AccountID != w.accountID || p.AccountName != w.accountName || p.RoleName != w.role {
+			t.Errorf("profile %d = %s/%s/%s, want %s/%s/%s", i, p.AccountID, p.AccountName, p.RoleName, w.accountID, w.accountName, w.role)
+		}
+		if p.StartURL != testStartURL || p.Region != testRegion {
+			t.Errorf("profile %d = (%s, %s), want (%s, %s)", i, p.StartURL, p.Region, testStartURL, testRegion)
+		}
+		if p.Name == "" || names[p.Name] {
+			t.Errorf("profile %d has empty or duplicate name %q", i, p.Name)
+		}
+		names[p.Name] = true
+	}
+}
+
+func TestDiscoverNoAccounts(t *testing.T) {
+	_, err := Discover(context.Background(), &fakeSSOClient{}, "token", testStartURL, testRegion, Options{})
+	if !errors.Is(err, ErrNoAccounts) {
+		t.Errorf("Discover() error = %v, want ErrNoAccounts", err)
+	}
+}
+
+func TestDiscoverNoRoles(t *testing.T) {
+	client := &fakeSSOClient{
+		accounts: []types.AccountInfo{account("111111111111", "Production")},
+	}
+	_, err := Discover(context.Background(), client, "token", testStartURL, testRegion, Options{})
+	if !errors.Is(err, ErrNoRoles) {
+		t.Errorf("Discover() error = %v, want ErrNoRoles", err)
+	}
+}
+
+func TestDiscoverCountsEmptyAccounts(t *testing.T) {
+	client := &fakeSSOClient{
+		accounts: []types.AccountInfo{
+			account("111111111111", "Production"),
+			account("222222222222", "Staging"),
+			account("333333333333", "Sandbox"),
+			account("444444444444", "Audit"),
+		},
+		roles: map[string][]string{
+			"111111111111": {"AdministratorAccess", "ReadOnly"},
+			"333333333333": {"Developer"},
+		},
+	}
+
+	result, err := Discover(context.Background(), client, "token", testStartURL, testRegion, Options{})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if result.AccountCount != 4 {
+		t.Errorf("AccountCount = %d, want 4", result.AccountCount)
+	}
+	if result.EmptyAccounts != 2 {
+		t.Errorf("EmptyAccounts = %d, want 2", result.EmptyAccounts)
+	}
+	if len(result.Profiles) != 3 {
+		t.Errorf("Profiles = %d, want 3", len(result.Profiles))
+	}
+}
+
+func TestDiscoverNameTemplate(t *testing.T) {
+	client := &fakeSSOClient{
+		accounts: []types.AccountInfo{account("111111111111", "Production")},
+		roles:    map[string][]string{"111111111111": {"Admin", "ReadOnly"}},
+	}
+
+	result, err := Discover(context.Background(), client, "token", testStartURL, testRegion, Options{NameTemplate: "{role}.{account_id}"})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	var names []string
+	for _, p := range result.Profiles {
+		names = append(names, p.Name)
+	}
+	if got, want := strings.Join(names, " "), "admin.111111111111 readonly.111111111111"; got != want {
+		t.Errorf("profile names = %q, want %q", got, want)
+	}
+}
+
+func TestDiscoverRoleError(t *testing.T) {
+	client := &fakeSSOClient{
+		accounts: []types.AccountInfo{account("111111111111", "Production")},
+		rolesErr: errors.New("throttled"),
+	}
+	_, err := Discover(context.Background(), client, "token", testStartURL, testRegion, Options{})
+	if err == nil {
+		t.Fatal("expected error when role discovery fails")
+	}
+}
+
+func TestDiscoverMaxAccounts(t *testing.T) {
+	client := &fakeSSOClient{
+		accounts: []types.AccountInfo{
+			account("111111111111", "One"),
+			account("222222222222", "Two"),
+			account("333333333333", "Three"),
+		},
+		roles: map[string][]string{
+			"111111111111": {"Admin"},
+			"222222222222": {"Admin"},
+			"333333333333": {"Admin"},
+		},
+	}
+
+	result, err := Discover(context.Background(), client, "token", testStartURL, testRegion, Options{MaxAccounts: 2})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	if !result.Truncated() {
+		t.Error("Truncated() = false, want true")
+	}
+	if result.AccountCount != 2 || result.TotalAccounts != 3 {
+		t.Errorf("AccountCount/TotalAccounts = %d/%d, want 2/3", result.AccountCount, result.TotalAccounts)
+	}
+	if len(client.roleLookups) != 2 {
+		t.Errorf("ListAccountRoles called for %v, want only the first 2 accounts", client.roleLookups)
+	}
+	for _, p := range result.Profiles {
+		if p.AccountID == "333333333333" {
+			t.Error("profile discovered for an account beyond the cap")
+		}
+	}
+}
+
+func TestDiscoverMaxAccountsNotReached(t *testing.T) {
+	client := &fakeSSOClient{
+		accounts: []types.AccountInfo{account("111111111111", "One")},
+		roles:    map[string][]string{"111111111111": {"Admin"}},
+	}
+
+	result, err := Discover(context.Background(), client, "token", testStartURL, testRegion, Options{MaxAccounts: 5})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+	if result.Truncated() {
+		t.Error("Truncated() = true, want false when under the cap")
+	}
+}
+
+func TestNewProfiles(t *testing.T) {
+	const org = "https://org.awsapps.com/start"
+	existing := []profile.SSOProfile{
+		{Name: "prod-admin", StartURL: org, AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "my-dev", StartURL: org, AccountID: "222222222222", RoleName: "ReadOnly"},
+	}
+	discovered := []profile.SSOProfile{
+		{Name: "prod-admin", StartURL: org, AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "prod-readonly", StartURL: org, AccountID: "111111111111", RoleName: "ReadOnly"},
+		{Name: "dev-readonly", StartURL: org, AccountID: "222222222222", RoleName: "ReadOnly"},
+		// Same account and role, but through another start URL.
+		{Name: "prod-admin", StartURL: "https://other.awsapps.com/start", AccountID: "111111111111", RoleName: "Admin"},
+	}
+
+	got := NewProfiles(discovered, existing)
+
+	var names []string
+	for _, p := range got {
+		names = append(names, p.Name)
+	}
+	if want := "prod-readonly,prod-admin-2"; strings.Join(names, ",") != want {
+		t.Errorf("NewProfiles() names = %v, want %s", names, want)
+	}
+	if got[1].StartURL != "https://other.awsapps.com/start" {
+		t.Errorf("renamed profile StartURL = %q, want the other start URL", got[1].StartURL)
+	}
+}
+
+func TestRoleExcluded(t *testing.T) {
+	patterns := []string{"AWSServiceRoleFor*", "BreakGlass"}
+	tests := []struct {
+		role string
+		want bool
+	}{
+		{"BreakGlass", true},
+		{"BreakGlassAdmin", false},
+		{"AWSServiceRoleForSSO", true},
+		{"AWSServiceRoleFor", true},
+		{"AdministratorAccess", false},
+	}
+	for _, tt := range tests {
+		if got := RoleExcluded(tt.role, patterns); got != tt.want {
+			t.Errorf("RoleExcluded(%q) = %v, want %v", tt.role, got, tt.want)
+		}
+	}
+	if RoleExcluded("BreakGlass", nil) {
+		t.Error("RoleExcluded() with no patterns = true, want false")
+	}
+
+	discovered := []profile.SSOProfile{
+		{Name: "prod-admin", RoleName: "AdministratorAccess"},
+		{Name: "prod-sso", RoleName: "AWSServiceRoleForSSO"},
+	}
+	if got := ExcludeRoles(discovered, patterns); len(got) != 1 || got[0].Name != "prod-admin" {
+		t.Errorf("ExcludeRoles() = %+v, want only prod-admin", got)
+	}
+}
+
+func TestFilterRoles(t *testing.T) {
+	discovered := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", RoleName: "AdministratorAccess"},
+		{Name: "prod-readonly", AccountID: "111111111111", RoleName: "ReadOnlyAccess"},
+		{Name: "dev-admin", AccountID: "222222222222", RoleName: "AdministratorAccess"},
+		{Name: "dev-billing", AccountID: "222222222222", RoleName: "Billing"},
+	}
+
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{"^AdministratorAccess$", "prod-admin,dev-admin"},
+		{"(?i)readonly|billing", "prod-readonly,dev-billing"},
+		{"^PowerUser", ""},
+	}
+	for _, tt := range tests {
+		var names []string
+		for _, p := range FilterRoles(discovered, regexp.MustCompile(tt.pattern)) {
+			names = append(names, p.Name)
+		}
+		if got := strings.Join(names, ","); got != tt.want {
+			t.Errorf("FilterRoles(%q) = %s, want %s", tt.pattern, got, tt.want)
+		}
+	}
+
+	if got := FilterRoles(discovered, nil); len(got) != len(discovered) {
+		t.Errorf("FilterRoles(nil) kept %d profiles, want all %d", len(got), len(discovered))
+	}
+}
+
+func TestCompare(t *testing.T) {
+	saved := []profile.SSOProfile{
+		{Name: "prod-admin", StartURL: testStartURL, AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "prod-readonly", StartURL: testStartURL, AccountID: "111111111111", RoleName: "ReadOnly"},
+		{Name: "old-dev", StartURL: testStartURL, AccountID: "333333333333", RoleName: "Developer"},
+	}
+	discovered := []profile.SSOProfile{
+		{Name: "production-admin", StartURL: testStartURL, AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "production-readonly", StartURL: testStartURL, AccountID: "111111111111", RoleName: "ReadOnly"},
+		{Name: "staging-developer", StartURL: testStartURL, AccountID: "222222222222", RoleName: "Developer"},
+	}
+
+	d := Compare(discovered, saved)
+	names := func(ps []profile.SSOProfile) string {
+		var out []string
+		for _, p := range ps {
+			out = append(out, p.Name)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := names(d.New); got != "staging-developer" {
+		t.Errorf("New = %s, want staging-developer", got)
+	}
+	if got := names(d.Revoked); got != "old-dev" {
+		t.Errorf("Revoked = %s, want old-dev", got)
+	}
+	if got := names(d.Unchanged); got != "prod-admin,prod-readonly" {
+		t.Errorf("Unchanged = %s, want the saved names prod-admin,prod-readonly", got)
+	}
+	if d.Empty() {
+		t.Error("Empty() = true, want false")
+	}
+
+	if same := Compare(discovered[:2], saved[:2]); !same.Empty() || len(same.Unchanged) != 2 {
+		t.Errorf("Compare() of matching sets = %+v, want two unchanged and no differences", same)
+	}
+}
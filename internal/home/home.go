@@ -0,0 +1,37 @@
+// Package home resolves the user's home directory, with fallbacks for
+// minimal containers where $HOME is unset and there is no passwd entry.
+package home
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// ErrUnresolvable is returned when no home directory can be determined.
+var ErrUnresolvable = errors.New("cannot determine home directory: set HOME or SAWS_HOME")
+
+// Dir returns the directory saws treats as home. In order, it uses:
+//
+//  1. SAWS_HOME, for overriding it explicitly
+//  2. the user's home directory ($HOME, or the passwd entry)
+//  3. the directory holding AWS_CONFIG_FILE or AWS_SHARED_CREDENTIALS_FILE,
+//     or its parent when that directory is named .aws
+func Dir() (string, error) {
+	if dir := os.Getenv("SAWS_HOME"); dir != "" {
+		return dir, nil
+	}
+	if dir, err := os.UserHomeDir(); err == nil && dir != "" {
+		return dir, nil
+	}
+	for _, env := range []string{"AWS_CONFIG_FILE", "AWS_SHARED_CREDENTIALS_FILE"} {
+		if file := os.Getenv(env); file != "" {
+			dir := filepath.Dir(file)
+			if filepath.Base(dir) == ".aws" {
+				dir = filepath.Dir(dir)
+			}
+			return dir, nil
+		}
+	}
+	return "", ErrUnresolvable
+}
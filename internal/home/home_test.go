@@ -0,0 +1,63 @@
+package home
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestDir(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "HOME",
+			env:  map[string]string{"HOME": "/home/dev"},
+			want: "/home/dev",
+		},
+		{
+			name: "SAWS_HOME overrides HOME",
+			env:  map[string]string{"HOME": "/home/dev", "SAWS_HOME": "/srv/saws"},
+			want: "/srv/saws",
+		},
+		{
+			name: "AWS_CONFIG_FILE in a .aws directory",
+			env:  map[string]string{"AWS_CONFIG_FILE": "/data/.aws/config"},
+			want: "/data",
+		},
+		{
+			name: "AWS_SHARED_CREDENTIALS_FILE elsewhere",
+			env:  map[string]string{"AWS_SHARED_CREDENTIALS_FILE": "/run/secrets/aws-credentials"},
+			want: "/run/secrets",
+		},
+		{
+			name:    "nothing set",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"HOME", "SAWS_HOME", "AWS_CONFIG_FILE", "AWS_SHARED_CREDENTIALS_FILE"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			got, err := Dir()
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnresolvable) {
+					t.Fatalf("Dir() = %q, %v; want ErrUnresolvable", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Dir() error = %v", err)
+			}
+			if got != filepath.FromSlash(tt.want) {
+				t.Errorf("Dir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package profile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SuggestProfileName generates a profile name from account and role info.
+// It lowercases and joins with a dash, e.g. "production-administratoraccess".
+func SuggestProfileName(accountName, roleName string) string {
+	if accountName == "" {
+		accountName = "aws"
+	}
+	return nameSlug(accountName) + "-" + nameSlug(roleName)
+}
+
+// nameSlug lowercases s and replaces spaces with dashes.
+func nameSlug(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, " ", "-"))
+}
+
+// DefaultNameTemplate is the profile name template matching
+// SuggestProfileName.
+const DefaultNameTemplate = "{account}-{role}"
+
+// namePlaceholder matches a placeholder in a profile name template.
+var namePlaceholder = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// RenderProfileName fills in a profile name template for p. {account} and
+// {role} are lowercased with spaces turned into dashes, as in
+// SuggestProfileName; {account_id} and {region} are used as they are. The
+// result must be a valid profile name.
+func RenderProfileName(template string, p SSOProfile) (string, error) {
+	account := p.AccountName
+	if account == "" {
+		account = "aws"
+	}
+	fields := map[string]string{
+		"{account}":    nameSlug(account),
+		"{role}":       nameSlug(p.RoleName),
+		"{account_id}": p.AccountID,
+		"{region}":     p.Region,
+	}
+
+	var unknown string
+	name := namePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		value, ok := fields[placeholder]
+		if !ok && unknown == "" {
+			unknown = placeholder
+		}
+		return value
+	})
+	if unknown != "" {
+		return "", fmt.Errorf("unknown placeholder %s in name template (known: {account}, {role}, {account_id}, {region})", unknown)
+	}
+	if err := ValidateProfileName(name); err != nil {
+		return "", fmt.Errorf("name template %q gives %q: %w", template, name, err)
+	}
+	return name, nil
+}
+
+// ValidateNameTemplate checks that a profile name template only uses known
+// placeholders and yields a valid profile name.
+func ValidateNameTemplate(template string) error {
+	_, err := RenderProfileName(template, SSOProfile{
+		AccountID: "123456789012", AccountName: "Example", RoleName: "Admin", Region: "us-east-1",
+	})
+	return err
+}
+
+// GenerateProfileNames is GenerateUniqueProfileNames with the base names
+// rendered from a name template.
+func GenerateProfileNames(profiles []SSOProfile, template string) ([]string, error) {
+	baseNames := make([]string, len(profiles))
+	for i, p := range profiles {
+		name, err := RenderProfileName(template, p)
+		if err != nil {
+			return nil, err
+		}
+		baseNames[i] = name
+	}
+	return uniqueNames(baseNames), nil
+}
+
+// GenerateUniqueProfileNames generates unique profile names for a list of profiles.
+// If two profiles would get the same name (e.g. same role across accounts with the
+// same name), it appends a numeric suffix (-2, -3, etc.).
+func GenerateUniqueProfileNames(profiles []SSOProfile) []string {
+	baseNames := make([]string, len(profiles))
+	for i, p := range profiles {
+		baseNames[i] = SuggestProfileName(p.AccountName, p.RoleName)
+	}
+	return uniqueNames(baseNames)
+}
+
+// uniqueNames suffixes repeated base names with -2, -3, etc.
+func uniqueNames(baseNames []string) []string {
+	names := make([]string, len(baseNames))
+	counts := map[string]int{}
+	for _, base := range baseNames {
+		counts[base]++
+	}
+
+	seen := map[string]int{}
+	for i, base := range baseNames {
+		if counts[base] > 1 {
+			seen[base]++
+			if seen[base] == 1 {
+				names[i] = base
+			} else {
+				names[i] = fmt.Sprintf("%s-%d", base, seen[base])
+			}
+		} else {
+			names[i] = base
+		}
+	}
+
+	return names
+}
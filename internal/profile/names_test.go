@@ -0,0 +1,162 @@
+package profile
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSuggestProfileName(t *testing.T) {
+	tests := []struct {
+		name        string
+		accountName string
+		roleName    string
+		want        string
+	}{
+		{"basic", "Production", "AdministratorAccess", "production-administratoraccess"},
+		{"with spaces", "My Account", "Power User", "my-account-power-user"},
+		{"empty account name", "", "Admin", "aws-admin"},
+		{"uppercase", "DEV", "ReadOnly", "dev-readonly"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SuggestProfileName(tt.accountName, tt.roleName)
+			if got != tt.want {
+				t.Errorf("SuggestProfileName(%q, %q) = %q, want %q", tt.accountName, tt.roleName, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateUniqueProfileNames(t *testing.T) {
+	t.Run("no duplicates", func(t *testing.T) {
+		profiles := []SSOProfile{
+			{AccountName: "Production", RoleName: "Admin"},
+			{AccountName: "Staging", RoleName: "Admin"},
+			{AccountName: "Production", RoleName: "ReadOnly"},
+		}
+		names := GenerateUniqueProfileNames(profiles)
+		if len(names) != 3 {
+			t.Fatalf("got %d names, want 3", len(names))
+		}
+		if names[0] != "production-admin" {
+			t.Errorf("names[0] = %q, want %q", names[0], "production-admin")
+		}
+		if names[1] != "staging-admin" {
+			t.Errorf("names[1] = %q, want %q", names[1], "staging-admin")
+		}
+		if names[2] != "production-readonly" {
+			t.Errorf("names[2] = %q, want %q", names[2], "production-readonly")
+		}
+	})
+
+	t.Run("duplicates get suffix", func(t *testing.T) {
+		profiles := []SSOProfile{
+			{AccountName: "Development", RoleName: "Admin"},
+			{AccountName: "Development", RoleName: "Admin"},
+			{AccountName: "Development", RoleName: "Admin"},
+		}
+		names := GenerateUniqueProfileNames(profiles)
+		if len(names) != 3 {
+			t.Fatalf("got %d names, want 3", len(names))
+		}
+		if names[0] != "development-admin" {
+			t.Errorf("names[0] = %q, want %q", names[0], "development-admin")
+		}
+		if names[1] != "development-admin-2" {
+			t.Errorf("names[1] = %q, want %q", names[1], "development-admin-2")
+		}
+		if names[2] != "development-admin-3" {
+			t.Errorf("names[2] = %q, want %q", names[2], "development-admin-3")
+		}
+	})
+
+	t.Run("mixed duplicates and unique", func(t *testing.T) {
+		profiles := []SSOProfile{
+			{AccountName: "Prod", RoleName: "Admin"},
+			{AccountName: "Staging", RoleName: "ReadOnly"},
+			{AccountName: "Prod", RoleName: "Admin"},
+		}
+		names := GenerateUniqueProfileNames(profiles)
+		if names[0] != "prod-admin" {
+			t.Errorf("names[0] = %q, want %q", names[0], "prod-admin")
+		}
+		if names[1] != "staging-readonly" {
+			t.Errorf("names[1] = %q, want %q", names[1], "staging-readonly")
+		}
+		if names[2] != "prod-admin-2" {
+			t.Errorf("names[2] = %q, want %q", names[2], "prod-admin-2")
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		names := GenerateUniqueProfileNames(nil)
+		if len(names) != 0 {
+			t.Errorf("got %d names for nil input, want 0", len(names))
+		}
+	})
+
+	t.Run("single profile", func(t *testing.T) {
+		profiles := []SSOProfile{
+			{AccountName: "Production", RoleName: "Admin"},
+		}
+		names := GenerateUniqueProfileNames(profiles)
+		if len(names) != 1 {
+			t.Fatalf("got %d names, want 1", len(names))
+		}
+		if names[0] != "production-admin" {
+			t.Errorf("names[0] = %q, want %q", names[0], "production-admin")
+		}
+	})
+}
+
+func TestRenderProfileName(t *testing.T) {
+	p := SSOProfile{AccountID: "111111111111", AccountName: "My Account", RoleName: "PowerUser", Region: "eu-west-1"}
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{DefaultNameTemplate, "my-account-poweruser"},
+		{"{role}@{account}", "poweruser@my-account"},
+		{"{account}_{role}", "my-account_poweruser"},
+		{"{role}.{account_id}", "poweruser.111111111111"},
+		{"{account}-{role}-{region}", "my-account-poweruser-eu-west-1"},
+	}
+	for _, tt := range tests {
+		got, err := RenderProfileName(tt.template, p)
+		if err != nil {
+			t.Errorf("RenderProfileName(%q) error = %v", tt.template, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("RenderProfileName(%q) = %q, want %q", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestValidateNameTemplate(t *testing.T) {
+	for _, bad := range []string{"{account}-{team}", "[{role}]", ""} {
+		if err := ValidateNameTemplate(bad); err == nil {
+			t.Errorf("ValidateNameTemplate(%q) = nil, want an error", bad)
+		}
+	}
+	if err := ValidateNameTemplate("{role}.{account_id}"); err != nil {
+		t.Errorf("ValidateNameTemplate() error = %v", err)
+	}
+}
+
+func TestGenerateProfileNamesSuffixesDuplicates(t *testing.T) {
+	profiles := []SSOProfile{
+		{AccountID: "111111111111", AccountName: "Prod", RoleName: "Admin"},
+		{AccountID: "222222222222", AccountName: "Prod", RoleName: "Admin"},
+		{AccountID: "333333333333", AccountName: "Dev", RoleName: "Admin"},
+	}
+	names, err := GenerateProfileNames(profiles, "{role}@{account}")
+	if err != nil {
+		t.Fatalf("GenerateProfileNames() error = %v", err)
+	}
+	want := []string{"admin@prod", "admin@prod-2", "admin@dev"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("GenerateProfileNames() = %v, want %v", names, want)
+	}
+}
@@ -11,12 +11,53 @@ import (
 type SSOProfile struct {
 	Name        string `ini:"-"` // profile name (used as section key)
 	StartURL    string `ini:"sso_start_url"`
-	Region      string `ini:"sso_region"`
+	Region      string `ini:"region"` // operational region for workloads
 	AccountID   string `ini:"sso_account_id"`
 	AccountName string `ini:"sso_account_name"` // human-friendly account alias
 	RoleName    string `ini:"sso_role_name"`
+
+	// AccountEmail is the account's root email address, recorded at
+	// discovery. AWS tools ignore it.
+	AccountEmail string `ini:"sso_account_email"`
+
+	// SSORegion is where the IAM Identity Center instance lives. Empty means
+	// it matches Region.
+	SSORegion string `ini:"sso_region"`
+
+	// SSORegionMissing is set for profiles loaded without an sso_region,
+	// directly or through their sso-session (e.g. a reference that did not
+	// resolve). Region is then only a guess at the SSO region; callers look
+	// it up in the SSO cache first.
+	SSORegionMissing bool `ini:"-"`
+
+	// SSOSession names the [sso-session] block the profile takes its start
+	// URL and SSO region from, if any.
+	SSOSession string `ini:"sso_session"`
+
+	// Note is an optional free-text description shown in the selector and
+	// `saws list`. AWS tools ignore it.
+	Note string `ini:"sso_account_description"`
+
+	// Group is an optional tag, such as an environment or team, that the
+	// selector groups accounts under. AWS tools ignore it.
+	Group string `ini:"sso_account_group"`
+
+	// DurationSeconds is the requested session length. SSO GetRoleCredentials
+	// does not accept a duration, so it only takes effect for role assumption.
+	DurationSeconds int `ini:"duration_seconds"`
 }
 
+// Session duration limits accepted by AWS for role sessions.
+const (
+	MinDurationSeconds = 900
+	MaxDurationSeconds = 43200
+)
+
+// MalformedDurationSeconds is the DurationSeconds of a profile loaded with a
+// duration_seconds that is not a whole number, so validation reports it
+// instead of the value being treated as unset.
+const MalformedDurationSeconds = -1
+
 // AWSRegions is the list of valid AWS regions for selection.
 var AWSRegions = []string{
 	"us-east-1", "us-east-2", "us-west-1", "us-west-2",
@@ -94,6 +135,21 @@ func ValidateRegion(region string) error {
 	return fmt.Errorf("unknown AWS region: %s", region)
 }
 
+// ValidateDurationSeconds checks that a session duration is unset (0) or within
+// the range AWS accepts for role sessions.
+func ValidateDurationSeconds(seconds int) error {
+	if seconds == 0 {
+		return nil
+	}
+	if seconds == MalformedDurationSeconds {
+		return fmt.Errorf("duration_seconds is not a whole number of seconds")
+	}
+	if seconds < MinDurationSeconds || seconds > MaxDurationSeconds {
+		return fmt.Errorf("duration must be between %d and %d seconds, got %d", MinDurationSeconds, MaxDurationSeconds, seconds)
+	}
+	return nil
+}
+
 // Validate checks all fields of the profile.
 func (p *SSOProfile) Validate() error {
 	if err := ValidateProfileName(p.Name); err != nil {
@@ -105,15 +161,32 @@ func (p *SSOProfile) Validate() error {
 	if err := ValidateRegion(p.Region); err != nil {
 		return fmt.Errorf("region: %w", err)
 	}
+	if p.SSORegion != "" {
+		if err := ValidateRegion(p.SSORegion); err != nil {
+			return fmt.Errorf("SSO region: %w", err)
+		}
+	}
 	if err := ValidateAccountID(p.AccountID); err != nil {
 		return fmt.Errorf("account ID: %w", err)
 	}
 	if err := ValidateRoleName(p.RoleName); err != nil {
 		return fmt.Errorf("role name: %w", err)
 	}
+	if err := ValidateDurationSeconds(p.DurationSeconds); err != nil {
+		return fmt.Errorf("duration seconds: %w", err)
+	}
 	return nil
 }
 
+// SSOClientRegion returns the region for OIDC and SSO API calls: SSORegion
+// if set, otherwise Region.
+func (p *SSOProfile) SSOClientRegion() string {
+	if p.SSORegion != "" {
+		return p.SSORegion
+	}
+	return p.Region
+}
+
 // DisplayName returns a formatted string for UI display.
 func (p *SSOProfile) DisplayName() string {
 	if p.AccountName != "" {
@@ -124,11 +197,13 @@ func (p *SSOProfile) DisplayName() string {
 
 // AccountGroup represents an AWS account with one or more SSO roles.
 type AccountGroup struct {
-	AccountID   string
-	AccountName string
-	StartURL    string
-	Region      string
-	Roles       []SSOProfile // all profiles sharing this account
+	AccountID    string
+	AccountName  string
+	AccountEmail string
+	StartURL     string
+	Region       string
+	Group        string       // first non-empty Group among the roles
+	Roles        []SSOProfile // all profiles sharing this account
 }
 
 // DisplayName returns a formatted string for the account group.
@@ -139,10 +214,36 @@ func (g *AccountGroup) DisplayName() string {
 	return g.AccountID
 }
 
+// FindAccountGroup returns the first group whose account ID equals target or
+// whose account name matches it case-insensitively.
+func FindAccountGroup(groups []AccountGroup, target string) (*AccountGroup, bool) {
+	for i := range groups {
+		g := &groups[i]
+		if g.AccountID == target || (g.AccountName != "" && strings.EqualFold(g.AccountName, target)) {
+			return g, true
+		}
+	}
+	return nil, false
+}
+
 // GroupByAccount groups profiles by their SSO start URL + account ID.
 // Profiles with the same start URL and account ID are grouped together,
 // differing only by role name.
 func GroupByAccount(profiles []SSOProfile) []AccountGroup {
+	return groupProfiles(profiles, true)
+}
+
+// GroupByAccountID groups profiles by account ID alone, merging accounts
+// reachable through several start URLs (e.g. linked organizations) into one
+// group. The group's StartURL is that of its first profile; each role keeps
+// its own.
+func GroupByAccountID(profiles []SSOProfile) []AccountGroup {
+	return groupProfiles(profiles, false)
+}
+
+// groupProfiles groups profiles by account ID, and also by start URL if
+// byStartURL is set, preserving first-seen order.
+func groupProfiles(profiles []SSOProfile, byStartURL bool) []AccountGroup {
 	type key struct {
 		startURL  string
 		accountID string
@@ -152,21 +253,32 @@ func GroupByAccount(profiles []SSOProfile) []AccountGroup {
 	groups := map[key]*AccountGroup{}
 
 	for _, p := range profiles {
-		k := key{startURL: p.StartURL, accountID: p.AccountID}
+		k := key{accountID: p.AccountID}
+		if byStartURL {
+			k.startURL = p.StartURL
+		}
 		if g, ok := groups[k]; ok {
 			g.Roles = append(g.Roles, p)
 			// Use the first non-empty account name found
 			if g.AccountName == "" && p.AccountName != "" {
 				g.AccountName = p.AccountName
 			}
+			if g.AccountEmail == "" && p.AccountEmail != "" {
+				g.AccountEmail = p.AccountEmail
+			}
+			if g.Group == "" && p.Group != "" {
+				g.Group = p.Group
+			}
 		} else {
 			order = append(order, k)
 			groups[k] = &AccountGroup{
-				AccountID:   p.AccountID,
-				AccountName: p.AccountName,
-				StartURL:    p.StartURL,
-				Region:      p.Region,
-				Roles:       []SSOProfile{p},
+				AccountID:    p.AccountID,
+				AccountName:  p.AccountName,
+				AccountEmail: p.AccountEmail,
+				StartURL:     p.StartURL,
+				Region:       p.Region,
+				Group:        p.Group,
+				Roles:        []SSOProfile{p},
 			}
 		}
 	}
@@ -177,3 +289,41 @@ func GroupByAccount(profiles []SSOProfile) []AccountGroup {
 	}
 	return result
 }
+
+// UngroupedLabel names the tag group of accounts without a Group.
+const UngroupedLabel = "Ungrouped"
+
+// TagGroup is the set of accounts sharing a Group tag.
+type TagGroup struct {
+	Name     string
+	Accounts []AccountGroup
+}
+
+// GroupByTag sorts account groups under their Group tag, in first-seen
+// order, with untagged accounts last under UngroupedLabel. It returns nil
+// when no account is tagged, so callers can skip the extra level.
+func GroupByTag(groups []AccountGroup) []TagGroup {
+	var tags []TagGroup
+	index := map[string]int{}
+	var ungrouped []AccountGroup
+	for _, g := range groups {
+		if g.Group == "" {
+			ungrouped = append(ungrouped, g)
+			continue
+		}
+		i, ok := index[g.Group]
+		if !ok {
+			i = len(tags)
+			index[g.Group] = i
+			tags = append(tags, TagGroup{Name: g.Group})
+		}
+		tags[i].Accounts = append(tags[i].Accounts, g)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	if len(ungrouped) > 0 {
+		tags = append(tags, TagGroup{Name: UngroupedLabel, Accounts: ungrouped})
+	}
+	return tags
+}
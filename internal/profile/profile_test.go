@@ -1,6 +1,7 @@
 package profile
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -122,6 +123,28 @@ func TestValidateRegion(t *testing.T) {
 	}
 }
 
+func TestValidateDurationSeconds(t *testing.T) {
+	tests := []struct {
+		seconds int
+		wantErr bool
+	}{
+		{0, false},
+		{900, false},
+		{3600, false},
+		{43200, false},
+		{899, true},
+		{43201, true},
+		{-1, true},
+	}
+
+	for _, tt := range tests {
+		err := ValidateDurationSeconds(tt.seconds)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ValidateDurationSeconds(%d) error = %v, wantErr %v", tt.seconds, err, tt.wantErr)
+		}
+	}
+}
+
 func TestSSOProfile_Validate(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -171,6 +194,42 @@ func TestSSOProfile_Validate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "separate SSO region",
+			profile: SSOProfile{
+				Name:      "my-profile",
+				StartURL:  "https://my-org.awsapps.com/start",
+				Region:    "eu-west-1",
+				SSORegion: "us-east-1",
+				AccountID: "123456789012",
+				RoleName:  "AdministratorAccess",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid SSO region",
+			profile: SSOProfile{
+				Name:      "my-profile",
+				StartURL:  "https://my-org.awsapps.com/start",
+				Region:    "eu-west-1",
+				SSORegion: "nowhere-1",
+				AccountID: "123456789012",
+				RoleName:  "AdministratorAccess",
+			},
+			wantErr: true,
+		},
+		{
+			name: "duration out of range",
+			profile: SSOProfile{
+				Name:            "my-profile",
+				StartURL:        "https://my-org.awsapps.com/start",
+				Region:          "us-east-1",
+				AccountID:       "123456789012",
+				RoleName:        "AdministratorAccess",
+				DurationSeconds: 60,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -183,6 +242,17 @@ func TestSSOProfile_Validate(t *testing.T) {
 	}
 }
 
+func TestSSOProfile_SSOClientRegion(t *testing.T) {
+	p := SSOProfile{Region: "eu-west-1"}
+	if got := p.SSOClientRegion(); got != "eu-west-1" {
+		t.Errorf("SSOClientRegion() = %q, want Region fallback eu-west-1", got)
+	}
+	p.SSORegion = "us-east-1"
+	if got := p.SSOClientRegion(); got != "us-east-1" {
+		t.Errorf("SSOClientRegion() = %q, want us-east-1", got)
+	}
+}
+
 func TestSSOProfile_DisplayName(t *testing.T) {
 	t.Run("without account name", func(t *testing.T) {
 		p := SSOProfile{
@@ -263,6 +333,73 @@ func TestGroupByAccount(t *testing.T) {
 	}
 }
 
+func TestGroupByTag(t *testing.T) {
+	profiles := []SSOProfile{
+		{Name: "legacy", AccountID: "999999999999", RoleName: "Admin"},
+		{Name: "plat-dev", AccountID: "111111111111", RoleName: "Admin", Group: "platform"},
+		{Name: "data-prod", AccountID: "222222222222", RoleName: "Admin", Group: "data"},
+		{Name: "plat-prod-ro", AccountID: "333333333333", RoleName: "ReadOnly"},
+		{Name: "plat-prod", AccountID: "333333333333", RoleName: "Admin", Group: "platform"},
+	}
+
+	tags := GroupByTag(GroupByAccount(profiles))
+
+	var got []string
+	for _, tag := range tags {
+		var ids []string
+		for _, g := range tag.Accounts {
+			ids = append(ids, g.AccountID)
+		}
+		got = append(got, tag.Name+":"+strings.Join(ids, ","))
+	}
+	want := []string{"platform:111111111111,333333333333", "data:222222222222", "Ungrouped:999999999999"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("GroupByTag() = %v, want %v", got, want)
+	}
+}
+
+func TestGroupByTagWithoutTags(t *testing.T) {
+	profiles := []SSOProfile{{Name: "dev", AccountID: "111111111111", RoleName: "Admin"}}
+	if tags := GroupByTag(GroupByAccount(profiles)); tags != nil {
+		t.Errorf("GroupByTag() = %+v, want nil when no account is tagged", tags)
+	}
+}
+
+func TestGroupByAccountID(t *testing.T) {
+	profiles := []SSOProfile{
+		{Name: "dev-admin", StartURL: "https://org.awsapps.com/start", Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "prod-admin", StartURL: "https://org.awsapps.com/start", Region: "us-east-1", AccountID: "222222222222", AccountName: "Production", RoleName: "Admin"},
+		{Name: "linked-readonly", StartURL: "https://other.awsapps.com/start", Region: "eu-west-1", AccountID: "111111111111", AccountName: "Development", RoleName: "ReadOnly"},
+	}
+
+	groups := GroupByAccountID(profiles)
+
+	if len(groups) != 2 {
+		t.Fatalf("GroupByAccountID() returned %d groups, want 2", len(groups))
+	}
+
+	merged := groups[0]
+	if merged.AccountID != "111111111111" {
+		t.Errorf("group[0].AccountID = %q, want 111111111111", merged.AccountID)
+	}
+	if merged.AccountName != "Development" {
+		t.Errorf("group[0].AccountName = %q, want Development from the second start URL", merged.AccountName)
+	}
+	if merged.StartURL != "https://org.awsapps.com/start" {
+		t.Errorf("group[0].StartURL = %q, want the first profile's", merged.StartURL)
+	}
+	if len(merged.Roles) != 2 {
+		t.Fatalf("group[0] has %d roles, want 2", len(merged.Roles))
+	}
+	if merged.Roles[1].StartURL != "https://other.awsapps.com/start" {
+		t.Errorf("group[0].Roles[1].StartURL = %q, want its own start URL", merged.Roles[1].StartURL)
+	}
+
+	if groups[1].AccountID != "222222222222" || len(groups[1].Roles) != 1 {
+		t.Errorf("group[1] = %s with %d roles, want 222222222222 with 1", groups[1].AccountID, len(groups[1].Roles))
+	}
+}
+
 func TestGroupByAccountEmpty(t *testing.T) {
 	groups := GroupByAccount(nil)
 	if len(groups) != 0 {
@@ -0,0 +1,226 @@
+// Package settings loads user defaults from ~/.config/saws/config.toml.
+//
+// The file holds simple top-level TOML keys, for example:
+//
+//	region = "eu-west-1"
+//	concurrency = 8
+//	theme = "none"
+//	export_format = "dotenv"
+//	auth_timeout = "10m"
+//	confirm_browser = true
+//	exclude_roles = "AWSServiceRoleFor*, BreakGlass"
+//
+// Values are resolved with the precedence flags > env > file > built-in
+// defaults; this package handles everything below flags.
+package settings
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lvstb/saws/internal/home"
+)
+
+// Themes accepted by the theme setting.
+const (
+	ThemeAuto = "auto" // colors when the terminal supports them
+	ThemeNone = "none" // never use colors
+)
+
+// Settings holds the configurable defaults.
+type Settings struct {
+	// Region pre-selects the region in the SSO connection form when the
+	// start URL has no cached region of its own.
+	Region string
+	// Concurrency caps parallel ListAccountRoles calls during discovery.
+	Concurrency int
+	// Theme is ThemeAuto or ThemeNone.
+	Theme string
+	// ExportFormat is the --export-format used when the flag is not given.
+	ExportFormat string
+	// AuthTimeout bounds how long the device authorization waits for approval.
+	AuthTimeout time.Duration
+	// ConfirmBrowser asks before opening the browser for a login, for
+	// shared or kiosk machines.
+	ConfirmBrowser bool
+	// ExcludeRoles holds glob patterns for role names that discovery never
+	// offers to import.
+	ExcludeRoles []string
+}
+
+// Defaults returns the built-in settings.
+func Defaults() Settings {
+	return Settings{
+		Concurrency: 5,
+		Theme:       ThemeAuto,
+		AuthTimeout: 5 * time.Minute,
+	}
+}
+
+// Path returns the path to the settings file, honouring XDG_CONFIG_HOME.
+func Path() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "saws", "config.toml"), nil
+	}
+	homeDir, err := home.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "saws", "config.toml"), nil
+}
+
+// Load returns the built-in defaults overridden by the settings file and then
+// by SAWS_* environment variables. A missing file is not an error; a
+// malformed one is, so typos don't go unnoticed.
+func Load() (Settings, error) {
+	s := Defaults()
+
+	path, err := Path()
+	if err != nil {
+		return s, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return s, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	if err := s.parse(data); err != nil {
+		return s, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := s.applyEnv(os.Getenv); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// envKeys maps environment variables to the settings keys they override.
+var envKeys = []struct{ env, key string }{
+	{"SAWS_REGION", "region"},
+	{"SAWS_CONCURRENCY", "concurrency"},
+	{"SAWS_THEME", "theme"},
+	{"SAWS_EXPORT_FORMAT", "export_format"},
+	{"SAWS_AUTH_TIMEOUT", "auth_timeout"},
+	{"SAWS_CONFIRM_BROWSER", "confirm_browser"},
+	{"SAWS_EXCLUDE_ROLES", "exclude_roles"},
+}
+
+// applyEnv overrides s with any set SAWS_* variables.
+func (s *Settings) applyEnv(getenv func(string) string) error {
+	for _, k := range envKeys {
+		if v := getenv(k.env); v != "" {
+			if err := s.set(k.key, v); err != nil {
+				return fmt.Errorf("%s: %w", k.env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parse applies the key = value lines in data to s. Blank lines and #
+// comments, including trailing ones, are skipped; string values may be
+// quoted.
+func (s *Settings) parse(data []byte) error {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", n)
+		}
+		key = strings.TrimSpace(key)
+		value, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		if err := s.set(key, value); err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// parseValue returns the value of a key = value line without its trailing
+// comment, unquoting basic ("...") and literal ('...') strings.
+func parseValue(raw string) (string, error) {
+	var value, rest string
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		quoted, err := strconv.QuotedPrefix(raw)
+		if err != nil {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		value, _ = strconv.Unquote(quoted)
+		rest = raw[len(quoted):]
+	case strings.HasPrefix(raw, "'"):
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		value, rest = raw[1:end+1], raw[end+2:]
+	default:
+		value, _, _ = strings.Cut(raw, "#")
+		return strings.TrimSpace(value), nil
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after string", rest)
+	}
+	return value, nil
+}
+
+// set assigns one setting by its file key.
+func (s *Settings) set(key, value string) error {
+	switch key {
+	case "region":
+		s.Region = value
+	case "concurrency":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return fmt.Errorf("concurrency must be a positive integer, got %q", value)
+		}
+		s.Concurrency = n
+	case "theme":
+		if value != ThemeAuto && value != ThemeNone {
+			return fmt.Errorf("theme must be %q or %q, got %q", ThemeAuto, ThemeNone, value)
+		}
+		s.Theme = value
+	case "export_format":
+		s.ExportFormat = value
+	case "auth_timeout":
+		d, err := time.ParseDuration(value)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("auth_timeout must be a positive duration such as 10m, got %q", value)
+		}
+		s.AuthTimeout = d
+	case "confirm_browser":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("confirm_browser must be true or false, got %q", value)
+		}
+		s.ConfirmBrowser = b
+	case "exclude_roles":
+		var patterns []string
+		for _, pattern := range strings.Split(value, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" {
+				continue
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("exclude_roles: invalid pattern %q", pattern)
+			}
+			patterns = append(patterns, pattern)
+		}
+		s.ExcludeRoles = patterns
+	default:
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeSettings writes a settings file under a temporary XDG_CONFIG_HOME.
+func writeSettings(t *testing.T, content string) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	path := filepath.Join(dir, "saws", "config.toml")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(s, Defaults()) {
+		t.Errorf("Load() = %+v, want defaults %+v", s, Defaults())
+	}
+}
+
+func TestLoadPartialFile(t *testing.T) {
+	writeSettings(t, `# only some keys
+region = "eu-west-1"
+
+concurrency = 8
+`)
+
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if s.Region != "eu-west-1" || s.Concurrency != 8 {
+		t.Errorf("Load() = %+v, want region eu-west-1 and concurrency 8 from the file", s)
+	}
+	if s.Theme != ThemeAuto || s.AuthTimeout != 5*time.Minute {
+		t.Errorf("Load() = %+v, want built-in theme and auth timeout", s)
+	}
+}
+
+func TestLoadEnvOverridesFile(t *testing.T) {
+	writeSettings(t, `region = "eu-west-1"
+theme = "none"
+auth_timeout = "10m"
+`)
+	t.Setenv("SAWS_REGION", "us-west-2")
+	t.Setenv("SAWS_AUTH_TIMEOUT", "2m")
+
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if s.Region != "us-west-2" {
+		t.Errorf("Region = %q, want env value us-west-2", s.Region)
+	}
+	if s.AuthTimeout != 2*time.Minute {
+		t.Errorf("AuthTimeout = %v, want env value 2m", s.AuthTimeout)
+	}
+	if s.Theme != ThemeNone {
+		t.Errorf("Theme = %q, want file value none", s.Theme)
+	}
+}
+
+func TestLoadConfirmBrowser(t *testing.T) {
+	writeSettings(t, "confirm_browser = true\n")
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !s.ConfirmBrowser {
+		t.Error("ConfirmBrowser = false, want true from the file")
+	}
+
+	t.Setenv("SAWS_CONFIRM_BROWSER", "false")
+	s, err = Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if s.ConfirmBrowser {
+		t.Error("ConfirmBrowser = true, want env value false")
+	}
+}
+
+func TestLoadExcludeRoles(t *testing.T) {
+	writeSettings(t, `exclude_roles = "AWSServiceRoleFor*, BreakGlass,"`+"\n")
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := []string{"AWSServiceRoleFor*", "BreakGlass"}
+	if !reflect.DeepEqual(s.ExcludeRoles, want) {
+		t.Errorf("ExcludeRoles = %q, want %q", s.ExcludeRoles, want)
+	}
+
+	t.Setenv("SAWS_EXCLUDE_ROLES", "Audit*")
+	if s, err = Load(); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(s.ExcludeRoles, []string{"Audit*"}) {
+		t.Errorf("ExcludeRoles = %q, want the env value", s.ExcludeRoles)
+	}
+}
+
+func TestLoadTrailingComments(t *testing.T) {
+	// The example from the README, verbatim.
+	writeSettings(t, `region = "eu-west-1"      # SAWS_REGION: region pre-selected for new start URLs
+concurrency = 8           # SAWS_CONCURRENCY: parallel role lookups during discovery (default 5)
+theme = "none"            # SAWS_THEME: auto (default) or none to disable colors
+export_format = "dotenv"  # SAWS_EXPORT_FORMAT: default for --export-format (the shell wrapper always uses shell)
+auth_timeout = "10m"      # SAWS_AUTH_TIMEOUT: how long to wait for browser approval (default 5m)
+confirm_browser = true    # SAWS_CONFIRM_BROWSER: ask before opening a browser to log in (shared machines)
+exclude_roles = "Break*"  # SAWS_EXCLUDE_ROLES: comma-separated role name globs discovery never offers (e.g. "AWSServiceRoleFor*, BreakGlass")
+`)
+
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Settings{
+		Region:         "eu-west-1",
+		Concurrency:    8,
+		Theme:          ThemeNone,
+		ExportFormat:   "dotenv",
+		AuthTimeout:    10 * time.Minute,
+		ConfirmBrowser: true,
+		ExcludeRoles:   []string{"Break*"},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("Load() = %+v, want %+v", s, want)
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		raw, want string
+	}{
+		{`8`, "8"},
+		{`8  # comment`, "8"},
+		{`"eu-west-1"`, "eu-west-1"},
+		{`"eu-west-1"  # comment`, "eu-west-1"},
+		{`"a # b"`, "a # b"},
+		{`'C:\path' # literal`, `C:\path`},
+	}
+	for _, tt := range tests {
+		got, err := parseValue(tt.raw)
+		if err != nil || got != tt.want {
+			t.Errorf("parseValue(%q) = %q, %v, want %q", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"unknown key", "colour = \"red\"\n", "unknown setting"},
+		{"missing equals", "region\n", "line 1"},
+		{"bad concurrency", "concurrency = 0\n", "concurrency"},
+		{"bad theme", "theme = \"neon\"\n", "theme"},
+		{"bad confirm_browser", "confirm_browser = maybe\n", "confirm_browser"},
+		{"bad exclude_roles", "exclude_roles = \"Admin[\"\n", "exclude_roles"},
+		{"unterminated string", "region = \"eu-west-1\n", "unterminated"},
+		{"text after string", "region = \"eu-west-1\" x\n", "after string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeSettings(t, tt.content)
+			_, err := Load()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Load() error = %v, want mention of %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package shell
+
+import "fmt"
+
+// CompletionScript generates a completion script for sh. Profile names are
+// not baked in: the script asks `saws __complete profile <partial>` for
+// them each time, so completions never go stale.
+func CompletionScript(sh Shell, binaryPath string) string {
+	switch sh {
+	case Fish:
+		return fishCompletion(binaryPath)
+	case Zsh:
+		return zshCompletion(binaryPath)
+	default:
+		return bashCompletion(binaryPath)
+	}
+}
+
+func bashCompletion(binaryPath string) string {
+	return fmt.Sprintf(`_saws_complete() {
+  local cur="${COMP_WORDS[COMP_CWORD]}"
+  local prev="${COMP_WORDS[COMP_CWORD-1]}"
+  case "$prev" in
+    --profile|-profile|which|note)
+      local IFS=$'\n'
+      COMPREPLY=($("%s" __complete profile "$cur" 2>/dev/null))
+      ;;
+  esac
+}
+complete -F _saws_complete saws
+`, binaryPath)
+}
+
+func zshCompletion(binaryPath string) string {
+	return fmt.Sprintf(`_saws() {
+  case "${words[CURRENT-1]}" in
+    --profile|-profile|which|note)
+      local -a profiles
+      profiles=("${(@f)$("%s" __complete profile "${words[CURRENT]}" 2>/dev/null)}")
+      compadd -U -a profiles
+      ;;
+  esac
+}
+compdef _saws saws
+`, binaryPath)
+}
+
+func fishCompletion(binaryPath string) string {
+	return fmt.Sprintf(`complete -c saws -l profile -x -a '("%[1]s" __complete profile (commandline -ct) 2>/dev/null)'
+complete -c saws -n '__fish_seen_subcommand_from which note' -x -a '("%[1]s" __complete profile (commandline -ct) 2>/dev/null)'
+`, binaryPath)
+}
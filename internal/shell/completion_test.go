@@ -0,0 +1,22 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompletionScript(t *testing.T) {
+	binary := "/usr/local/bin/saws"
+	for _, sh := range []Shell{Bash, Zsh, Fish} {
+		script := CompletionScript(sh, binary)
+		if !strings.Contains(script, binary+`" __complete profile`) {
+			t.Errorf("%s completion does not call __complete profile on %s:\n%s", sh, binary, script)
+		}
+	}
+	if !strings.Contains(CompletionScript(Bash, binary), "complete -F _saws_complete saws") {
+		t.Error("bash completion does not register its function")
+	}
+	if !strings.Contains(CompletionScript(Zsh, binary), "compdef _saws saws") {
+		t.Error("zsh completion does not register its function")
+	}
+}
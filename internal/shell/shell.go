@@ -10,6 +10,8 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+
+	"github.com/lvstb/saws/internal/home"
 )
 
 // Shell represents a supported shell type.
@@ -65,28 +67,59 @@ func DetectShell() (Shell, error) {
 
 // RCFile returns the path to the shell's rc file.
 func RCFile(sh Shell) (string, error) {
-	home, err := os.UserHomeDir()
+	homeDir, err := home.Dir()
 	if err != nil {
-		return "", fmt.Errorf("cannot determine home directory: %w", err)
+		return "", err
 	}
 
 	switch sh {
 	case Bash:
-		// On macOS, bash uses .bash_profile for login shells.
-		// On Linux, .bashrc is more common.
-		if runtime.GOOS == "darwin" {
-			return filepath.Join(home, ".bash_profile"), nil
-		}
-		return filepath.Join(home, ".bashrc"), nil
+		return bashRCFile(homeDir, runtime.GOOS), nil
 	case Zsh:
-		return filepath.Join(home, ".zshrc"), nil
+		return filepath.Join(homeDir, ".zshrc"), nil
 	case Fish:
-		return filepath.Join(home, ".config", "fish", "config.fish"), nil
+		return filepath.Join(homeDir, ".config", "fish", "config.fish"), nil
 	default:
 		return "", fmt.Errorf("unsupported shell: %s", sh)
 	}
 }
 
+// bashRCFile picks the bash rc file. Linux terminals start interactive
+// non-login shells, which read .bashrc. macOS terminals start login shells,
+// which read .bash_profile, so .bashrc is only used there when it exists and
+// .bash_profile sources it; that way both login and non-login shells get
+// the wrapper.
+func bashRCFile(homeDir, goos string) string {
+	bashrc := filepath.Join(homeDir, ".bashrc")
+	profile := filepath.Join(homeDir, ".bash_profile")
+	if goos != "darwin" {
+		return bashrc
+	}
+
+	if _, err := os.Stat(bashrc); err != nil {
+		return profile
+	}
+	content, err := os.ReadFile(profile)
+	if err == nil && sourcesBashrc(string(content)) {
+		return bashrc
+	}
+	return profile
+}
+
+// sourcesBashrc reports whether a .bash_profile loads ~/.bashrc.
+func sourcesBashrc(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") || !strings.Contains(line, ".bashrc") {
+			continue
+		}
+		if strings.Contains(line, "source ") || strings.HasPrefix(line, ". ") || strings.Contains(line, " . ") {
+			return true
+		}
+	}
+	return false
+}
+
 // BinaryPath returns the path to the saws binary.
 // It first checks if the binary is in PATH, then falls back to the current executable.
 func BinaryPath() (string, error) {
@@ -102,12 +135,33 @@ func BinaryPath() (string, error) {
 	return resolved, nil
 }
 
+// passThroughFlags are flags, given anywhere on the command line, that print
+// something other than export commands and can't be combined with --export,
+// so the wrapper runs the binary directly instead of evaling its output.
+var passThroughFlags = []string{"open-console", "json", "credential-process"}
+
+// posixPassThroughPattern returns a case pattern matching any of
+// passThroughFlags in the forms the flag package accepts.
+func posixPassThroughPattern() string {
+	var alts []string
+	for _, f := range passThroughFlags {
+		alts = append(alts, "-"+f, "--"+f, "-"+f+"=*", "--"+f+"=*")
+	}
+	return strings.Join(alts, "|")
+}
+
+// fishPassThroughRegex is posixPassThroughPattern as a regex for string match.
+func fishPassThroughRegex() string {
+	return "^--?(" + strings.Join(passThroughFlags, "|") + ")(=.*)?$"
+}
+
 // WrapperScript generates the shell wrapper function for the given shell.
 // The wrapper:
 //  1. Sets SAWS_WRAPPER=1 so the binary knows it's wrapped
 //  2. Runs the binary with --export and any extra args
 //  3. Evals the output to set env vars in the parent shell
-//  4. Falls through to the real binary for non-credential flows (configure, version, etc.)
+//  4. Falls through to the real binary for subcommands, non-credential flows
+//     (configure, version, etc.), and passThroughFlags
 func WrapperScript(sh Shell, binaryPath string) string {
 	switch sh {
 	case Fish:
@@ -123,13 +177,22 @@ func posixWrapper(binaryPath string) string {
 saws() {
   local SAWS_BIN="%s"
 
-  # Pass-through commands that don't need eval
+  # Pass-through subcommands and flags that don't need eval
   case "$1" in
-    init|--version|--configure|configure)
+    --version|--configure|[!-]*)
       SAWS_WRAPPER=1 "$SAWS_BIN" "$@"
       return $?
       ;;
   esac
+  local arg
+  for arg in "$@"; do
+    case "$arg" in
+      %s)
+        SAWS_WRAPPER=1 "$SAWS_BIN" "$@"
+        return $?
+        ;;
+    esac
+  done
 
   # Single invocation: export commands on stdout, display on stderr
   local export_output
@@ -143,7 +206,7 @@ saws() {
     SAWS_WRAPPER=1 "$SAWS_BIN" "$@"
   fi
 }
-%s`, beginMarker, binaryPath, endMarker)
+%s`, beginMarker, binaryPath, posixPassThroughPattern(), endMarker)
 }
 
 func fishWrapper(binaryPath string) string {
@@ -151,11 +214,14 @@ func fishWrapper(binaryPath string) string {
 function saws
   set -l SAWS_BIN "%s"
 
-  # Pass-through commands that don't need eval
-  switch $argv[1]
-    case init --version --configure configure
-      SAWS_WRAPPER=1 $SAWS_BIN $argv
-      return $status
+  # Pass-through subcommands and flags that don't need eval
+  if contains -- "$argv[1]" --version --configure; or string match -qr '^[^-]' -- "$argv[1]"
+    SAWS_WRAPPER=1 $SAWS_BIN $argv
+    return $status
+  end
+  if string match -qr -- '%s' $argv
+    SAWS_WRAPPER=1 $SAWS_BIN $argv
+    return $status
   end
 
   # Single invocation: export commands on stdout, display on stderr
@@ -169,38 +235,103 @@ function saws
     SAWS_WRAPPER=1 $SAWS_BIN $argv
   end
 end
-%s`, beginMarker, binaryPath, endMarker)
+%s`, beginMarker, binaryPath, fishPassThroughRegex(), endMarker)
 }
 
 // Install adds the saws wrapper function to the shell's rc file.
 // If the block already exists, it replaces it. Otherwise, it appends it.
-func Install(sh Shell, binaryPath string, rcPath string) error {
+// A symlinked rc file is updated at its target and stays a symlink.
+// changed is false when the installed block was already identical, in which
+// case the file is not rewritten.
+func Install(sh Shell, binaryPath string, rcPath string) (changed bool, err error) {
 	wrapper := WrapperScript(sh, binaryPath)
 
+	rcPath, err = resolveRC(rcPath)
+	if err != nil {
+		return false, err
+	}
+
 	// Read existing rc file content (might not exist yet)
 	content, err := os.ReadFile(rcPath)
 	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to read %s: %w", rcPath, err)
+		return false, fmt.Errorf("failed to read %s: %w", rcPath, err)
 	}
 
 	existingContent := string(content)
 	newContent := replaceOrAppendBlock(existingContent, wrapper)
+	if err == nil && newContent == existingContent {
+		return false, nil
+	}
 
 	// Ensure parent directory exists (for fish config)
 	dir := filepath.Dir(rcPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+		return false, fmt.Errorf("failed to create directory %s: %w", dir, err)
 	}
 
 	if err := os.WriteFile(rcPath, []byte(newContent), 0644); err != nil {
-		return fmt.Errorf("failed to write %s: %w", rcPath, err)
+		return false, fmt.Errorf("failed to write %s: %w", rcPath, err)
 	}
 
-	return nil
+	return true, nil
+}
+
+// rcCandidates lists every rc file of sh the wrapper may have been installed
+// into. RCFile picks one of them, but which one depends on the platform and
+// on the files present at install time, so both bash files are checked.
+func rcCandidates(sh Shell) ([]string, error) {
+	homeDir, err := home.Dir()
+	if err != nil {
+		return nil, err
+	}
+
+	switch sh {
+	case Bash:
+		return []string{filepath.Join(homeDir, ".bashrc"), filepath.Join(homeDir, ".bash_profile")}, nil
+	case Zsh:
+		return []string{filepath.Join(homeDir, ".zshrc"), filepath.Join(homeDir, ".zprofile")}, nil
+	case Fish:
+		return []string{filepath.Join(homeDir, ".config", "fish", "config.fish")}, nil
+	default:
+		return nil, fmt.Errorf("unsupported shell: %s", sh)
+	}
 }
 
-// Uninstall removes the saws wrapper function from the shell's rc file.
+// Sync refreshes the wrapper in every rc file of every supported shell that
+// already has one, so an upgrade reaches all of them. It returns the rc
+// files whose block changed.
+func Sync(binaryPath string) ([]string, error) {
+	var updated []string
+	for _, name := range SupportedShells() {
+		sh := Shell(name)
+		rcPaths, err := rcCandidates(sh)
+		if err != nil {
+			return updated, err
+		}
+		for _, rcPath := range rcPaths {
+			if !IsInstalled(rcPath) {
+				continue
+			}
+			changed, err := Install(sh, binaryPath, rcPath)
+			if err != nil {
+				return updated, err
+			}
+			if changed {
+				updated = append(updated, rcPath)
+			}
+		}
+	}
+	return updated, nil
+}
+
+// Uninstall removes the saws wrapper function from the shell's rc file,
+// following a symlinked rc file to its target like Install.
 func Uninstall(rcPath string) error {
+	rcPath, err := resolveRC(rcPath)
+	if err != nil {
+		return err
+	}
+
 	content, err := os.ReadFile(rcPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -217,6 +348,28 @@ func Uninstall(rcPath string) error {
 	return nil
 }
 
+// resolveRC returns the file an rc path refers to. Symlinks, as used by
+// dotfiles managers, are followed so edits land in the link target and the
+// link itself is never replaced by a regular file; that includes a dangling
+// link whose target does not exist yet.
+func resolveRC(rcPath string) (string, error) {
+	info, err := os.Lstat(rcPath)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return rcPath, nil
+	}
+	if target, err := filepath.EvalSymlinks(rcPath); err == nil {
+		return target, nil
+	}
+	target, err := os.Readlink(rcPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot read symlink %s: %w", rcPath, err)
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(rcPath), target)
+	}
+	return target, nil
+}
+
 // IsInstalled checks if the saws wrapper is present in the given rc file.
 func IsInstalled(rcPath string) bool {
 	content, err := os.ReadFile(rcPath)
@@ -2,9 +2,12 @@ package shell
 
 import (
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParseShell(t *testing.T) {
@@ -103,6 +106,9 @@ func TestWrapperScript(t *testing.T) {
 		if !strings.Contains(script, "--export") {
 			t.Error("missing --export flag")
 		}
+		if !strings.Contains(script, "[!-]*)") {
+			t.Error("missing pass-through for subcommands")
+		}
 	})
 
 	t.Run("zsh uses same POSIX syntax as bash", func(t *testing.T) {
@@ -124,6 +130,9 @@ func TestWrapperScript(t *testing.T) {
 		if !strings.Contains(script, "$status") {
 			t.Error("missing fish $status")
 		}
+		if !strings.Contains(script, "string match -qr '^[^-]'") {
+			t.Error("missing pass-through for subcommands")
+		}
 		// Should NOT contain bash syntax
 		if strings.Contains(script, "saws()") {
 			t.Error("fish wrapper should not contain bash function syntax")
@@ -131,13 +140,63 @@ func TestWrapperScript(t *testing.T) {
 	})
 }
 
+// TestWrapperPassThroughFlags runs the POSIX wrapper against a fake binary
+// that logs its arguments, and checks how often and how it is invoked.
+func TestWrapperPassThroughFlags(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not installed")
+	}
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "calls")
+	bin := filepath.Join(dir, "saws")
+	fake := "#!/bin/sh\necho \"$*\" >> " + logPath + "\n"
+	if err := os.WriteFile(bin, []byte(fake), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args string
+		want []string
+	}{
+		{"--profile dev", []string{"--export --profile dev"}},
+		{"--profile dev --open-console", []string{"--profile dev --open-console"}},
+		{"--open-console=true", []string{"--open-console=true"}},
+		{"--profile dev --json", []string{"--profile dev --json"}},
+		{"--profile dev -credential-process", []string{"--profile dev -credential-process"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			os.Remove(logPath)
+			script := WrapperScript(Bash, bin) + "\nsaws " + tt.args + "\n"
+			if out, err := exec.Command(bash, "-c", script).CombinedOutput(); err != nil {
+				t.Fatalf("wrapper error = %v\n%s", err, out)
+			}
+			data, err := os.ReadFile(logPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Split(strings.TrimSpace(string(data)), "\n"); !slices.Equal(got, tt.want) {
+				t.Errorf("binary calls = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	fish := WrapperScript(Fish, bin)
+	for _, f := range []string{"open-console", "json", "credential-process"} {
+		if !strings.Contains(fish, f) {
+			t.Errorf("fish wrapper does not pass --%s through", f)
+		}
+	}
+}
+
 func TestInstallAndUninstall(t *testing.T) {
 	tmpDir := t.TempDir()
 	rcPath := filepath.Join(tmpDir, ".bashrc")
 	binary := "/usr/local/bin/saws"
 
 	// Install into a new file
-	err := Install(Bash, binary, rcPath)
+	_, err := Install(Bash, binary, rcPath)
 	if err != nil {
 		t.Fatalf("Install() error: %v", err)
 	}
@@ -189,6 +248,73 @@ func TestInstallAndUninstall(t *testing.T) {
 	}
 }
 
+func TestInstallThroughSymlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	dotfiles := filepath.Join(tmpDir, "dotfiles")
+	if err := os.MkdirAll(dotfiles, 0755); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(dotfiles, "zshrc")
+	if err := os.WriteFile(target, []byte("export EDITOR=vim\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rcPath := filepath.Join(tmpDir, ".zshrc")
+	if err := os.Symlink("dotfiles/zshrc", rcPath); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	assertSymlink := func(step string) {
+		t.Helper()
+		info, err := os.Lstat(rcPath)
+		if err != nil {
+			t.Fatalf("%s: %v", step, err)
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			t.Fatalf("%s replaced the symlink with a regular file", step)
+		}
+		if link, _ := os.Readlink(rcPath); link != "dotfiles/zshrc" {
+			t.Errorf("%s changed the link to %q", step, link)
+		}
+	}
+
+	if _, err := Install(Zsh, "/usr/local/bin/saws", rcPath); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	assertSymlink("Install")
+	content, _ := os.ReadFile(target)
+	if !strings.Contains(string(content), beginMarker) || !strings.Contains(string(content), "export EDITOR=vim") {
+		t.Errorf("link target after Install = %q, want existing content plus the wrapper", content)
+	}
+
+	if err := Uninstall(rcPath); err != nil {
+		t.Fatalf("Uninstall() error: %v", err)
+	}
+	assertSymlink("Uninstall")
+	content, _ = os.ReadFile(target)
+	if strings.Contains(string(content), beginMarker) {
+		t.Error("link target still contains the wrapper after Uninstall")
+	}
+}
+
+func TestInstallThroughDanglingSymlink(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "dotfiles", "bashrc")
+	rcPath := filepath.Join(tmpDir, ".bashrc")
+	if err := os.Symlink(target, rcPath); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	if _, err := Install(Bash, "/usr/local/bin/saws", rcPath); err != nil {
+		t.Fatalf("Install() error: %v", err)
+	}
+	if info, err := os.Lstat(rcPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("Install() replaced the dangling symlink")
+	}
+	if !IsInstalled(target) {
+		t.Error("wrapper was not written to the link target")
+	}
+}
+
 func TestInstallPreservesExistingContent(t *testing.T) {
 	tmpDir := t.TempDir()
 	rcPath := filepath.Join(tmpDir, ".zshrc")
@@ -199,7 +325,7 @@ func TestInstallPreservesExistingContent(t *testing.T) {
 	os.WriteFile(rcPath, []byte(existing), 0644)
 
 	// Install
-	err := Install(Zsh, binary, rcPath)
+	_, err := Install(Zsh, binary, rcPath)
 	if err != nil {
 		t.Fatalf("Install() error: %v", err)
 	}
@@ -227,13 +353,13 @@ func TestInstallReplacesExistingBlock(t *testing.T) {
 	rcPath := filepath.Join(tmpDir, ".bashrc")
 
 	// Install with one binary path
-	err := Install(Bash, "/old/path/saws", rcPath)
+	_, err := Install(Bash, "/old/path/saws", rcPath)
 	if err != nil {
 		t.Fatalf("first Install() error: %v", err)
 	}
 
 	// Install again with a different binary path
-	err = Install(Bash, "/new/path/saws", rcPath)
+	_, err = Install(Bash, "/new/path/saws", rcPath)
 	if err != nil {
 		t.Fatalf("second Install() error: %v", err)
 	}
@@ -259,12 +385,47 @@ func TestInstallReplacesExistingBlock(t *testing.T) {
 	}
 }
 
+func TestInstallIdenticalIsNoOp(t *testing.T) {
+	rcPath := filepath.Join(t.TempDir(), ".bashrc")
+	binary := "/usr/local/bin/saws"
+
+	changed, err := Install(Bash, binary, rcPath)
+	if err != nil || !changed {
+		t.Fatalf("first Install() = %v, %v; want changed", changed, err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(rcPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+	before, _ := os.ReadFile(rcPath)
+
+	changed, err = Install(Bash, binary, rcPath)
+	if err != nil {
+		t.Fatalf("second Install() error: %v", err)
+	}
+	if changed {
+		t.Error("second Install() with identical inputs reported a change")
+	}
+	after, _ := os.ReadFile(rcPath)
+	if string(after) != string(before) {
+		t.Error("second Install() changed the file contents")
+	}
+	if info, err := os.Stat(rcPath); err != nil || !info.ModTime().Equal(old) {
+		t.Errorf("second Install() touched the file (mtime %v, want %v)", info.ModTime(), old)
+	}
+
+	changed, err = Install(Bash, "/opt/saws/bin/saws", rcPath)
+	if err != nil || !changed {
+		t.Errorf("Install() with a new binary path = %v, %v; want changed", changed, err)
+	}
+}
+
 func TestInstallFishCreatesDirectory(t *testing.T) {
 	tmpDir := t.TempDir()
 	rcPath := filepath.Join(tmpDir, ".config", "fish", "config.fish")
 	binary := "/usr/local/bin/saws"
 
-	err := Install(Fish, binary, rcPath)
+	_, err := Install(Fish, binary, rcPath)
 	if err != nil {
 		t.Fatalf("Install() error: %v", err)
 	}
@@ -385,3 +546,111 @@ func TestRemoveBlock(t *testing.T) {
 		}
 	})
 }
+
+func TestBashRCFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		goos    string
+		bashrc  bool
+		profile string // contents of .bash_profile; "" means absent
+		want    string
+	}{
+		{"linux always uses .bashrc", "linux", false, "", ".bashrc"},
+		{"macOS with neither file", "darwin", false, "", ".bash_profile"},
+		{"macOS with only .bash_profile", "darwin", false, "export PATH=$PATH:/opt/bin\n", ".bash_profile"},
+		{"macOS with only .bashrc", "darwin", true, "", ".bash_profile"},
+		{"macOS .bash_profile not sourcing .bashrc", "darwin", true, "export PATH=$PATH:/opt/bin\n", ".bash_profile"},
+		{"macOS .bash_profile sourcing .bashrc", "darwin", true, "[ -f ~/.bashrc ] && source ~/.bashrc\n", ".bashrc"},
+		{"macOS .bash_profile dot-sourcing .bashrc", "darwin", true, "if [ -f ~/.bashrc ]; then . ~/.bashrc; fi\n", ".bashrc"},
+		{"macOS commented-out source", "darwin", true, "# source ~/.bashrc\n", ".bash_profile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.bashrc {
+				os.WriteFile(filepath.Join(dir, ".bashrc"), []byte("alias ll='ls -l'\n"), 0644)
+			}
+			if tt.profile != "" {
+				os.WriteFile(filepath.Join(dir, ".bash_profile"), []byte(tt.profile), 0644)
+			}
+			if got := bashRCFile(dir, tt.goos); got != filepath.Join(dir, tt.want) {
+				t.Errorf("bashRCFile() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("SAWS_HOME", "")
+
+	bashrc, _ := RCFile(Bash)
+	zshrc, _ := RCFile(Zsh)
+	fishConfig, _ := RCFile(Fish)
+	for _, rc := range []struct {
+		sh   Shell
+		path string
+	}{{Bash, bashrc}, {Zsh, zshrc}} {
+		if _, err := Install(rc.sh, "/old/path/saws", rc.path); err != nil {
+			t.Fatalf("Install(%s) error: %v", rc.sh, err)
+		}
+	}
+
+	updated, err := Sync("/new/path/saws")
+	if err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if strings.Join(updated, ",") != bashrc+","+zshrc {
+		t.Errorf("Sync() updated %v, want %s and %s", updated, bashrc, zshrc)
+	}
+	for _, path := range []string{bashrc, zshrc} {
+		content, _ := os.ReadFile(path)
+		if strings.Contains(string(content), "/old/path/saws") || !strings.Contains(string(content), "/new/path/saws") {
+			t.Errorf("%s was not updated to the new binary:\n%s", path, content)
+		}
+	}
+	if _, err := os.Stat(fishConfig); !os.IsNotExist(err) {
+		t.Error("Sync() installed into a shell that had no wrapper")
+	}
+
+	updated, err = Sync("/new/path/saws")
+	if err != nil || len(updated) != 0 {
+		t.Errorf("second Sync() = %v, %v; want no updates", updated, err)
+	}
+}
+
+func TestSyncChecksEveryRCFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("SAWS_HOME", "")
+
+	// A wrapper in .bash_profile, installed on macOS, must be refreshed even
+	// where RCFile picks .bashrc, and vice versa.
+	bashrc := filepath.Join(dir, ".bashrc")
+	bashProfile := filepath.Join(dir, ".bash_profile")
+	zprofile := filepath.Join(dir, ".zprofile")
+	for _, rc := range []struct {
+		sh   Shell
+		path string
+	}{{Bash, bashrc}, {Bash, bashProfile}, {Zsh, zprofile}} {
+		if _, err := Install(rc.sh, "/old/path/saws", rc.path); err != nil {
+			t.Fatalf("Install(%s, %s) error: %v", rc.sh, rc.path, err)
+		}
+	}
+
+	updated, err := Sync("/new/path/saws")
+	if err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	if want := bashrc + "," + bashProfile + "," + zprofile; strings.Join(updated, ",") != want {
+		t.Errorf("Sync() updated %v, want %s", updated, want)
+	}
+	for _, path := range []string{bashrc, bashProfile, zprofile} {
+		content, _ := os.ReadFile(path)
+		if strings.Contains(string(content), "/old/path/saws") {
+			t.Errorf("%s was not updated to the new binary:\n%s", path, content)
+		}
+	}
+}
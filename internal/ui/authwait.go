@@ -0,0 +1,94 @@
+package ui
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+)
+
+// ErrAuthCanceled is returned by WaitForApproval when the user presses
+// ctrl+c while waiting.
+var ErrAuthCanceled = errors.New("authorization canceled")
+
+// authDoneMsg carries the result of the wait function.
+type authDoneMsg struct{ err error }
+
+// authWaitModel shows a key hint while the device authorization is pending
+// and lets 'o' reopen the verification URL in the browser.
+type authWaitModel struct {
+	ctx      context.Context
+	url      string
+	open     func(url string) error
+	wait     func(ctx context.Context) error
+	status   string
+	err      error
+	done     bool
+	canceled bool
+}
+
+func (m authWaitModel) Init() tea.Cmd {
+	return func() tea.Msg {
+		return authDoneMsg{err: m.wait(m.ctx)}
+	}
+}
+
+func (m authWaitModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case authDoneMsg:
+		m.done = true
+		m.err = msg.err
+		return m, tea.Quit
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			m.canceled = true
+			return m, tea.Quit
+		case "o", "O":
+			if err := m.open(m.url); err != nil {
+				m.status = "Could not open the browser: " + err.Error()
+			} else {
+				m.status = "Opened the browser again"
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m authWaitModel) View() string {
+	if m.done || m.canceled {
+		return ""
+	}
+	view := MutedStyle.Render("  Press o to reopen the browser, ctrl+c to cancel")
+	if m.status != "" {
+		view += "\n" + lipgloss.NewStyle().Foreground(ColorMuted).PaddingLeft(2).Render(m.status)
+	}
+	return view + "\n"
+}
+
+// WaitForApproval runs wait, which polls for the device authorization, while
+// listening for key presses: 'o' calls open with url, so a missed or failed
+// browser tab can be reopened, and ctrl+c cancels. Without a terminal on
+// stdin and Output it just runs wait.
+func WaitForApproval(ctx context.Context, url string, open func(url string) error, wait func(ctx context.Context) error) error {
+	if !term.IsTerminal(os.Stdin.Fd()) || !isInteractiveTerminal(Output) {
+		return wait(ctx)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m := authWaitModel{ctx: ctx, url: url, open: open, wait: wait}
+	finalModel, err := tea.NewProgram(m, tea.WithOutput(Output)).Run()
+	if err != nil {
+		return err
+	}
+	result := finalModel.(authWaitModel)
+	if result.canceled {
+		return ErrAuthCanceled
+	}
+	return result.err
+}
@@ -0,0 +1,80 @@
+package ui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// bigGlyphs is a 5-row block font for the characters that appear in SSO
+// user codes. '#' marks a filled cell.
+var bigGlyphs = map[rune][5]string{
+	'A': {" ### ", "#   #", "#####", "#   #", "#   #"},
+	'B': {"#### ", "#   #", "#### ", "#   #", "#### "},
+	'C': {" ####", "#    ", "#    ", "#    ", " ####"},
+	'D': {"#### ", "#   #", "#   #", "#   #", "#### "},
+	'E': {"#####", "#    ", "#### ", "#    ", "#####"},
+	'F': {"#####", "#    ", "#### ", "#    ", "#    "},
+	'G': {" ####", "#    ", "#  ##", "#   #", " ####"},
+	'H': {"#   #", "#   #", "#####", "#   #", "#   #"},
+	'I': {"#####", "  #  ", "  #  ", "  #  ", "#####"},
+	'J': {"#####", "   # ", "   # ", "#  # ", " ##  "},
+	'K': {"#   #", "#  # ", "###  ", "#  # ", "#   #"},
+	'L': {"#    ", "#    ", "#    ", "#    ", "#####"},
+	'M': {"#   #", "## ##", "# # #", "#   #", "#   #"},
+	'N': {"#   #", "##  #", "# # #", "#  ##", "#   #"},
+	'O': {" ### ", "#   #", "#   #", "#   #", " ### "},
+	'P': {"#### ", "#   #", "#### ", "#    ", "#    "},
+	'Q': {" ### ", "#   #", "# # #", "#  # ", " ## #"},
+	'R': {"#### ", "#   #", "#### ", "#  # ", "#   #"},
+	'S': {" ####", "#    ", " ### ", "    #", "#### "},
+	'T': {"#####", "  #  ", "  #  ", "  #  ", "  #  "},
+	'U': {"#   #", "#   #", "#   #", "#   #", " ### "},
+	'V': {"#   #", "#   #", "#   #", " # # ", "  #  "},
+	'W': {"#   #", "#   #", "# # #", "## ##", "#   #"},
+	'X': {"#   #", " # # ", "  #  ", " # # ", "#   #"},
+	'Y': {"#   #", " # # ", "  #  ", "  #  ", "  #  "},
+	'Z': {"#####", "   # ", "  #  ", " #   ", "#####"},
+	'0': {" ### ", "#  ##", "# # #", "##  #", " ### "},
+	'1': {"  #  ", " ##  ", "  #  ", "  #  ", " ### "},
+	'2': {" ### ", "#   #", "  ## ", " #   ", "#####"},
+	'3': {"#### ", "    #", " ### ", "    #", "#### "},
+	'4': {"#   #", "#   #", "#####", "    #", "    #"},
+	'5': {"#####", "#    ", "#### ", "    #", "#### "},
+	'6': {" ### ", "#    ", "#### ", "#   #", " ### "},
+	'7': {"#####", "    #", "   # ", "  #  ", "  #  "},
+	'8': {" ### ", "#   #", " ### ", "#   #", " ### "},
+	'9': {" ### ", "#   #", " ####", "    #", " ### "},
+	'-': {"     ", "     ", " ### ", "     ", "     "},
+}
+
+var blankGlyph = [5]string{"     ", "     ", "     ", "     ", "     "}
+
+// BigCode renders code as five lines of block characters, so a device
+// authorization user code can be read across a room. Letters are matched
+// case-insensitively; characters without a glyph are left blank.
+func BigCode(code string) string {
+	var rows [5]strings.Builder
+	for i, r := range code {
+		glyph, ok := bigGlyphs[unicode.ToUpper(r)]
+		if !ok {
+			glyph = blankGlyph
+		}
+		for row := range rows {
+			if i > 0 {
+				rows[row].WriteString("  ")
+			}
+			rows[row].WriteString(strings.ReplaceAll(glyph[row], "#", "█"))
+		}
+	}
+
+	lines := make([]string, len(rows))
+	for i := range rows {
+		lines[i] = BigCodeStyle.Render(strings.TrimRight(rows[i].String(), " "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// OutputIsTerminal reports whether Output is an interactive terminal.
+func OutputIsTerminal() bool {
+	return isInteractiveTerminal(Output)
+}
@@ -0,0 +1,144 @@
+package ui
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// diffContext is how many unchanged lines are kept around each change.
+const diffContext = 2
+
+// secretLineRegex matches INI lines holding credential secrets. The access
+// key ID is not secret on its own and stays visible.
+var secretLineRegex = regexp.MustCompile(`^(\s*(?:aws_secret_access_key|aws_session_token|aws_security_token)\s*=\s*)\S.*$`)
+
+// diffLine is one line of a line-based diff: op is ' ', '+' or '-'.
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// Diff renders the line differences between two versions of an INI file,
+// colored when Output is an interactive terminal and as plain +/- lines
+// otherwise. Secret values are masked.
+func Diff(before, after string) string {
+	return FormatDiff(before, after, isInteractiveTerminal(Output))
+}
+
+// FormatDiff renders the line differences between before and after, with
+// diffContext unchanged lines around each change. Added lines are prefixed
+// "+", removed lines "-"; styled adds green and red. Secret values are
+// masked. It returns "" when nothing changed.
+func FormatDiff(before, after string, styled bool) string {
+	lines := diffLines(splitLines(before), splitLines(after))
+
+	show := make([]bool, len(lines))
+	changed := false
+	for i, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		changed = true
+		for j := max(0, i-diffContext); j <= min(len(lines)-1, i+diffContext); j++ {
+			show[j] = true
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	addStyle := lipgloss.NewStyle().Foreground(ColorSuccess)
+	delStyle := lipgloss.NewStyle().Foreground(ColorError)
+
+	var b strings.Builder
+	skipped := false
+	for i, l := range lines {
+		if !show[i] {
+			skipped = true
+			continue
+		}
+		if skipped {
+			b.WriteString(renderDiffLine(MutedStyle, styled, "  ...") + "\n")
+			skipped = false
+		}
+		text := string(l.op) + " " + maskSecret(l.text)
+		switch l.op {
+		case '+':
+			text = renderDiffLine(addStyle, styled, text)
+		case '-':
+			text = renderDiffLine(delStyle, styled, text)
+		default:
+			text = renderDiffLine(MutedStyle, styled, text)
+		}
+		b.WriteString(text + "\n")
+	}
+	if skipped {
+		b.WriteString(renderDiffLine(MutedStyle, styled, "  ...") + "\n")
+	}
+	return b.String()
+}
+
+func renderDiffLine(style lipgloss.Style, styled bool, text string) string {
+	if !styled {
+		return text
+	}
+	return style.Render(text)
+}
+
+// maskSecret replaces the value of a secret INI key with a placeholder.
+func maskSecret(line string) string {
+	return secretLineRegex.ReplaceAllString(line, "${1}<redacted>")
+}
+
+// splitLines splits s into lines, ignoring a trailing newline.
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffLines computes a line diff from the longest common subsequence of a
+// and b. Config files are small, so the quadratic table is fine.
+func diffLines(a, b []string) []diffLine {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{'-', a[i]})
+			i++
+		default:
+			out = append(out, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffLine{'+', b[j]})
+	}
+	return out
+}
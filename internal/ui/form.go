@@ -17,23 +17,16 @@ type SSOConnection struct {
 // RunSSOConnectionForm displays a minimal form asking only for SSO Start URL and Region.
 // This is used for first-time setup / auto-discovery where we authenticate first,
 // then discover accounts and roles via the API.
-func RunSSOConnectionForm(defaults *SSOConnection) (*SSOConnection, error) {
-	var (
-		startURL string
-		region   string
-	)
-
+//
+// The start URL is asked first so that cachedRegion (if non-nil) can look up the
+// region previously used with that URL and pre-select it in the region picker.
+func RunSSOConnectionForm(defaults *SSOConnection, cachedRegion func(startURL string) string) (*SSOConnection, error) {
+	var startURL string
 	if defaults != nil {
 		startURL = defaults.StartURL
-		region = defaults.Region
-	}
-
-	regionOptions := make([]huh.Option[string], len(profile.AWSRegions))
-	for i, r := range profile.AWSRegions {
-		regionOptions[i] = huh.NewOption(r, r)
 	}
 
-	form := huh.NewForm(
+	urlForm := huh.NewForm(
 		huh.NewGroup(
 			huh.NewInput().
 				Title("SSO Start URL").
@@ -41,20 +34,19 @@ func RunSSOConnectionForm(defaults *SSOConnection) (*SSOConnection, error) {
 				Placeholder("https://my-org.awsapps.com/start").
 				Value(&startURL).
 				Validate(profile.ValidateStartURL),
-
-			huh.NewSelect[string]().
-				Title("SSO Region").
-				Description("The AWS region where your SSO instance is configured").
-				Options(regionOptions...).
-				Value(&region).
-				Height(10),
 		).Title("Connect to AWS SSO").
 			Description("Enter your SSO details to discover available accounts and roles"),
 	).WithTheme(sawsTheme()).WithOutput(Output)
 
-	if err := form.Run(); err != nil {
+	if err := urlForm.Run(); err != nil {
 		return nil, fmt.Errorf("form cancelled: %w", err)
 	}
+	startURL = strings.TrimSpace(startURL)
+
+	region, err := SelectRegion(defaultRegion(defaults, startURL, cachedRegion))
+	if err != nil {
+		return nil, err
+	}
 
 	return &SSOConnection{
 		StartURL: startURL,
@@ -62,49 +54,67 @@ func RunSSOConnectionForm(defaults *SSOConnection) (*SSOConnection, error) {
 	}, nil
 }
 
-// SuggestProfileName generates a profile name from account and role info.
-// It lowercases and joins with a dash, e.g. "production-administratoraccess".
-func SuggestProfileName(accountName, roleName string) string {
-	name := accountName
-	if name == "" {
-		name = "aws"
+// SelectRegion asks the user to pick the SSO region, with current
+// pre-selected.
+func SelectRegion(current string) (string, error) {
+	regionOptions := make([]huh.Option[string], len(profile.AWSRegions))
+	for i, r := range profile.AWSRegions {
+		regionOptions[i] = huh.NewOption(r, r)
 	}
-	name = strings.ToLower(strings.ReplaceAll(name, " ", "-"))
-	role := strings.ToLower(strings.ReplaceAll(roleName, " ", "-"))
-	return name + "-" + role
+
+	region := current
+	regionForm := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("SSO Region").
+				Description("The AWS region where your SSO instance is configured").
+				Options(regionOptions...).
+				Value(&region).
+				Height(10),
+		),
+	).WithTheme(sawsTheme()).WithOutput(Output)
+
+	if err := regionForm.Run(); err != nil {
+		return "", fmt.Errorf("form cancelled: %w", err)
+	}
+	return region, nil
 }
 
-// GenerateUniqueProfileNames generates unique profile names for a list of profiles.
-// If two profiles would get the same name (e.g. same role across accounts with the
-// same name), it appends a numeric suffix (-2, -3, etc.).
-func GenerateUniqueProfileNames(profiles []profile.SSOProfile) []string {
-	names := make([]string, len(profiles))
-	counts := map[string]int{}
-
-	// First pass: generate base names and count occurrences
-	baseNames := make([]string, len(profiles))
-	for i, p := range profiles {
-		base := SuggestProfileName(p.AccountName, p.RoleName)
-		baseNames[i] = base
-		counts[base]++
+// SelectRole asks the user to pick one of roles, with current pre-selected.
+func SelectRole(roles []string, current string) (string, error) {
+	options := make([]huh.Option[string], len(roles))
+	for i, r := range roles {
+		options[i] = huh.NewOption(r, r)
 	}
 
-	// Second pass: append suffix for duplicates
-	seen := map[string]int{}
-	for i, base := range baseNames {
-		if counts[base] > 1 {
-			seen[base]++
-			if seen[base] == 1 {
-				names[i] = base
-			} else {
-				names[i] = fmt.Sprintf("%s-%d", base, seen[base])
-			}
-		} else {
-			names[i] = base
-		}
+	role := current
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Select a Role").
+				Description("Roles currently available in this account").
+				Options(options...).
+				Value(&role).
+				Height(10),
+		),
+	).WithTheme(sawsTheme()).WithOutput(Output)
+
+	if err := form.Run(); err != nil {
+		return "", fmt.Errorf("form cancelled: %w", err)
 	}
+	return role, nil
+}
 
-	return names
+// defaultRegion picks the region to pre-select in the connection form.
+// An explicit default wins; otherwise the region cached for the start URL is used.
+func defaultRegion(defaults *SSOConnection, startURL string, cachedRegion func(startURL string) string) string {
+	if defaults != nil && defaults.Region != "" {
+		return defaults.Region
+	}
+	if cachedRegion != nil {
+		return cachedRegion(startURL)
+	}
+	return ""
 }
 
 // DiscoveredProfile pairs a profile with its auto-generated name for the import selector.
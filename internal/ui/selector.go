@@ -1,9 +1,11 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -47,9 +49,51 @@ func isFilterRune(msg tea.KeyMsg) (rune, bool) {
 	return 0, false
 }
 
+// ErrSelectionTimeout is returned by the selectors when SelectorOptions.IdleTimeout
+// passes without a key press.
+var ErrSelectionTimeout = errors.New("timed out waiting for selection")
+
+// idleTimeoutMsg is delivered when an idle window scheduled at key press
+// number seq has elapsed.
+type idleTimeoutMsg struct{ seq int }
+
+// idleTimer cancels a selector after a period without key presses.
+type idleTimer struct {
+	timeout time.Duration // zero disables the timer
+	seq     int           // bumped on every key press
+	expired bool
+}
+
+// tick schedules the end of the current idle window.
+func (t idleTimer) tick() tea.Cmd {
+	if t.timeout <= 0 {
+		return nil
+	}
+	seq := t.seq
+	return tea.Tick(t.timeout, func(time.Time) tea.Msg { return idleTimeoutMsg{seq: seq} })
+}
+
+// touch records a key press and starts a new idle window; ticks from
+// earlier windows are then ignored.
+func (t *idleTimer) touch() tea.Cmd {
+	t.seq++
+	return t.tick()
+}
+
+// fired reports whether msg ends the current idle window, marking the timer
+// expired if so.
+func (t *idleTimer) fired(msg idleTimeoutMsg) bool {
+	if t.timeout <= 0 || msg.seq != t.seq {
+		return false
+	}
+	t.expired = true
+	return true
+}
+
 const (
 	addNewProfileLabel = "+ Configure new profile"
 	backLabel          = "< Back to accounts"
+	backToGroupsLabel  = "< Back to groups"
 )
 
 // itemKind distinguishes the type of list item.
@@ -60,6 +104,8 @@ const (
 	kindRole
 	kindNew
 	kindBack
+	kindTag
+	kindBackToGroups
 )
 
 // selectorItem implements list.Item for the profile selector.
@@ -67,6 +113,14 @@ type selectorItem struct {
 	kind    itemKind
 	account *profile.AccountGroup // set for kindAccount
 	profile *profile.SSOProfile   // set for kindRole
+	tag     *profile.TagGroup     // set for kindTag
+
+	// showStartURL labels a role with its start URL, since a merged
+	// account may mix roles from several.
+	showStartURL bool
+
+	// showEmail adds the account email to the description and filter.
+	showEmail bool
 }
 
 func (i selectorItem) FilterValue() string {
@@ -77,16 +131,34 @@ func (i selectorItem) FilterValue() string {
 			parts += i.account.AccountName + " "
 		}
 		parts += i.account.AccountID + " " + i.account.Region
+		if i.showEmail && i.account.AccountEmail != "" {
+			parts += " " + i.account.AccountEmail
+		}
 		for _, r := range i.account.Roles {
 			parts += " " + r.Name
+			if r.Note != "" {
+				parts += " " + r.Note
+			}
 		}
 		return parts
 	case kindRole:
-		return i.profile.RoleName + " " + i.profile.Name
+		parts := i.profile.RoleName + " " + i.profile.Name + " " + i.profile.Note
+		if i.showEmail && i.profile.AccountEmail != "" {
+			parts += " " + i.profile.AccountEmail
+		}
+		return parts
 	case kindNew:
 		return addNewProfileLabel
 	case kindBack:
 		return backLabel
+	case kindTag:
+		parts := i.tag.Name
+		for _, g := range i.tag.Accounts {
+			parts += " " + g.AccountName + " " + g.AccountID
+		}
+		return parts
+	case kindBackToGroups:
+		return backToGroupsLabel
 	default:
 		return ""
 	}
@@ -105,9 +177,83 @@ func (d selectorDelegate) Render(w io.Writer, m list.Model, index int, listItem
 		return
 	}
 
-	isSelected := index == m.Index()
+	title, desc := item.text()
+	titleStyle, descStyle := itemStyles(index == m.Index())
+	// Both lines carry the style's padding plus a two-column marker or indent.
+	avail := m.Width() - titleStyle.GetPaddingLeft() - 2
+	title, desc = truncate(title, avail), truncate(desc, avail)
+	if index == m.Index() {
+		title = "> " + title
+	} else {
+		title = "  " + title
+	}
 
-	var title, desc string
+	fmt.Fprintf(w, "%s\n%s", titleStyle.Render(title), descStyle.Render("  "+desc))
+}
+
+// compactDelegate renders each item on a single line, title then
+// description, for --compact on small terminals.
+type compactDelegate struct{}
+
+func (d compactDelegate) Height() int                             { return 1 }
+func (d compactDelegate) Spacing() int                            { return 0 }
+func (d compactDelegate) Update(_ tea.Msg, _ *list.Model) tea.Cmd { return nil }
+
+func (d compactDelegate) Render(w io.Writer, m list.Model, index int, listItem list.Item) {
+	item, ok := listItem.(selectorItem)
+	if !ok {
+		return
+	}
+
+	title, desc := item.text()
+	titleStyle, descStyle := itemStyles(index == m.Index())
+	title = truncate(title, m.Width()-titleStyle.GetPaddingLeft()-2)
+	if index == m.Index() {
+		title = "> " + title
+	} else {
+		title = "  " + title
+	}
+	used := titleStyle.GetPaddingLeft() + lipgloss.Width(title) + descStyle.GetPaddingLeft() + 2
+	desc = truncate(desc, m.Width()-used)
+
+	line := titleStyle.Render(title) + descStyle.Render("  "+desc)
+	fmt.Fprint(w, lipgloss.NewStyle().MaxWidth(m.Width()).Render(line))
+}
+
+// truncate shortens s to at most width terminal columns, ending it with an
+// ellipsis when anything was cut. A non-positive width leaves s unchanged,
+// as a list that has not been sized yet reports zero.
+func truncate(s string, width int) string {
+	if width <= 0 || lipgloss.Width(s) <= width {
+		return s
+	}
+	const ellipsis = "…"
+	limit := width - lipgloss.Width(ellipsis)
+	var b strings.Builder
+	used := 0
+	for _, r := range s {
+		w := lipgloss.Width(string(r))
+		if used+w > limit {
+			break
+		}
+		b.WriteRune(r)
+		used += w
+	}
+	return b.String() + ellipsis
+}
+
+// itemStyles returns the title and description styles for an item.
+func itemStyles(selected bool) (title, desc lipgloss.Style) {
+	title = lipgloss.NewStyle().PaddingLeft(2)
+	desc = lipgloss.NewStyle().PaddingLeft(2).Foreground(ColorMuted)
+	if selected {
+		return title.Foreground(ColorPrimary).Bold(true), desc.Foreground(ColorPrimary)
+	}
+	return title.Foreground(ColorWhite), desc
+}
+
+// text returns the title and description shown for an item.
+func (item selectorItem) text() (title, desc string) {
 	switch item.kind {
 	case kindAccount:
 		g := item.account
@@ -119,42 +265,55 @@ func (d selectorDelegate) Render(w io.Writer, m list.Model, index int, listItem
 		roleCount := len(g.Roles)
 		if roleCount == 1 {
 			desc = fmt.Sprintf("%s | %s | %s", g.AccountID, g.Region, g.Roles[0].RoleName)
+			if note := g.Roles[0].Note; note != "" {
+				desc += " | " + note
+			}
 		} else {
 			desc = fmt.Sprintf("%s | %s | %d roles", g.AccountID, g.Region, roleCount)
 		}
+		if item.showEmail && g.AccountEmail != "" {
+			desc += " | " + g.AccountEmail
+		}
 	case kindRole:
 		p := item.profile
 		title = p.RoleName
 		desc = p.Name
+		if p.Region != "" {
+			desc += " | " + p.Region
+		}
+		if item.showStartURL {
+			desc += " | " + p.StartURL
+		}
+		if p.Note != "" {
+			desc += " | " + p.Note
+		}
 	case kindNew:
 		title = addNewProfileLabel
 		desc = "Set up a new SSO profile"
 	case kindBack:
 		title = backLabel
 		desc = "Return to account list"
+	case kindTag:
+		title = item.tag.Name
+		if n := len(item.tag.Accounts); n == 1 {
+			desc = "1 account"
+		} else {
+			desc = fmt.Sprintf("%d accounts", n)
+		}
+	case kindBackToGroups:
+		title = backToGroupsLabel
+		desc = "Return to group list"
 	}
-
-	titleStyle := lipgloss.NewStyle().PaddingLeft(2)
-	descStyle := lipgloss.NewStyle().PaddingLeft(2).Foreground(ColorMuted)
-
-	if isSelected {
-		titleStyle = titleStyle.Foreground(ColorPrimary).Bold(true)
-		descStyle = descStyle.Foreground(ColorPrimary)
-		title = "> " + title
-	} else {
-		titleStyle = titleStyle.Foreground(ColorWhite)
-		title = "  " + title
-	}
-
-	fmt.Fprintf(w, "%s\n%s", titleStyle.Render(title), descStyle.Render("  "+desc))
+	return title, desc
 }
 
-// selectorLevel tracks whether we're showing accounts or roles.
+// selectorLevel tracks whether we're showing groups, accounts, or roles.
 type selectorLevel int
 
 const (
 	levelAccounts selectorLevel = iota
 	levelRoles
+	levelTags
 )
 
 // selectorModel is the bubbletea model for profile selection.
@@ -162,18 +321,38 @@ const (
 // arrow keys simultaneously navigate the filtered results.
 type selectorModel struct {
 	list       list.Model
+	profiles   []profile.SSOProfile
 	groups     []profile.AccountGroup
+	mergeByID  bool        // group accounts by ID alone rather than start URL + ID
 	allItems   []list.Item // unfiltered items for current level
 	filterText string
-	level      selectorLevel
-	selected   *profile.AccountGroup // the account we drilled into
-	choice     *profile.SSOProfile
-	isNew      bool
-	quitting   bool
+	// filterFocused is set by '/', so a leading 'q' filters instead of quitting.
+	filterFocused bool
+	level         selectorLevel
+	tag           string                // the group tag we drilled into, if any
+	selected      *profile.AccountGroup // the account we drilled into
+	choice        *profile.SSOProfile
+	isNew         bool
+	rediscover    bool // 'r': run discovery again, then come back
+	quitting      bool
+	idle          idleTimer
+	showEmail     bool // show and filter by account email
+
+	// lastRoles maps account IDs to the profile last used in each.
+	lastRoles map[string]string
+	// rememberRole picks an account's last-used role on enter.
+	rememberRole bool
+
+	// deleteFn removes a saved profile; nil disables the 'd' key.
+	deleteFn func(name string) error
+	// pendingDelete is the profile awaiting y/n confirmation of 'd'.
+	pendingDelete *profile.SSOProfile
+	// status reports the outcome of the last delete.
+	status string
 }
 
 func (m selectorModel) Init() tea.Cmd {
-	return nil
+	return m.idle.tick()
 }
 
 // applyFilter updates the list items based on the current filter text.
@@ -188,20 +367,59 @@ func (m *selectorModel) setLevel(level selectorLevel, items []list.Item, title s
 	m.level = level
 	m.allItems = items
 	m.filterText = ""
+	m.filterFocused = false
 	m.list.SetItems(items)
 	m.list.Title = title
 	m.list.Select(0)
 }
 
 func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case idleTimeoutMsg:
+		if m.idle.fired(msg) {
+			m.quitting = true
+			return m, tea.Quit
+		}
+		return m, nil
+	case tea.KeyMsg:
+		idleCmd := m.idle.touch()
+		model, cmd := m.update(msg)
+		return model, tea.Batch(cmd, idleCmd)
+	}
+	return m.update(msg)
+}
+
+func (m selectorModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.pendingDelete != nil {
+			return m.confirmDelete(msg)
+		}
+		m.status = ""
+
 		// Handle filter input: printable runes
 		if r, ok := isFilterRune(msg); ok {
-			// 'q' quits when filter is empty
-			if r == 'q' && m.filterText == "" {
-				m.quitting = true
-				return m, tea.Quit
+			if m.filterText == "" && !m.filterFocused {
+				// 'q' quits when filter is empty; '/' focuses the filter;
+				// 'r' asks to rediscover; 'd' asks to delete the highlighted
+				// profile
+				switch r {
+				case 'q':
+					m.quitting = true
+					return m, tea.Quit
+				case 'r':
+					m.rediscover = true
+					m.quitting = true
+					return m, tea.Quit
+				case '/':
+					m.filterFocused = true
+					return m, nil
+				case 'd':
+					if p := m.deletable(); p != nil {
+						m.pendingDelete = p
+						return m, nil
+					}
+				}
 			}
 			m.filterText += string(r)
 			m.applyFilter()
@@ -215,6 +433,11 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.applyFilter()
 				return m, nil
 			}
+		case tea.KeyTab:
+			if m.level == levelAccounts {
+				m.toggleMerge()
+				return m, nil
+			}
 		case tea.KeyEnter:
 			item, ok := m.list.SelectedItem().(selectorItem)
 			if !ok {
@@ -227,7 +450,14 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				return m, tea.Quit
 			case kindBack:
 				m.selected = nil
-				m.setLevel(levelAccounts, m.accountItems(), "Select an AWS Account")
+				m.showAccounts()
+				return m, nil
+			case kindTag:
+				m.tag = item.tag.Name
+				m.showAccounts()
+				return m, nil
+			case kindBackToGroups:
+				m.showTop()
 				return m, nil
 			case kindAccount:
 				if len(item.account.Roles) == 1 {
@@ -236,12 +466,13 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m.quitting = true
 					return m, tea.Quit
 				}
-				m.selected = item.account
-				accountLabel := item.account.AccountID
-				if item.account.AccountName != "" {
-					accountLabel = item.account.AccountName
+				if i := m.lastRoleIndex(item.account); i >= 0 && m.rememberRole {
+					p := item.account.Roles[i]
+					m.choice = &p
+					m.quitting = true
+					return m, tea.Quit
 				}
-				m.setLevel(levelRoles, m.roleItems(item.account), fmt.Sprintf("Select a Role — %s", accountLabel))
+				m.showRoles(item.account)
 				return m, nil
 			case kindRole:
 				p := *item.profile
@@ -251,15 +482,21 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case tea.KeyEscape:
 			// If there's filter text, clear it first
-			if m.filterText != "" {
+			if m.filterText != "" || m.filterFocused {
 				m.filterText = ""
+				m.filterFocused = false
 				m.applyFilter()
 				return m, nil
 			}
-			// If in roles view, go back to accounts
+			// If in roles view, go back to accounts, and from a group's
+			// accounts back to the groups
 			if m.level == levelRoles {
 				m.selected = nil
-				m.setLevel(levelAccounts, m.accountItems(), "Select an AWS Account")
+				m.showAccounts()
+				return m, nil
+			}
+			if m.level == levelAccounts && m.tag != "" {
+				m.showTop()
 				return m, nil
 			}
 			m.quitting = true
@@ -279,9 +516,108 @@ func (m selectorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// finalView is the frame left behind on quit. With the alt screen it is
+// discarded; with --no-alt-screen it keeps the outcome in scrollback.
+func (m selectorModel) finalView() string {
+	switch {
+	case m.choice != nil:
+		return "  " + SuccessStyle.Render("Selected "+m.choice.Name) + "\n"
+	case m.isNew:
+		return "  " + MutedStyle.Render("Adding a new profile") + "\n"
+	case m.rediscover:
+		return "  " + MutedStyle.Render("Rediscovering accounts") + "\n"
+	}
+	return ""
+}
+
+// deletable returns the profile 'd' would delete: the highlighted role, or
+// the only role of the highlighted account. It is nil when deleting is
+// disabled or nothing suitable is highlighted.
+func (m selectorModel) deletable() *profile.SSOProfile {
+	if m.deleteFn == nil {
+		return nil
+	}
+	item, ok := m.list.SelectedItem().(selectorItem)
+	if !ok {
+		return nil
+	}
+	switch {
+	case item.kind == kindRole:
+		p := *item.profile
+		return &p
+	case item.kind == kindAccount && len(item.account.Roles) == 1:
+		p := item.account.Roles[0]
+		return &p
+	}
+	return nil
+}
+
+// confirmDelete handles the y/n answer to a pending delete. Any key other
+// than y cancels.
+func (m selectorModel) confirmDelete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	p := m.pendingDelete
+	m.pendingDelete = nil
+	if msg.Type == tea.KeyCtrlC {
+		m.quitting = true
+		return m, tea.Quit
+	}
+	if r, ok := isFilterRune(msg); !ok || (r != 'y' && r != 'Y') {
+		m.status = "Kept " + p.Name
+		return m, nil
+	}
+	if err := m.deleteFn(p.Name); err != nil {
+		m.status = "Could not delete " + p.Name + ": " + err.Error()
+		return m, nil
+	}
+	m.removeProfile(p.Name)
+	m.status = "Deleted " + p.Name
+	return m, nil
+}
+
+// removeProfile drops a deleted profile from the live list, staying in the
+// current account's role list while it still has roles.
+func (m *selectorModel) removeProfile(name string) {
+	kept := make([]profile.SSOProfile, 0, len(m.profiles))
+	for _, p := range m.profiles {
+		if p.Name != name {
+			kept = append(kept, p)
+		}
+	}
+	m.profiles = kept
+	m.regroup()
+
+	index := m.list.Index()
+	if m.level == levelRoles && m.selected != nil {
+		prev := m.selected
+		m.selected = nil
+		for i := range m.groups {
+			g := &m.groups[i]
+			if g.AccountID == prev.AccountID && (m.mergeByID || g.StartURL == prev.StartURL) {
+				m.selected = g
+				break
+			}
+		}
+		if m.selected == nil {
+			m.showAccounts()
+			return
+		}
+		m.allItems = m.roleItems(m.selected)
+	} else if m.level == levelTags {
+		m.allItems = m.tagItems()
+	} else {
+		if m.tag != "" && len(m.tagAccounts()) == 0 {
+			m.showTop()
+			return
+		}
+		m.allItems = m.accountItems()
+	}
+	m.applyFilter()
+	m.list.Select(min(index, len(m.list.Items())-1))
+}
+
 func (m selectorModel) View() string {
 	if m.quitting {
-		return ""
+		return m.finalView()
 	}
 
 	var b strings.Builder
@@ -292,27 +628,171 @@ func (m selectorModel) View() string {
 	cursor := lipgloss.NewStyle().Foreground(ColorPrimary).Render("█")
 	filterStyle := lipgloss.NewStyle().Foreground(ColorWhite)
 
-	if m.filterText != "" {
-		b.WriteString("  " + prompt + filterStyle.Render(m.filterText) + cursor + "\n\n")
+	if m.pendingDelete != nil {
+		question := fmt.Sprintf("Delete profile %s from ~/.aws/config? (y/N)", m.pendingDelete.Name)
+		b.WriteString("  " + WarningStyle.Render(question) + "\n\n")
+	} else if m.filterText != "" || m.filterFocused {
+		count := lipgloss.NewStyle().Foreground(ColorMuted).Render(matchCountLabel(m.matchCount()))
+		b.WriteString("  " + prompt + filterStyle.Render(m.filterText) + cursor + "  " + count + "\n\n")
 	} else {
 		placeholder := lipgloss.NewStyle().Foreground(ColorMuted).Render("Type to filter...")
 		b.WriteString("  " + prompt + placeholder + "\n\n")
 	}
 
 	b.WriteString(m.list.View())
+	if m.status != "" {
+		b.WriteString("\n" + lipgloss.NewStyle().Foreground(ColorMuted).PaddingLeft(2).Render(m.status))
+	}
 
 	// Help line at bottom
+	keys := "enter: select  /: filter  esc: back  q: quit  r: rediscover"
+	if m.deleteFn != nil {
+		keys += "  d: delete"
+	}
+	if m.level == levelAccounts {
+		if m.mergeByID {
+			keys += "  tab: split by start URL"
+		} else {
+			keys += "  tab: merge by account ID"
+		}
+	}
 	help := lipgloss.NewStyle().Foreground(ColorMuted).PaddingLeft(2).
-		Render("enter: select  esc: back  q: quit")
+		Render(keys)
 	b.WriteString("\n" + help)
 
 	return b.String()
 }
 
-func (m selectorModel) accountItems() []list.Item {
-	items := make([]list.Item, 0, len(m.groups)+1)
+// matchCount returns how many accounts or roles the filter currently shows,
+// not counting the "new profile" and "back" entries.
+func (m selectorModel) matchCount() int {
+	n := 0
+	for _, item := range m.list.Items() {
+		if si, ok := item.(selectorItem); ok && (si.kind == kindAccount || si.kind == kindRole || si.kind == kindTag) {
+			n++
+		}
+	}
+	return n
+}
+
+// matchCountLabel formats a filter match count, e.g. "3 matches".
+func matchCountLabel(n int) string {
+	if n == 1 {
+		return "1 match"
+	}
+	return fmt.Sprintf("%d matches", n)
+}
+
+// toggleMerge switches between grouping accounts by start URL + ID and by
+// ID alone, keeping the current filter.
+func (m *selectorModel) toggleMerge() {
+	m.mergeByID = !m.mergeByID
+	m.regroup()
+	m.allItems = m.accountItems()
+	m.applyFilter()
+}
+
+// regroup rebuilds the account groups from the profiles.
+func (m *selectorModel) regroup() {
+	if m.mergeByID {
+		m.groups = profile.GroupByAccountID(m.profiles)
+	} else {
+		m.groups = profile.GroupByAccount(m.profiles)
+	}
+}
+
+// showRoles drills into an account's roles, highlighting the one last used
+// there.
+func (m *selectorModel) showRoles(g *profile.AccountGroup) {
+	m.selected = g
+	m.setLevel(levelRoles, m.roleItems(g), fmt.Sprintf("Select a Role — %s", accountLabel(g)))
+	if i := m.lastRoleIndex(g); i >= 0 {
+		// The back entry comes first.
+		m.list.Select(i + 1)
+	}
+}
+
+// lastRoleIndex returns the index in g.Roles of the role last used in the
+// account, or -1.
+func (m selectorModel) lastRoleIndex(g *profile.AccountGroup) int {
+	name := m.lastRoles[g.AccountID]
+	if name == "" {
+		return -1
+	}
+	for i, r := range g.Roles {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// showTop switches to the top level: the group tags if any account is
+// tagged, otherwise the accounts.
+func (m *selectorModel) showTop() {
+	m.tag = ""
+	m.selected = nil
+	if tags := m.tagItems(); tags != nil {
+		m.setLevel(levelTags, tags, "Select a Group")
+		return
+	}
+	m.setLevel(levelAccounts, m.accountItems(), "Select an AWS Account")
+}
+
+// showAccounts switches to the accounts of the current group tag, or to the
+// top level if that group has no accounts left.
+func (m *selectorModel) showAccounts() {
+	if m.tag == "" || len(m.tagAccounts()) == 0 {
+		m.showTop()
+		return
+	}
+	m.setLevel(levelAccounts, m.accountItems(), "Select an AWS Account — "+m.tag)
+}
+
+// tagItems returns the group tag items, or nil when no account is tagged.
+func (m selectorModel) tagItems() []list.Item {
+	tags := profile.GroupByTag(m.groups)
+	if tags == nil {
+		return nil
+	}
+	items := make([]list.Item, 0, len(tags)+1)
+	for i := range tags {
+		items = append(items, selectorItem{kind: kindTag, tag: &tags[i]})
+	}
+	items = append(items, selectorItem{kind: kindNew})
+	return items
+}
+
+// tagAccounts returns the account groups shown at the accounts level: those
+// under the current group tag, or all of them when none is chosen.
+func (m selectorModel) tagAccounts() []*profile.AccountGroup {
+	accounts := make([]*profile.AccountGroup, 0, len(m.groups))
 	for i := range m.groups {
-		items = append(items, selectorItem{kind: kindAccount, account: &m.groups[i]})
+		g := &m.groups[i]
+		if m.tag != "" && groupTag(g) != m.tag {
+			continue
+		}
+		accounts = append(accounts, g)
+	}
+	return accounts
+}
+
+// groupTag returns the group tag an account is listed under.
+func groupTag(g *profile.AccountGroup) string {
+	if g.Group == "" {
+		return profile.UngroupedLabel
+	}
+	return g.Group
+}
+
+func (m selectorModel) accountItems() []list.Item {
+	accounts := m.tagAccounts()
+	items := make([]list.Item, 0, len(accounts)+2)
+	if m.tag != "" {
+		items = append(items, selectorItem{kind: kindBackToGroups})
+	}
+	for _, g := range accounts {
+		items = append(items, selectorItem{kind: kindAccount, account: g, showEmail: m.showEmail})
 	}
 	items = append(items, selectorItem{kind: kindNew})
 	return items
@@ -322,7 +802,7 @@ func (m selectorModel) roleItems(g *profile.AccountGroup) []list.Item {
 	items := make([]list.Item, 0, len(g.Roles)+1)
 	items = append(items, selectorItem{kind: kindBack})
 	for i := range g.Roles {
-		items = append(items, selectorItem{kind: kindRole, profile: &g.Roles[i]})
+		items = append(items, selectorItem{kind: kindRole, profile: &g.Roles[i], showStartURL: m.mergeByID, showEmail: m.showEmail})
 	}
 	return items
 }
@@ -331,23 +811,66 @@ func (m selectorModel) roleItems(g *profile.AccountGroup) []list.Item {
 type SelectionResult struct {
 	Profile *profile.SSOProfile // non-nil if an existing profile was selected
 	IsNew   bool                // true if user wants to create a new profile
+
+	// Rediscover is true if the user wants to run discovery again (for a
+	// newly granted role) and then pick from the updated profiles.
+	Rediscover bool
 }
 
-// RunProfileSelector displays a searchable list of profiles,
-// grouped by AWS account. Selecting an account expands to show its roles.
-// Typing filters the list; arrow keys navigate simultaneously.
-func RunProfileSelector(profiles []profile.SSOProfile) (*SelectionResult, error) {
-	groups := profile.GroupByAccount(profiles)
+// SelectorOptions configures RunProfileSelector.
+type SelectorOptions struct {
+	// InitialFilter pre-populates the filter so the list opens already filtered.
+	InitialFilter string
+
+	// Account, if set, opens the selector directly at the role list of the
+	// account with this ID or name.
+	Account string
+
+	// Compact renders each item on one line instead of two.
+	Compact bool
+
+	// Delete, if set, enables the 'd' key, which deletes the highlighted
+	// profile after a y/n confirmation and removes it from the list.
+	Delete func(name string) error
+
+	// LastRoles maps account IDs to the name of the profile last used in
+	// each. Drilling into an account highlights that role.
+	LastRoles map[string]string
+
+	// RememberRole makes enter on an account pick its last-used role
+	// straight away instead of listing the roles.
+	RememberRole bool
+
+	// ShowEmail shows account emails in the list and lets the filter match
+	// them, e.g. by domain.
+	ShowEmail bool
 
-	delegate := selectorDelegate{}
-	items := make([]list.Item, 0, len(groups)+1)
-	for i := range groups {
-		items = append(items, selectorItem{kind: kindAccount, account: &groups[i]})
+	// IdleTimeout, if positive, cancels the selector with
+	// ErrSelectionTimeout after this long without a key press.
+	IdleTimeout time.Duration
+
+	// NoAltScreen renders the list inline instead of in the alternate screen,
+	// so the final selection stays in the terminal scrollback.
+	NoAltScreen bool
+}
+
+// programOptions returns the tea program options for a selector.
+func programOptions(opts SelectorOptions) []tea.ProgramOption {
+	popts := []tea.ProgramOption{tea.WithOutput(Output)}
+	if !opts.NoAltScreen {
+		popts = append(popts, tea.WithAltScreen())
+	}
+	return popts
+}
+
+// newSelectorModel builds the selector model for the given profiles and options.
+func newSelectorModel(profiles []profile.SSOProfile, opts SelectorOptions) selectorModel {
+	var delegate list.ItemDelegate = selectorDelegate{}
+	if opts.Compact {
+		delegate = compactDelegate{}
 	}
-	items = append(items, selectorItem{kind: kindNew})
 
-	l := list.New(items, delegate, 60, 14)
-	l.Title = "Select an AWS Account"
+	l := list.New(nil, delegate, 60, 14)
 	l.Styles.Title = TitleStyle
 	l.SetFilteringEnabled(false)
 	l.SetShowHelp(false)
@@ -355,31 +878,91 @@ func RunProfileSelector(profiles []profile.SSOProfile) (*SelectionResult, error)
 
 	m := selectorModel{
 		list:     l,
-		groups:   groups,
-		allItems: items,
-		level:    levelAccounts,
+		profiles: profiles,
+		groups:   profile.GroupByAccount(profiles),
+		idle:     idleTimer{timeout: opts.IdleTimeout},
+
+		showEmail: opts.ShowEmail,
+		deleteFn:  opts.Delete,
+
+		lastRoles:    opts.LastRoles,
+		rememberRole: opts.RememberRole,
+	}
+	m.showTop()
+
+	if opts.Account != "" {
+		if g, ok := profile.FindAccountGroup(m.groups, opts.Account); ok {
+			if m.level == levelTags {
+				m.tag = groupTag(g)
+			}
+			m.showRoles(g)
+		}
+	}
+
+	if opts.InitialFilter != "" {
+		m.filterText = opts.InitialFilter
+		m.applyFilter()
 	}
+	return m
+}
+
+// accountLabel names an account for titles: its name if known, else its ID.
+func accountLabel(g *profile.AccountGroup) string {
+	if g.AccountName != "" {
+		return g.AccountName
+	}
+	return g.AccountID
+}
+
+// RunProfileSelector displays a searchable list of profiles,
+// grouped by AWS account. Selecting an account expands to show its roles.
+// Typing filters the list; arrow keys navigate simultaneously.
+func RunProfileSelector(profiles []profile.SSOProfile, opts SelectorOptions) (*SelectionResult, error) {
+	m := newSelectorModel(profiles, opts)
 
-	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithOutput(Output))
+	p := tea.NewProgram(m, programOptions(opts)...)
 	finalModel, err := p.Run()
 	if err != nil {
 		return nil, fmt.Errorf("selector failed: %w", err)
 	}
 
 	result := finalModel.(selectorModel)
-	if result.choice == nil && !result.isNew {
+	if result.idle.expired {
+		return nil, ErrSelectionTimeout
+	}
+	if result.choice == nil && !result.isNew && !result.rediscover {
 		return nil, fmt.Errorf("no profile selected")
 	}
 
 	return &SelectionResult{
-		Profile: result.choice,
-		IsNew:   result.isNew,
+		Profile:    result.choice,
+		IsNew:      result.isNew,
+		Rediscover: result.rediscover,
 	}, nil
 }
 
+// FilterProfiles returns the profiles whose account name, account ID, region,
+// role name, or profile name contain term (case-insensitive), in input order.
+func FilterProfiles(profiles []profile.SSOProfile, term string) []profile.SSOProfile {
+	term = strings.ToLower(term)
+	var out []profile.SSOProfile
+	for _, p := range profiles {
+		haystack := strings.ToLower(strings.Join([]string{p.AccountName, p.AccountID, p.Region, p.RoleName, p.Name}, " "))
+		if strings.Contains(haystack, term) {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
 // Confirm displays a yes/no confirmation prompt.
 func Confirm(message string) (bool, error) {
-	var result bool
+	return ConfirmDefault(message, false)
+}
+
+// ConfirmDefault is Confirm with def preselected.
+func ConfirmDefault(message string, def bool) (bool, error) {
+	result := def
 	form := huh.NewForm(
 		huh.NewGroup(
 			huh.NewConfirm().
@@ -402,7 +985,8 @@ func Confirm(message string) (bool, error) {
 // account/role combinations. All are pre-selected by default. The user can
 // toggle items with space, select/deselect all with a/n, and confirm with enter.
 // Typing filters the list; arrow keys navigate simultaneously.
-func RunProfileImportSelector(discovered []DiscoveredProfile) ([]DiscoveredProfile, error) {
+// Only opts.NoAltScreen and opts.IdleTimeout apply to the import selector.
+func RunProfileImportSelector(discovered []DiscoveredProfile, opts SelectorOptions) ([]DiscoveredProfile, error) {
 	if len(discovered) == 0 {
 		return nil, fmt.Errorf("no profiles to import")
 	}
@@ -438,15 +1022,19 @@ func RunProfileImportSelector(discovered []DiscoveredProfile) ([]DiscoveredProfi
 		allItems:   items,
 		checked:    checked,
 		discovered: discovered,
+		idle:       idleTimer{timeout: opts.IdleTimeout},
 	}
 
-	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithOutput(Output))
+	p := tea.NewProgram(m, programOptions(opts)...)
 	finalModel, err := p.Run()
 	if err != nil {
 		return nil, fmt.Errorf("import selector failed: %w", err)
 	}
 
 	result := finalModel.(importModel)
+	if result.idle.expired {
+		return nil, ErrSelectionTimeout
+	}
 	if result.cancelled {
 		return nil, fmt.Errorf("import selection cancelled")
 	}
@@ -530,10 +1118,11 @@ type importModel struct {
 	discovered []DiscoveredProfile
 	confirmed  bool
 	cancelled  bool
+	idle       idleTimer
 }
 
 func (m importModel) Init() tea.Cmd {
-	return nil
+	return m.idle.tick()
 }
 
 // applyFilter updates the list items based on the current filter text.
@@ -544,6 +1133,36 @@ func (m *importModel) applyFilter() {
 }
 
 func (m importModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case idleTimeoutMsg:
+		if m.idle.fired(msg) {
+			m.cancelled = true
+			return m, tea.Quit
+		}
+		return m, nil
+	case tea.KeyMsg:
+		idleCmd := m.idle.touch()
+		model, cmd := m.update(msg)
+		return model, tea.Batch(cmd, idleCmd)
+	}
+	return m.update(msg)
+}
+
+// finalView is the frame left behind on quit, as for selectorModel.
+func (m importModel) finalView() string {
+	if !m.confirmed {
+		return ""
+	}
+	count := 0
+	for _, v := range m.checked {
+		if v {
+			count++
+		}
+	}
+	return "  " + SuccessStyle.Render(fmt.Sprintf("Selected %d of %d profiles to import", count, len(m.discovered))) + "\n"
+}
+
+func (m importModel) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
 		// Handle filter input: printable runes
@@ -619,7 +1238,7 @@ func (m importModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 func (m importModel) View() string {
 	if m.confirmed || m.cancelled {
-		return ""
+		return m.finalView()
 	}
 
 	var b strings.Builder
@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
 )
 
 // Output is the writer used for TUI rendering. Defaults to os.Stdout.
@@ -13,6 +15,23 @@ import (
 // clean for shell eval.
 var Output io.Writer = os.Stdout
 
+// Quiet suppresses informational output written through Info. Prompts,
+// warnings, and errors are unaffected.
+var Quiet bool
+
+// NoColor turns off colors in all styled output (the "none" theme). It takes
+// effect at the next InitStyles.
+var NoColor bool
+
+// Info returns the writer for informational and success messages: Output,
+// or io.Discard when Quiet is set.
+func Info() io.Writer {
+	if Quiet {
+		return io.Discard
+	}
+	return Output
+}
+
 var (
 	// ColorPrimary is the AWS orange brand color.
 	ColorPrimary = lipgloss.Color("#FF9900")
@@ -57,12 +76,18 @@ var (
 	ValueStyle lipgloss.Style
 	// BannerStyle is the style for the ASCII art banner.
 	BannerStyle lipgloss.Style
+	// BigCodeStyle is the style for the large device authorization code.
+	BigCodeStyle lipgloss.Style
 )
 
 // InitStyles (re)initializes all lipgloss styles using the current default
 // renderer. Call this after configuring the lipgloss renderer (e.g. after
 // setting it to stderr in --export mode) and before any style is used.
 func InitStyles() {
+	if NoColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+
 	TitleStyle = lipgloss.NewStyle().
 		Bold(true).
 		Foreground(ColorPrimary).
@@ -109,10 +134,38 @@ func InitStyles() {
 		Bold(true).
 		Foreground(ColorPrimary).
 		MarginBottom(1)
+
+	BigCodeStyle = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(ColorPrimary)
 }
 
-// Banner returns the saws ASCII banner.
+// compactBanner is the single unstyled line used where the ASCII art would
+// only be noise.
+const compactBanner = "saws — AWS SSO Credential Helper\n"
+
+// CompactBanner returns the one-line, unstyled banner.
+func CompactBanner() string {
+	return compactBanner
+}
+
+// isInteractiveTerminal reports whether w is a terminal that can show styled
+// output: a TTY, and TERM is not "dumb".
+func isInteractiveTerminal(w io.Writer) bool {
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && term.IsTerminal(f.Fd())
+}
+
+// Banner returns the saws ASCII banner, or the compact banner when Output is
+// not an interactive terminal (pipes, CI logs, TERM=dumb).
 func Banner() string {
+	if !isInteractiveTerminal(Output) {
+		return CompactBanner()
+	}
+
 	banner := `
   ___  __ ___      _____
  / __|/ _` + "`" + ` \ \ /\ / / __|
@@ -1,9 +1,17 @@
 package ui
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/lvstb/saws/internal/profile"
 )
 
@@ -17,6 +25,47 @@ func TestBanner(t *testing.T) {
 	}
 }
 
+func TestBannerNonTTYIsCompact(t *testing.T) {
+	orig := Output
+	defer func() { Output = orig }()
+	Output = &bytes.Buffer{}
+
+	banner := Banner()
+	if banner != CompactBanner() {
+		t.Errorf("Banner() = %q, want compact banner", banner)
+	}
+	if strings.Contains(banner, "\x1b[") {
+		t.Errorf("compact banner contains ANSI codes: %q", banner)
+	}
+	if strings.Count(banner, "\n") != 1 {
+		t.Errorf("compact banner should be a single line, got %q", banner)
+	}
+}
+
+func TestBannerDumbTerminal(t *testing.T) {
+	t.Setenv("TERM", "dumb")
+	if isInteractiveTerminal(os.Stdout) {
+		t.Error("isInteractiveTerminal() = true with TERM=dumb")
+	}
+}
+
+func TestInfoQuiet(t *testing.T) {
+	origOutput, origQuiet := Output, Quiet
+	defer func() { Output, Quiet = origOutput, origQuiet }()
+
+	var buf bytes.Buffer
+	Output = &buf
+
+	Quiet = false
+	if Info() != &buf {
+		t.Error("Info() should return Output when not quiet")
+	}
+	Quiet = true
+	if Info() != io.Discard {
+		t.Error("Info() should discard output when quiet")
+	}
+}
+
 func TestFormatKeyValue(t *testing.T) {
 	result := FormatKeyValue("Key:", "Value")
 	if result == "" {
@@ -69,6 +118,26 @@ func TestSelectorItemFilterValue(t *testing.T) {
 		}
 	})
 
+	t.Run("account email is matched only when shown", func(t *testing.T) {
+		g := profile.AccountGroup{
+			AccountID:    "123456789012",
+			AccountName:  "Payments",
+			AccountEmail: "aws-payments@team-a.example.com",
+			Roles:        []profile.SSOProfile{{Name: "payments-admin", RoleName: "Admin"}},
+		}
+		items := []list.Item{
+			selectorItem{kind: kindAccount, account: &g, showEmail: true},
+		}
+		if got := filterItems(items, "team-a.example.com"); len(got) != 1 {
+			t.Errorf("filtering by email domain matched %d items, want 1", len(got))
+		}
+
+		hidden := []list.Item{selectorItem{kind: kindAccount, account: &g}}
+		if got := filterItems(hidden, "team-a.example.com"); len(got) != 0 {
+			t.Errorf("filtering by email domain without ShowEmail matched %d items, want 0", len(got))
+		}
+	})
+
 	t.Run("new item", func(t *testing.T) {
 		item := selectorItem{kind: kindNew}
 		if item.FilterValue() != addNewProfileLabel {
@@ -84,6 +153,28 @@ func TestSelectorItemFilterValue(t *testing.T) {
 	})
 }
 
+func TestSelectorDelegateRoleDescription(t *testing.T) {
+	d := selectorDelegate{}
+	l := list.New(nil, d, 80, 10)
+
+	render := func(item selectorItem) string {
+		var buf bytes.Buffer
+		d.Render(&buf, l, 0, item)
+		return buf.String()
+	}
+
+	p := profile.SSOProfile{Name: "dev-admin-eu", RoleName: "Admin", Region: "eu-west-1", StartURL: "https://corp.awsapps.com/start"}
+	got := render(selectorItem{kind: kindRole, profile: &p})
+	if !strings.Contains(got, "dev-admin-eu | eu-west-1") {
+		t.Errorf("role description = %q, want profile name and region", got)
+	}
+
+	got = render(selectorItem{kind: kindRole, profile: &p, showStartURL: true})
+	if !strings.Contains(got, "dev-admin-eu | eu-west-1 | https://corp.awsapps.com/start") {
+		t.Errorf("merged role description = %q, want region before start URL", got)
+	}
+}
+
 func TestSelectorDelegateDimensions(t *testing.T) {
 	d := selectorDelegate{}
 	if d.Height() != 2 {
@@ -94,6 +185,77 @@ func TestSelectorDelegateDimensions(t *testing.T) {
 	}
 }
 
+func TestCompactDelegate(t *testing.T) {
+	d := compactDelegate{}
+	if d.Height() != 1 {
+		t.Errorf("Height() = %d, want 1", d.Height())
+	}
+
+	l := list.New(nil, d, 60, 10)
+	g := profile.AccountGroup{
+		AccountID:   "111111111111",
+		AccountName: "Production",
+		Region:      "eu-west-1",
+		Roles:       []profile.SSOProfile{{Name: "prod-admin", RoleName: "Admin"}, {Name: "prod-ro", RoleName: "ReadOnly"}},
+	}
+	var buf bytes.Buffer
+	d.Render(&buf, l, 0, selectorItem{kind: kindAccount, account: &g})
+	got := buf.String()
+	if strings.Contains(got, "\n") {
+		t.Errorf("compact item spans several lines: %q", got)
+	}
+	for _, want := range []string{"Production", "111111111111 | eu-west-1 | 2 roles"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("compact item = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestDelegatesTruncateLongNames(t *testing.T) {
+	const width = 40
+	g := profile.AccountGroup{
+		AccountID:   "111111111111",
+		AccountName: strings.Repeat("Very Long Production Account Name ", 5),
+		Region:      "eu-west-1",
+		Roles:       []profile.SSOProfile{{Name: "prod-admin", RoleName: "AdministratorAccess"}},
+	}
+	for _, d := range []list.ItemDelegate{selectorDelegate{}, compactDelegate{}} {
+		l := list.New(nil, d, width, 10)
+		for index := 0; index < 2; index++ {
+			var buf bytes.Buffer
+			d.Render(&buf, l, index, selectorItem{kind: kindAccount, account: &g})
+			got := buf.String()
+			for _, line := range strings.Split(got, "\n") {
+				if w := lipgloss.Width(line); w > width {
+					t.Errorf("%T line %q is %d columns, want at most %d", d, line, w, width)
+				}
+			}
+			if !strings.Contains(got, "…") {
+				t.Errorf("%T item = %q, want an ellipsis", d, got)
+			}
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s     string
+		width int
+		want  string
+	}{
+		{"Production", 20, "Production"},
+		{"Production", 10, "Production"},
+		{"Production", 6, "Produ…"},
+		{"Production", 0, "Production"},
+		{"日本語アカウント", 7, "日本語…"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
 func TestSawsTheme(t *testing.T) {
 	theme := sawsTheme()
 	if theme == nil {
@@ -160,118 +322,13 @@ func containsStr(s, substr string) bool {
 	return false
 }
 
-func TestSuggestProfileName(t *testing.T) {
-	tests := []struct {
-		name        string
-		accountName string
-		roleName    string
-		want        string
-	}{
-		{"basic", "Production", "AdministratorAccess", "production-administratoraccess"},
-		{"with spaces", "My Account", "Power User", "my-account-power-user"},
-		{"empty account name", "", "Admin", "aws-admin"},
-		{"uppercase", "DEV", "ReadOnly", "dev-readonly"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := SuggestProfileName(tt.accountName, tt.roleName)
-			if got != tt.want {
-				t.Errorf("SuggestProfileName(%q, %q) = %q, want %q", tt.accountName, tt.roleName, got, tt.want)
-			}
-		})
-	}
-}
-
-func TestGenerateUniqueProfileNames(t *testing.T) {
-	t.Run("no duplicates", func(t *testing.T) {
-		profiles := []profile.SSOProfile{
-			{AccountName: "Production", RoleName: "Admin"},
-			{AccountName: "Staging", RoleName: "Admin"},
-			{AccountName: "Production", RoleName: "ReadOnly"},
-		}
-		names := GenerateUniqueProfileNames(profiles)
-		if len(names) != 3 {
-			t.Fatalf("got %d names, want 3", len(names))
-		}
-		if names[0] != "production-admin" {
-			t.Errorf("names[0] = %q, want %q", names[0], "production-admin")
-		}
-		if names[1] != "staging-admin" {
-			t.Errorf("names[1] = %q, want %q", names[1], "staging-admin")
-		}
-		if names[2] != "production-readonly" {
-			t.Errorf("names[2] = %q, want %q", names[2], "production-readonly")
-		}
-	})
-
-	t.Run("duplicates get suffix", func(t *testing.T) {
-		profiles := []profile.SSOProfile{
-			{AccountName: "Development", RoleName: "Admin"},
-			{AccountName: "Development", RoleName: "Admin"},
-			{AccountName: "Development", RoleName: "Admin"},
-		}
-		names := GenerateUniqueProfileNames(profiles)
-		if len(names) != 3 {
-			t.Fatalf("got %d names, want 3", len(names))
-		}
-		if names[0] != "development-admin" {
-			t.Errorf("names[0] = %q, want %q", names[0], "development-admin")
-		}
-		if names[1] != "development-admin-2" {
-			t.Errorf("names[1] = %q, want %q", names[1], "development-admin-2")
-		}
-		if names[2] != "development-admin-3" {
-			t.Errorf("names[2] = %q, want %q", names[2], "development-admin-3")
-		}
-	})
-
-	t.Run("mixed duplicates and unique", func(t *testing.T) {
-		profiles := []profile.SSOProfile{
-			{AccountName: "Prod", RoleName: "Admin"},
-			{AccountName: "Staging", RoleName: "ReadOnly"},
-			{AccountName: "Prod", RoleName: "Admin"},
-		}
-		names := GenerateUniqueProfileNames(profiles)
-		if names[0] != "prod-admin" {
-			t.Errorf("names[0] = %q, want %q", names[0], "prod-admin")
-		}
-		if names[1] != "staging-readonly" {
-			t.Errorf("names[1] = %q, want %q", names[1], "staging-readonly")
-		}
-		if names[2] != "prod-admin-2" {
-			t.Errorf("names[2] = %q, want %q", names[2], "prod-admin-2")
-		}
-	})
-
-	t.Run("empty input", func(t *testing.T) {
-		names := GenerateUniqueProfileNames(nil)
-		if len(names) != 0 {
-			t.Errorf("got %d names for nil input, want 0", len(names))
-		}
-	})
-
-	t.Run("single profile", func(t *testing.T) {
-		profiles := []profile.SSOProfile{
-			{AccountName: "Production", RoleName: "Admin"},
-		}
-		names := GenerateUniqueProfileNames(profiles)
-		if len(names) != 1 {
-			t.Fatalf("got %d names, want 1", len(names))
-		}
-		if names[0] != "production-admin" {
-			t.Errorf("names[0] = %q, want %q", names[0], "production-admin")
-		}
-	})
-}
-
 func TestRunProfileImportSelector_Empty(t *testing.T) {
-	_, err := RunProfileImportSelector(nil)
+	_, err := RunProfileImportSelector(nil, SelectorOptions{})
 	if err == nil {
 		t.Fatal("expected error for nil input, got nil")
 	}
 
-	_, err = RunProfileImportSelector([]DiscoveredProfile{})
+	_, err = RunProfileImportSelector([]DiscoveredProfile{}, SelectorOptions{})
 	if err == nil {
 		t.Fatal("expected error for empty input, got nil")
 	}
@@ -359,3 +416,557 @@ func TestFilterItems(t *testing.T) {
 		}
 	})
 }
+
+func TestDefaultRegion(t *testing.T) {
+	cache := map[string]string{
+		"https://cached.awsapps.com/start": "eu-central-1",
+	}
+	lookup := func(startURL string) string { return cache[startURL] }
+
+	tests := []struct {
+		name     string
+		defaults *SSOConnection
+		startURL string
+		lookup   func(string) string
+		want     string
+	}{
+		{"cached region seeds default", nil, "https://cached.awsapps.com/start", lookup, "eu-central-1"},
+		{"unknown start URL", nil, "https://other.awsapps.com/start", lookup, ""},
+		{"explicit default wins", &SSOConnection{Region: "us-west-2"}, "https://cached.awsapps.com/start", lookup, "us-west-2"},
+		{"nil lookup", nil, "https://cached.awsapps.com/start", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := defaultRegion(tt.defaults, tt.startURL, tt.lookup); got != tt.want {
+				t.Errorf("defaultRegion() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSelectorModelInitialFilter(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", AccountName: "Production", RoleName: "Admin", Region: "us-east-1", StartURL: "https://x"},
+		{Name: "staging-admin", AccountID: "222222222222", AccountName: "Staging", RoleName: "Admin", Region: "us-east-1", StartURL: "https://x"},
+		{Name: "dev-admin", AccountID: "333333333333", AccountName: "Development", RoleName: "Admin", Region: "us-east-1", StartURL: "https://x"},
+	}
+
+	t.Run("no filter shows all accounts plus new", func(t *testing.T) {
+		m := newSelectorModel(profiles, SelectorOptions{})
+		if got := len(m.list.Items()); got != 4 {
+			t.Errorf("items = %d, want 4", got)
+		}
+	})
+
+	t.Run("initial filter narrows items", func(t *testing.T) {
+		m := newSelectorModel(profiles, SelectorOptions{InitialFilter: "prod"})
+		if m.filterText != "prod" {
+			t.Errorf("filterText = %q, want %q", m.filterText, "prod")
+		}
+		items := m.list.Items()
+		if len(items) != 1 {
+			t.Fatalf("items = %d, want 1", len(items))
+		}
+		if items[0].(selectorItem).account.AccountName != "Production" {
+			t.Errorf("filtered item = %q, want Production", items[0].(selectorItem).account.AccountName)
+		}
+	})
+}
+
+func TestSelectorMatchCount(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", AccountName: "Production", RoleName: "Admin", Region: "us-east-1", StartURL: "https://x"},
+		{Name: "prod-eu-admin", AccountID: "222222222222", AccountName: "Production EU", RoleName: "Admin", Region: "eu-west-1", StartURL: "https://x"},
+		{Name: "dev-admin", AccountID: "333333333333", AccountName: "Development", RoleName: "Admin", Region: "us-east-1", StartURL: "https://x"},
+	}
+	var m tea.Model = newSelectorModel(profiles, SelectorOptions{})
+
+	// '/' focuses the filter, so the following 'q' filters instead of quitting.
+	for _, r := range "/qz" {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	sm := m.(selectorModel)
+	if sm.quitting || sm.filterText != "qz" {
+		t.Fatalf("after '/qz': quitting = %v, filterText = %q", sm.quitting, sm.filterText)
+	}
+	if got := sm.matchCount(); got != 0 {
+		t.Errorf("matchCount() for %q = %d, want 0", sm.filterText, got)
+	}
+
+	m = newSelectorModel(profiles, SelectorOptions{})
+	for _, r := range "prod" {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	sm = m.(selectorModel)
+	if got := sm.matchCount(); got != 2 {
+		t.Errorf("matchCount() for %q = %d, want 2", sm.filterText, got)
+	}
+	if !containsStr(sm.View(), "2 matches") {
+		t.Error("View() should show the match count while filtering")
+	}
+}
+
+func TestFilterProfiles(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountName: "Production", RoleName: "Admin"},
+		{Name: "prod-readonly", AccountName: "Production", RoleName: "ReadOnly"},
+		{Name: "dev-admin", AccountName: "Development", RoleName: "Admin"},
+	}
+
+	if got := FilterProfiles(profiles, "readonly"); len(got) != 1 || got[0].Name != "prod-readonly" {
+		t.Errorf("FilterProfiles(readonly) = %+v, want [prod-readonly]", got)
+	}
+	if got := FilterProfiles(profiles, "PROD"); len(got) != 2 {
+		t.Errorf("FilterProfiles(PROD) returned %d, want 2", len(got))
+	}
+	if got := FilterProfiles(profiles, "nothing"); len(got) != 0 {
+		t.Errorf("FilterProfiles(nothing) returned %d, want 0", len(got))
+	}
+}
+
+func TestSelectorKeepsStartURLForSharedAccountID(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "a-admin", StartURL: "https://org-a.awsapps.com/start", AccountID: "111111111111", AccountName: "Shared", RoleName: "Admin"},
+		{Name: "b-admin", StartURL: "https://org-b.awsapps.com/start", AccountID: "111111111111", AccountName: "Shared", RoleName: "Admin"},
+	}
+	m := newSelectorModel(profiles, SelectorOptions{})
+
+	if got := len(m.groups); got != 2 {
+		t.Fatalf("groups = %d, want 2 (one per start URL)", got)
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	updated, _ = updated.(selectorModel).Update(tea.KeyMsg{Type: tea.KeyEnter})
+	result := updated.(selectorModel)
+
+	if result.choice == nil {
+		t.Fatal("expected a profile to be chosen")
+	}
+	if result.choice.StartURL != "https://org-b.awsapps.com/start" {
+		t.Errorf("choice.StartURL = %q, want org-b", result.choice.StartURL)
+	}
+	if result.choice.Name != "b-admin" {
+		t.Errorf("choice.Name = %q, want b-admin", result.choice.Name)
+	}
+}
+
+func TestSelectorTabMergesByAccountID(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "a-admin", StartURL: "https://org-a.awsapps.com/start", AccountID: "111111111111", AccountName: "Shared", RoleName: "Admin"},
+		{Name: "b-admin", StartURL: "https://org-b.awsapps.com/start", AccountID: "111111111111", AccountName: "Shared", RoleName: "Admin"},
+	}
+	m := newSelectorModel(profiles, SelectorOptions{})
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	merged := updated.(selectorModel)
+	if got := len(merged.groups); got != 1 {
+		t.Fatalf("merged groups = %d, want 1", got)
+	}
+
+	updated, _ = merged.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	roles := updated.(selectorModel).roleItems(&merged.groups[0])
+	if item := roles[2].(selectorItem); !item.showStartURL {
+		t.Error("merged role items should be labelled with their start URL")
+	}
+
+	updated, _ = merged.Update(tea.KeyMsg{Type: tea.KeyTab})
+	if got := len(updated.(selectorModel).groups); got != 2 {
+		t.Errorf("groups after second tab = %d, want 2", got)
+	}
+}
+
+func TestProgramOptionsAltScreen(t *testing.T) {
+	// Output is always set; alt-screen is added unless disabled.
+	if got := len(programOptions(SelectorOptions{})); got != 2 {
+		t.Errorf("default options = %d, want 2 (output + alt-screen)", got)
+	}
+	if got := len(programOptions(SelectorOptions{NoAltScreen: true})); got != 1 {
+		t.Errorf("NoAltScreen options = %d, want 1 (output only)", got)
+	}
+}
+
+func TestSelectorFinalView(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", AccountName: "Production", RoleName: "Admin", StartURL: "https://x"},
+	}
+
+	// Without the alt screen the last frame stays in scrollback, so it must
+	// say what was picked rather than go blank.
+	var m tea.Model = newSelectorModel(profiles, SelectorOptions{NoAltScreen: true})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := m.View(); !strings.Contains(got, "Selected prod-admin") {
+		t.Errorf("final selector view = %q, want the selected profile", got)
+	}
+
+	m = newSelectorModel(profiles, SelectorOptions{NoAltScreen: true})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	if got := m.View(); got != "" {
+		t.Errorf("final selector view after cancel = %q, want empty", got)
+	}
+
+	im := importModel{
+		checked:    map[int]bool{0: true, 1: false},
+		discovered: make([]DiscoveredProfile, 2),
+	}
+	m, _ = im.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if got := m.View(); !strings.Contains(got, "Selected 1 of 2 profiles") {
+		t.Errorf("final import view = %q, want the selection count", got)
+	}
+}
+
+func TestNewSelectorModelAccount(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", AccountName: "Production", RoleName: "Admin", StartURL: "https://x"},
+		{Name: "prod-readonly", AccountID: "111111111111", AccountName: "Production", RoleName: "ReadOnly", StartURL: "https://x"},
+		{Name: "dev-admin", AccountID: "222222222222", AccountName: "Development", RoleName: "Admin", StartURL: "https://x"},
+	}
+
+	for _, target := range []string{"111111111111", "production"} {
+		m := newSelectorModel(profiles, SelectorOptions{Account: target})
+		if m.level != levelRoles {
+			t.Fatalf("Account %q: level = %v, want roles", target, m.level)
+		}
+		if m.selected == nil || m.selected.AccountID != "111111111111" {
+			t.Fatalf("Account %q: selected = %+v, want account 111111111111", target, m.selected)
+		}
+		// Back entry plus both roles.
+		if got := len(m.list.Items()); got != 3 {
+			t.Errorf("Account %q: items = %d, want 3", target, got)
+		}
+	}
+
+	m := newSelectorModel(profiles, SelectorOptions{Account: "999999999999"})
+	if m.level != levelAccounts {
+		t.Errorf("unknown account: level = %v, want accounts", m.level)
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	before := `[default]
+aws_access_key_id = AKIAOLD
+aws_secret_access_key = oldsecret
+`
+	after := `[default]
+aws_access_key_id = AKIAOLD
+aws_secret_access_key = oldsecret
+
+[dev]
+aws_access_key_id = AKIANEW
+aws_secret_access_key = newsecret
+aws_session_token = newtoken
+`
+	got := FormatDiff(before, after, false)
+
+	for _, want := range []string{"+ [dev]", "+ aws_access_key_id = AKIANEW", "+ aws_secret_access_key = <redacted>", "+ aws_session_token = <redacted>"} {
+		if !containsStr(got, want) {
+			t.Errorf("FormatDiff() missing %q in:\n%s", want, got)
+		}
+	}
+	for _, secret := range []string{"oldsecret", "newsecret", "newtoken"} {
+		if containsStr(got, secret) {
+			t.Errorf("FormatDiff() leaks %q:\n%s", secret, got)
+		}
+	}
+
+	removed := FormatDiff(after, before, false)
+	if !containsStr(removed, "- [dev]") {
+		t.Errorf("FormatDiff() should mark removed sections with -:\n%s", removed)
+	}
+	if FormatDiff(before, before, false) != "" {
+		t.Error("FormatDiff() of identical input should be empty")
+	}
+}
+
+func TestSelectorIdleTimeout(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", AccountName: "Production", RoleName: "Admin", StartURL: "https://x"},
+		{Name: "dev-admin", AccountID: "222222222222", AccountName: "Development", RoleName: "Admin", StartURL: "https://x"},
+	}
+
+	m := newSelectorModel(profiles, SelectorOptions{IdleTimeout: time.Minute})
+	if m.Init() == nil {
+		t.Fatal("Init() should schedule the idle timeout")
+	}
+
+	// A key press starts a new window, so the first window's tick is stale.
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	if cmd == nil {
+		t.Fatal("key press should reschedule the idle timeout")
+	}
+	updated, _ = updated.Update(idleTimeoutMsg{seq: 0})
+	if sm := updated.(selectorModel); sm.quitting || sm.idle.expired {
+		t.Fatal("stale idle tick should not cancel the selector")
+	}
+
+	// No input across the current window cancels.
+	updated, cmd = updated.Update(idleTimeoutMsg{seq: 1})
+	sm := updated.(selectorModel)
+	if !sm.idle.expired || !sm.quitting || cmd == nil {
+		t.Errorf("idle tick: expired = %v, quitting = %v; want the selector to quit", sm.idle.expired, sm.quitting)
+	}
+
+	off := newSelectorModel(profiles, SelectorOptions{})
+	if off.Init() != nil {
+		t.Error("Init() should not schedule a timeout when IdleTimeout is zero")
+	}
+}
+
+func TestSelectorGroupsByTag(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "plat-dev", AccountID: "111111111111", AccountName: "Platform Dev", RoleName: "Admin", Group: "platform"},
+		{Name: "plat-prod", AccountID: "222222222222", AccountName: "Platform Prod", RoleName: "Admin", Group: "platform"},
+		{Name: "legacy", AccountID: "333333333333", AccountName: "Legacy", RoleName: "Admin"},
+	}
+	var m tea.Model = newSelectorModel(profiles, SelectorOptions{})
+	sm := m.(selectorModel)
+	if sm.level != levelTags {
+		t.Fatalf("level = %v, want groups first", sm.level)
+	}
+	// platform, Ungrouped, and the new-profile entry.
+	if got := len(sm.list.Items()); got != 3 {
+		t.Fatalf("group items = %d, want 3", got)
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	sm = m.(selectorModel)
+	if sm.level != levelAccounts || sm.tag != "platform" {
+		t.Fatalf("level = %v, tag = %q; want platform's accounts", sm.level, sm.tag)
+	}
+	// Back entry, two accounts, and the new-profile entry.
+	if got := len(sm.list.Items()); got != 4 {
+		t.Errorf("account items = %d, want 4", got)
+	}
+
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	if sm = m.(selectorModel); sm.level != levelTags || sm.tag != "" {
+		t.Errorf("after esc: level = %v, tag = %q; want the groups", sm.level, sm.tag)
+	}
+
+	untagged := []profile.SSOProfile{{Name: "dev", AccountID: "111111111111", RoleName: "Admin"}}
+	if sm := newSelectorModel(untagged, SelectorOptions{}); sm.level != levelAccounts {
+		t.Errorf("level = %v, want accounts when nothing is tagged", sm.level)
+	}
+}
+
+func TestSelectorLastRole(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", AccountName: "Production", RoleName: "Admin"},
+		{Name: "prod-readonly", AccountID: "111111111111", AccountName: "Production", RoleName: "ReadOnly"},
+	}
+	last := map[string]string{"111111111111": "prod-readonly"}
+
+	var m tea.Model = newSelectorModel(profiles, SelectorOptions{LastRoles: last})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	sm := m.(selectorModel)
+	if sm.level != levelRoles {
+		t.Fatalf("level = %v, want roles", sm.level)
+	}
+	if item, ok := sm.list.SelectedItem().(selectorItem); !ok || item.kind != kindRole || item.profile.Name != "prod-readonly" {
+		t.Errorf("highlighted %+v, want the last-used role prod-readonly", sm.list.SelectedItem())
+	}
+
+	m = newSelectorModel(profiles, SelectorOptions{LastRoles: last, RememberRole: true})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if sm := m.(selectorModel); sm.choice == nil || sm.choice.Name != "prod-readonly" {
+		t.Errorf("choice = %+v, want prod-readonly picked straight away", sm.choice)
+	}
+}
+
+func TestSelectorRediscoverKey(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", AccountName: "Production", RoleName: "Admin", StartURL: "https://x"},
+		{Name: "dev-admin", AccountID: "222222222222", AccountName: "Development", RoleName: "Admin", StartURL: "https://x"},
+	}
+
+	var m tea.Model = newSelectorModel(profiles, SelectorOptions{})
+	m, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	sm := m.(selectorModel)
+	if !sm.rediscover || !sm.quitting || cmd == nil {
+		t.Errorf("r: rediscover = %v, quitting = %v; want the selector to quit asking to rediscover", sm.rediscover, sm.quitting)
+	}
+	if sm.choice != nil || sm.isNew {
+		t.Errorf("r should not pick a profile: choice = %v, isNew = %v", sm.choice, sm.isNew)
+	}
+
+	// With filter text, 'r' is part of the filter.
+	m = newSelectorModel(profiles, SelectorOptions{InitialFilter: "p"})
+	m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}})
+	if sm := m.(selectorModel); sm.rediscover || sm.filterText != "pr" {
+		t.Errorf("r with filter: rediscover = %v, filter = %q; want filter pr", sm.rediscover, sm.filterText)
+	}
+}
+
+func TestSelectorDeleteKey(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", AccountName: "Production", RoleName: "Admin", StartURL: "https://x"},
+		{Name: "prod-readonly", AccountID: "111111111111", AccountName: "Production", RoleName: "ReadOnly", StartURL: "https://x"},
+		{Name: "dev-admin", AccountID: "222222222222", AccountName: "Development", RoleName: "Admin", StartURL: "https://x"},
+	}
+	press := func(m tea.Model, r rune) tea.Model {
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		return m
+	}
+
+	var deleted []string
+	deleteOK := func(name string) error {
+		deleted = append(deleted, name)
+		return nil
+	}
+
+	t.Run("cancel keeps the profile", func(t *testing.T) {
+		deleted = nil
+		var m tea.Model = newSelectorModel(profiles, SelectorOptions{Account: "111111111111", Delete: deleteOK})
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m = press(m, 'd')
+		if sm := m.(selectorModel); sm.pendingDelete == nil || sm.pendingDelete.Name != "prod-admin" {
+			t.Fatalf("pendingDelete = %+v, want prod-admin", sm.pendingDelete)
+		}
+		m = press(m, 'n')
+		sm := m.(selectorModel)
+		if len(deleted) != 0 || sm.pendingDelete != nil {
+			t.Errorf("after n: deleted = %v, pending = %v; want nothing deleted", deleted, sm.pendingDelete)
+		}
+		if got := len(sm.list.Items()); got != 3 {
+			t.Errorf("items = %d, want 3", got)
+		}
+	})
+
+	t.Run("confirm removes the role and stays in the account", func(t *testing.T) {
+		deleted = nil
+		var m tea.Model = newSelectorModel(profiles, SelectorOptions{Account: "111111111111", Delete: deleteOK})
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m = press(press(m, 'd'), 'y')
+		sm := m.(selectorModel)
+		if len(deleted) != 1 || deleted[0] != "prod-admin" {
+			t.Fatalf("deleted = %v, want [prod-admin]", deleted)
+		}
+		if sm.quitting {
+			t.Fatal("delete should not exit the selector")
+		}
+		if sm.level != levelRoles {
+			t.Fatalf("level = %v, want roles", sm.level)
+		}
+		// Back entry plus the remaining role.
+		if got := len(sm.list.Items()); got != 2 {
+			t.Errorf("items = %d, want 2", got)
+		}
+		if len(sm.profiles) != 2 {
+			t.Errorf("profiles = %d, want 2", len(sm.profiles))
+		}
+	})
+
+	t.Run("deleting an account's last role returns to accounts", func(t *testing.T) {
+		deleted = nil
+		var m tea.Model = newSelectorModel(profiles, SelectorOptions{Account: "222222222222", Delete: deleteOK})
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m = press(press(m, 'd'), 'y')
+		sm := m.(selectorModel)
+		if sm.level != levelAccounts {
+			t.Fatalf("level = %v, want accounts", sm.level)
+		}
+		// The remaining account plus the "new profile" entry.
+		if got := len(sm.list.Items()); got != 2 {
+			t.Errorf("items = %d, want 2", got)
+		}
+	})
+
+	t.Run("failed delete keeps the item", func(t *testing.T) {
+		var m tea.Model = newSelectorModel(profiles, SelectorOptions{
+			Account: "111111111111",
+			Delete:  func(string) error { return errors.New("permission denied") },
+		})
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		m = press(press(m, 'd'), 'y')
+		sm := m.(selectorModel)
+		if got := len(sm.list.Items()); got != 3 {
+			t.Errorf("items = %d, want 3", got)
+		}
+		if !containsStr(sm.status, "permission denied") {
+			t.Errorf("status = %q, want the error", sm.status)
+		}
+	})
+
+	t.Run("d types into a non-empty filter", func(t *testing.T) {
+		deleted = nil
+		var m tea.Model = newSelectorModel(profiles, SelectorOptions{Delete: deleteOK})
+		m = press(press(m, 'p'), 'd')
+		sm := m.(selectorModel)
+		if sm.pendingDelete != nil || sm.filterText != "pd" {
+			t.Errorf("filterText = %q, pending = %v; want d typed into the filter", sm.filterText, sm.pendingDelete)
+		}
+	})
+
+	t.Run("disabled without a delete function", func(t *testing.T) {
+		var m tea.Model = newSelectorModel(profiles, SelectorOptions{Account: "111111111111"})
+		m, _ = m.Update(tea.KeyMsg{Type: tea.KeyDown})
+		if sm := press(m, 'd').(selectorModel); sm.pendingDelete != nil {
+			t.Error("d should not prompt when Delete is nil")
+		}
+	})
+}
+
+func TestBigCode(t *testing.T) {
+	got := BigCode("ABCD-1234")
+	lines := strings.Split(got, "\n")
+	if len(lines) != 5 {
+		t.Fatalf("BigCode() has %d lines, want 5:\n%s", len(lines), got)
+	}
+	if !containsStr(got, "█") {
+		t.Errorf("BigCode() should use block characters:\n%s", got)
+	}
+	if containsStr(got, "ABCD") {
+		t.Errorf("BigCode() should not contain the plain code:\n%s", got)
+	}
+	if BigCode("abcd") != BigCode("ABCD") {
+		t.Error("BigCode() should ignore letter case")
+	}
+}
+
+func TestAuthWaitModelReopensBrowser(t *testing.T) {
+	var opened []string
+	m := authWaitModel{
+		url:  "https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH",
+		open: func(url string) error { opened = append(opened, url); return nil },
+	}
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	if cmd != nil {
+		t.Error("o should not quit")
+	}
+	if len(opened) != 1 || opened[0] != m.url {
+		t.Fatalf("opened = %v, want [%s]", opened, m.url)
+	}
+	if !containsStr(updated.View(), "Opened the browser again") {
+		t.Errorf("View() = %q, want the reopen status", updated.View())
+	}
+
+	// Other keys are ignored.
+	updated, _ = updated.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'x'}})
+	if len(opened) != 1 {
+		t.Errorf("x opened the browser: %v", opened)
+	}
+
+	m.open = func(string) error { return errors.New("no browser") }
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'o'}})
+	if !containsStr(updated.(authWaitModel).status, "no browser") {
+		t.Errorf("status = %q, want the open error", updated.(authWaitModel).status)
+	}
+}
+
+func TestAuthWaitModelFinishes(t *testing.T) {
+	m := authWaitModel{open: func(string) error { return nil }}
+
+	updated, cmd := m.Update(authDoneMsg{err: errors.New("denied")})
+	if cmd == nil || !updated.(authWaitModel).done {
+		t.Fatal("authDoneMsg should quit")
+	}
+	if err := updated.(authWaitModel).err; err == nil || err.Error() != "denied" {
+		t.Errorf("err = %v, want denied", err)
+	}
+
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+	if cmd == nil || !updated.(authWaitModel).canceled {
+		t.Error("ctrl+c should cancel")
+	}
+}
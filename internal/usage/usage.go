@@ -0,0 +1,204 @@
+// Package usage records how often and how recently each profile is used.
+// The data stays on local disk (~/.config/saws/usage.json) and is only used
+// to order the profile selector and pre-select the last-used SSO region;
+// nothing is ever sent over the network.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/lvstb/saws/internal/home"
+	"github.com/lvstb/saws/internal/profile"
+)
+
+// Entry holds the usage statistics for a single profile.
+type Entry struct {
+	Count    int       `json:"count"`
+	LastUsed time.Time `json:"lastUsed"`
+}
+
+// Store holds usage statistics keyed by profile name.
+type Store struct {
+	Profiles map[string]Entry `json:"profiles"`
+
+	// LastRoles maps account IDs to the name of the profile last used in
+	// each, so the selector can offer that role again.
+	LastRoles map[string]string `json:"lastRoles,omitempty"`
+
+	// LastRegion is the SSO region last used to set up profiles, offered
+	// first the next time the region is asked for.
+	LastRegion string `json:"lastRegion,omitempty"`
+}
+
+// Order selects how profiles are sorted by SortProfiles.
+type Order string
+
+const (
+	// OrderNone keeps profiles in config file order.
+	OrderNone Order = ""
+	// OrderFrequency sorts the most frequently used profiles first.
+	OrderFrequency Order = "frequency"
+	// OrderRecent sorts the most recently used profiles first.
+	OrderRecent Order = "recent"
+)
+
+// ParseOrder parses a sort order name as given on the command line.
+func ParseOrder(name string) (Order, error) {
+	switch Order(name) {
+	case OrderNone, OrderFrequency, OrderRecent:
+		return Order(name), nil
+	default:
+		return "", fmt.Errorf("unknown sort order %q (supported: %s, %s)", name, OrderFrequency, OrderRecent)
+	}
+}
+
+// Path returns the path to the usage file, honouring XDG_CONFIG_HOME.
+func Path() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "saws", "usage.json"), nil
+	}
+	homeDir, err := home.Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config", "saws", "usage.json"), nil
+}
+
+// Load reads the usage store. A missing or corrupt file yields an empty
+// store rather than an error, since usage data is purely advisory.
+func Load() (*Store, error) {
+	s := &Store{Profiles: map[string]Entry{}, LastRoles: map[string]string{}}
+
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, nil
+	}
+
+	var loaded Store
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return s, nil
+	}
+	for name, e := range loaded.Profiles {
+		s.Profiles[name] = e
+	}
+	for accountID, name := range loaded.LastRoles {
+		s.LastRoles[accountID] = name
+	}
+	s.LastRegion = loaded.LastRegion
+	return s, nil
+}
+
+// Save writes the usage store to disk.
+func (s *Store) Save() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("cannot create usage directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot marshal usage data: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("cannot write usage file: %w", err)
+	}
+	return nil
+}
+
+// Record increments the use count for a profile and sets its last-used time.
+func (s *Store) Record(name string, at time.Time) {
+	e := s.Profiles[name]
+	e.Count++
+	e.LastUsed = at
+	s.Profiles[name] = e
+}
+
+// RecordRole remembers name as the profile last used in the account.
+func (s *Store) RecordRole(accountID, name string) {
+	if s.LastRoles == nil {
+		s.LastRoles = map[string]string{}
+	}
+	s.LastRoles[accountID] = name
+}
+
+// LastRole returns the name of the profile last used in the account, or ""
+// if none is recorded.
+func (s *Store) LastRole(accountID string) string {
+	return s.LastRoles[accountID]
+}
+
+// RecordUse loads the store, records a use of the named profile, and saves
+// it. A non-empty accountID also remembers it as that account's last role.
+func RecordUse(name, accountID string) error {
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+	s.Record(name, time.Now())
+	if accountID != "" {
+		s.RecordRole(accountID, name)
+	}
+	return s.Save()
+}
+
+// RecordRegion loads the store, remembers region as the last-used SSO
+// region, and saves it.
+func RecordRegion(region string) error {
+	s, err := Load()
+	if err != nil {
+		return err
+	}
+	s.LastRegion = region
+	return s.Save()
+}
+
+// Forget deletes all recorded usage data. It is not an error if none exists.
+func Forget() error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove usage file: %w", err)
+	}
+	return nil
+}
+
+// SortProfiles returns a copy of profiles sorted by the given order.
+// Profiles without recorded usage keep their relative config order after
+// the used ones. OrderNone returns the profiles unchanged.
+func (s *Store) SortProfiles(profiles []profile.SSOProfile, order Order) []profile.SSOProfile {
+	sorted := make([]profile.SSOProfile, len(profiles))
+	copy(sorted, profiles)
+
+	if order == OrderNone {
+		return sorted
+	}
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := s.Profiles[sorted[i].Name], s.Profiles[sorted[j].Name]
+		if order == OrderRecent {
+			return a.LastUsed.After(b.LastUsed)
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.LastUsed.After(b.LastUsed)
+	})
+	return sorted
+}
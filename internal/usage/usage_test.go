@@ -0,0 +1,205 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lvstb/saws/internal/profile"
+)
+
+func TestRecordIncrements(t *testing.T) {
+	s := &Store{Profiles: map[string]Entry{}}
+	first := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+
+	s.Record("dev", first)
+	s.Record("dev", second)
+	s.Record("prod", first)
+
+	if got := s.Profiles["dev"].Count; got != 2 {
+		t.Errorf("dev count = %d, want 2", got)
+	}
+	if got := s.Profiles["dev"].LastUsed; !got.Equal(second) {
+		t.Errorf("dev lastUsed = %v, want %v", got, second)
+	}
+	if got := s.Profiles["prod"].Count; got != 1 {
+		t.Errorf("prod count = %d, want 1", got)
+	}
+}
+
+func TestRecordUseMergesWithExistingFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if err := RecordUse("dev", ""); err != nil {
+		t.Fatalf("RecordUse() error = %v", err)
+	}
+	if err := RecordUse("prod", ""); err != nil {
+		t.Fatalf("RecordUse() error = %v", err)
+	}
+	if err := RecordUse("dev", ""); err != nil {
+		t.Fatalf("RecordUse() error = %v", err)
+	}
+
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := s.Profiles["dev"].Count; got != 2 {
+		t.Errorf("dev count = %d, want 2", got)
+	}
+	if got := s.Profiles["prod"].Count; got != 1 {
+		t.Errorf("prod count = %d, want 1", got)
+	}
+}
+
+func TestRecordUseRemembersLastRole(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	for _, use := range [][2]string{
+		{"prod-admin", "111111111111"},
+		{"dev-admin", "222222222222"},
+		{"prod-readonly", "111111111111"},
+	} {
+		if err := RecordUse(use[0], use[1]); err != nil {
+			t.Fatalf("RecordUse(%q, %q) error = %v", use[0], use[1], err)
+		}
+	}
+
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := s.LastRole("111111111111"); got != "prod-readonly" {
+		t.Errorf("LastRole(111111111111) = %q, want prod-readonly", got)
+	}
+	if got := s.LastRole("222222222222"); got != "dev-admin" {
+		t.Errorf("LastRole(222222222222) = %q, want dev-admin", got)
+	}
+	if got := s.LastRole("333333333333"); got != "" {
+		t.Errorf("LastRole(333333333333) = %q, want empty", got)
+	}
+}
+
+func TestRecordRegion(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if err := RecordUse("dev", "111111111111"); err != nil {
+		t.Fatalf("RecordUse() error = %v", err)
+	}
+	if err := RecordRegion("ap-southeast-2"); err != nil {
+		t.Fatalf("RecordRegion() error = %v", err)
+	}
+
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if s.LastRegion != "ap-southeast-2" {
+		t.Errorf("LastRegion = %q, want ap-southeast-2", s.LastRegion)
+	}
+	if s.Profiles["dev"].Count != 1 || s.LastRole("111111111111") != "dev" {
+		t.Errorf("RecordRegion() lost existing usage: %+v", s)
+	}
+}
+
+func TestLoadCorruptFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	path, err := Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(s.Profiles) != 0 {
+		t.Errorf("Load() of corrupt file returned %d entries, want 0", len(s.Profiles))
+	}
+
+	// Recording over a corrupt file replaces it with valid data
+	if err := RecordUse("dev", ""); err != nil {
+		t.Fatalf("RecordUse() error = %v", err)
+	}
+	s, _ = Load()
+	if s.Profiles["dev"].Count != 1 {
+		t.Errorf("dev count = %d, want 1", s.Profiles["dev"].Count)
+	}
+}
+
+func TestForget(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if err := RecordUse("dev", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := Forget(); err != nil {
+		t.Fatalf("Forget() error = %v", err)
+	}
+	s, _ := Load()
+	if len(s.Profiles) != 0 {
+		t.Errorf("Load() after Forget() returned %d entries, want 0", len(s.Profiles))
+	}
+	if err := Forget(); err != nil {
+		t.Errorf("Forget() with no file error = %v", err)
+	}
+}
+
+func TestSortProfiles(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &Store{Profiles: map[string]Entry{
+		"frequent": {Count: 10, LastUsed: base},
+		"recent":   {Count: 1, LastUsed: base.Add(time.Hour)},
+	}}
+	profiles := []profile.SSOProfile{{Name: "unused"}, {Name: "recent"}, {Name: "frequent"}}
+
+	names := func(ps []profile.SSOProfile) []string {
+		out := make([]string, len(ps))
+		for i, p := range ps {
+			out[i] = p.Name
+		}
+		return out
+	}
+
+	tests := []struct {
+		order Order
+		want  []string
+	}{
+		{OrderNone, []string{"unused", "recent", "frequent"}},
+		{OrderFrequency, []string{"frequent", "recent", "unused"}},
+		{OrderRecent, []string{"recent", "frequent", "unused"}},
+	}
+	for _, tt := range tests {
+		got := names(s.SortProfiles(profiles, tt.order))
+		for i := range tt.want {
+			if got[i] != tt.want[i] {
+				t.Errorf("SortProfiles(%q) = %v, want %v", tt.order, got, tt.want)
+				break
+			}
+		}
+	}
+
+	if profiles[0].Name != "unused" {
+		t.Error("SortProfiles() modified its input")
+	}
+}
+
+func TestParseOrder(t *testing.T) {
+	for _, name := range []string{"", "frequency", "recent"} {
+		if _, err := ParseOrder(name); err != nil {
+			t.Errorf("ParseOrder(%q) error = %v", name, err)
+		}
+	}
+	if _, err := ParseOrder("alphabetical"); err == nil {
+		t.Error("ParseOrder(alphabetical) expected error")
+	}
+}
@@ -2,21 +2,38 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
+	"regexp"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/smithy-go"
 	"github.com/charmbracelet/lipgloss"
-	"golang.org/x/sync/errgroup"
 
 	"github.com/lvstb/saws/internal/auth"
 	"github.com/lvstb/saws/internal/config"
 	"github.com/lvstb/saws/internal/credentials"
+	"github.com/lvstb/saws/internal/discovery"
 	"github.com/lvstb/saws/internal/profile"
+	"github.com/lvstb/saws/internal/settings"
 	"github.com/lvstb/saws/internal/shell"
 	"github.com/lvstb/saws/internal/ui"
+	"github.com/lvstb/saws/internal/usage"
 )
 
 var (
@@ -26,24 +43,121 @@ var (
 	flagConfigure = flag.Bool("configure", false, "Force new profile setup")
 	flagExport    = flag.Bool("export", false, "Output only export commands (for eval)")
 	flagVersion   = flag.Bool("version", false, "Print version and exit")
+
+	flagRoleSelect    = flag.Bool("role-select", false, "Pick the role from those currently granted in the profile's account instead of its saved role")
+	flagSelectAccount = flag.String("select-account", "", "Go straight to the role list of this account (ID or name)")
+	flagSelect        = flag.String("select", "", "Open the profile selector pre-filtered by this text")
+	flagYes           = flag.Bool("yes", false, "Skip confirmation prompts (with --select, use the match directly if exactly one profile matches)")
+	flagSort          = flag.String("sort", "", "Order the profile selector by local usage: frequency or recent")
+	flagPrintURL      = flag.Bool("print-url", false, "Print only the verification URL on stdout (everything else on stderr) and don't open a browser")
+	flagCredsProfile  = flag.String("creds-profile", "", "Write credentials under this section name in ~/.aws/credentials (default: the profile name)")
+	flagConfirmSwitch = flag.Bool("confirm-switch", false, "Ask before replacing still-valid credentials of a different active profile")
+	flagExportFormat  = flag.String("export-format", "", "Format for exported credentials: shell (default), json, or dotenv; implies --export")
+	flagOnly          = flag.String("only", "", "Comma-separated variables to export, e.g. AWS_ACCESS_KEY_ID,AWS_SECRET_ACCESS_KEY (applies to --export and --env-file)")
+	flagExportFD      = flag.Int("export-fd", 0, "Write export output to this open file descriptor (3 or higher) instead of stdout; implies --export")
+	flagFIPS          = flag.Bool("fips", false, "Use FIPS endpoints for the SSO and SSO OIDC APIs")
+	flagOpenConsole   = flag.Bool("open-console", false, "Open the AWS console for the profile instead of displaying credentials")
+	flagDestination   = flag.String("destination", "", "Console URL to land on with --open-console (default: the console home for the region)")
+	flagRegion        = flag.String("region", "", "Console region for --open-console; with --start-url, the region exported with the credentials (default: the profile's region, or --sso-region)")
+	flagSSORegion     = flag.String("sso-region", "", "Region of the IAM Identity Center instance behind --start-url")
+	flagStartURL      = flag.String("start-url", "", "Credentials-only mode: log in to this SSO start URL without reading or writing ~/.aws/config (needs --sso-region, --account-id, --role)")
+	flagAccountID     = flag.String("account-id", "", "Account ID for --start-url; alone, use the saved profile of an account with a single role")
+	flagRole          = flag.String("role", "", "Role name for --start-url, or to pick among an --account-id's saved profiles")
+	flagCredProcess   = flag.Bool("credential-process", false, "Print credentials for --profile as credential_process JSON, for use from ~/.aws/config")
+	flagManualCode    = flag.Bool("manual-code", false, "Show and open the bare verification URL and type the code in, instead of a link with the code embedded")
+	flagBigCode       = flag.Bool("big-code", false, "Also show the device authorization code in large block letters (terminals only), for shared screens")
+	flagEnvFile       = flag.String("env-file", "", "Also append the credentials, region, and expiration as KEY=VALUE lines to this file (e.g. $GITHUB_ENV)")
+	flagCompact       = flag.Bool("compact", false, "Render selector items on one line each, so more fit on small terminals")
+	flagAccountEmail  = flag.Bool("account-email", false, "Show account emails in the selector and let the filter match them")
+	flagRoleFilter    = flag.String("role-filter", "", "With discovery, only offer roles whose name matches this regular expression")
+	flagMerge         = flag.Bool("merge", false, "With discovery, only offer account/role pairs not already saved")
+	flagSSOSession    = flag.String("sso-session", "", "Reuse the start URL and region of an existing [sso-session <name>] block for discovery")
+	flagJSON          = flag.Bool("json", false, "Print a single JSON summary of the login on stdout (requires --profile)")
+	flagQuiet         = flag.Bool("quiet", false, "Suppress banner and informational output; prompts, warnings, errors, and export output still print")
+	flagIdP           = flag.String("idp", "", "Identity provider behind AWS SSO, for tailored approval hints (okta, azure, jumpcloud, google, onelogin)")
+	flagMaxAccounts   = flag.Int("max-accounts", 0, "Only discover roles for the first N accounts during --configure (default: no limit)")
+	flagReauth        = flag.Duration("reauth-threshold", 0, "Log in again when the cached SSO token has less than this long left (e.g. 2h; default off)")
+	flagIdleTimeout   = flag.Duration("idle-timeout", 0, "Cancel interactive selectors after this long without a key press (e.g. 60s; default off)")
+	flagNoAltScreen   = flag.Bool("no-alt-screen", false, "Render selectors inline instead of in the alternate screen (or set SAWS_NO_ALT_SCREEN=1)")
+	flagProxy         = flag.String("proxy", "", "Send SSO calls through this proxy URL, whatever HTTPS_PROXY says (e.g. http://proxy.corp:3128)")
+	flagIPv4          = flag.Bool("ipv4", false, "Only use IPv4 for SSO calls, for networks where IPv6 is blackholed (or set SAWS_FORCE_IPV4=1)")
+	flagCLICache      = flag.Bool("write-cli-cache", false, "Also write the session to ~/.aws/cli/cache in the AWS CLI's credential cache format")
+	flagNameTemplate  = flag.String("name-template", "", "Name discovered profiles from a template with {account}, {role}, {account_id}, {region} (default {account}-{role})")
+	flagRememberRole  = flag.Bool("remember-role", false, "Choosing an account with several roles uses the role you last picked there")
+	flagNoBanner      = flag.Bool("no-banner", false, "Do not print the banner (or set SAWS_NO_BANNER=1)")
+	flagConfigFile    = flag.String("config-file", "", "Read and write profiles in this AWS config file instead of AWS_CONFIG_FILE or ~/.aws/config")
+	flagCredsFile     = flag.String("credentials-file", "", "Write credentials to this file instead of AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials")
+	flagLegacyToken   = flag.Bool("legacy-token-var", false, "With --export, also export the session token as AWS_SECURITY_TOKEN for older tools")
+	flagTimeout       = flag.Duration("timeout", 0, "Give up on the whole run, including login polling, discovery, and credential fetches, after this long (e.g. 2m; default off)")
 )
 
+// subcommands maps subcommand names to their handlers. Each handler receives
+// the arguments following the subcommand name.
+var subcommands = map[string]func(args []string) error{
+	"init":                    runInit,
+	"login":                   runLogin,
+	"sessions":                runSessions,
+	"forget-usage":            runForgetUsage,
+	"cache":                   runCache,
+	"watch":                   runWatch,
+	"validate":                runValidate,
+	"rotate-all":              runRotateAll,
+	"migrate":                 runMigrate,
+	"list":                    runList,
+	"which":                   runWhich,
+	"note":                    runNote,
+	"ping":                    runPing,
+	"completion":              runCompletion,
+	"check":                   runCheck,
+	"diff":                    runDiff,
+	"inventory":               runInventory,
+	"__complete":              runComplete,
+	"wire-credential-process": runWireCredentialProcess,
+}
+
+// roleFilter is the compiled --role-filter, or nil when unset.
+var roleFilter *regexp.Regexp
+
+// onlyKeys is the parsed --only, or nil to export every variable.
+var onlyKeys credentials.KeySet
+
+// userSettings holds defaults from the settings file and SAWS_* environment
+// variables. Flags override them.
+var userSettings = settings.Defaults()
+
 func main() {
+	// Settings may turn colors off, so load them before styling anything.
+	s, err := settings.Load()
+	ui.NoColor = s.Theme == settings.ThemeNone
+
 	// Initialize styles early so error messages etc. are styled.
 	// In --export mode, run() will reconfigure the renderer and re-init.
 	ui.InitStyles()
+	config.LoosePermWarning = warnLoosePerm
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, ui.ErrorStyle.Render("Error: settings: "+err.Error()))
+		os.Exit(1)
+	}
+	userSettings = s
 
 	// Handle subcommands before flag parsing
-	if len(os.Args) >= 2 && os.Args[1] == "init" {
-		if err := runInit(os.Args[2:]); err != nil {
-			fmt.Fprintln(os.Stderr, ui.ErrorStyle.Render("Error: "+err.Error()))
-			os.Exit(1)
+	if len(os.Args) >= 2 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			runSubcommand(cmd, os.Args[2:])
+			return
 		}
-		return
 	}
 
 	flag.Parse()
 
+	// Global flags may also come first, as in saws --config-file f list;
+	// subcommands that read the AWS files pick up the file flags.
+	if cmd, ok := subcommands[flag.Arg(0)]; ok {
+		runSubcommand(cmd, flag.Args()[1:])
+		return
+	}
+
 	if *flagVersion {
 		fmt.Printf("saws %s\n", version)
 		os.Exit(0)
@@ -51,483 +165,2919 @@ func main() {
 
 	if err := run(); err != nil {
 		fmt.Fprintln(os.Stderr, ui.ErrorStyle.Render("Error: "+err.Error()))
-		os.Exit(1)
+		if hint := proxyHint(err); hint != "" {
+			fmt.Fprintln(os.Stderr, ui.MutedStyle.Render(hint))
+		}
+		os.Exit(exitCode(err))
 	}
 }
 
-func run() error {
-	ctx := context.Background()
-
-	// In export mode, redirect all display output to stderr so stdout
-	// stays clean for shell eval. TUI components use ui.Output.
-	// Also set lipgloss renderer to stderr so it detects colors from the
-	// TTY (stderr) rather than the pipe (stdout).
-	if *flagExport {
-		ui.Output = os.Stderr
-		lipgloss.SetDefaultRenderer(lipgloss.NewRenderer(os.Stderr))
-		ui.InitStyles()
+// runSubcommand runs a subcommand handler, exiting on failure.
+func runSubcommand(cmd func(args []string) error, args []string) {
+	if err := cmd(args); err != nil {
+		fmt.Fprintln(os.Stderr, ui.ErrorStyle.Render("Error: "+err.Error()))
+		os.Exit(exitCode(err))
 	}
+}
 
-	fmt.Fprint(ui.Output, ui.Banner())
+// errTimedOut is returned when --timeout expires before the run completes.
+var errTimedOut = errors.New("timed out")
 
-	// Determine which profile to use
-	p, token, err := resolveProfile(ctx)
-	if err != nil {
-		return err
+// exitTimedOut is the exit status for errTimedOut, the same as timeout(1)'s,
+// so CI scripts can tell a stuck run from a failed one.
+const exitTimedOut = 124
+
+// exitCode returns the process exit status for an error returned by run or
+// a subcommand.
+func exitCode(err error) int {
+	if errors.Is(err, errTimedOut) {
+		return exitTimedOut
 	}
+	return 1
+}
 
-	// nil profile with nil error means discovery just saved profiles — nothing more to do
-	if p == nil {
-		return nil
+// run runs the default credential flow, bounded by --timeout.
+func run() error {
+	if *flagTimeout < 0 {
+		return fmt.Errorf("--timeout must not be negative")
 	}
+	return withTimeout(*flagTimeout, runFlow)
+}
 
-	// If no token yet, check the SSO cache for a valid one
-	if token == nil {
-		if cached := config.ReadSSOCache(p.StartURL); cached != nil {
-			fmt.Fprintln(ui.Output, ui.SuccessStyle.Render("  Using cached SSO token (still valid)"))
-			fmt.Fprintln(ui.Output)
-			token = &auth.TokenResult{
-				AccessToken: cached.AccessToken,
-				ExpiresAt:   cached.ExpiresAt,
-			}
-		}
+// withTimeout calls fn with a context that is cancelled after timeout, or
+// never when timeout is zero. If fn fails once the deadline has passed, the
+// error is errTimedOut.
+func withTimeout(timeout time.Duration, fn func(ctx context.Context) error) error {
+	if timeout == 0 {
+		return fn(context.Background())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := fn(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s (--timeout): %v", errTimedOut, timeout, err)
 	}
+	return err
+}
 
-	// Authenticate via SSO OIDC if we still don't have a token
-	if token == nil {
-		// Load AWS config once for both auth and credential fetching
-		cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(p.Region))
-		if err != nil {
-			return fmt.Errorf("failed to load AWS config: %w", err)
+// runFlow picks a profile, logs in if needed, and outputs its credentials.
+func runFlow(ctx context.Context) error {
+	if *flagCredsProfile != "" {
+		if err := profile.ValidateProfileName(*flagCredsProfile); err != nil {
+			return fmt.Errorf("--creds-profile: %w", err)
 		}
-
-		token, err = authenticate(ctx, cfg, p)
+	}
+	if err := configureFiles(); err != nil {
+		return err
+	}
+	formatName := *flagExportFormat
+	if formatName == "" {
+		formatName = settingsExportFormat()
+	}
+	format, err := credentials.ParseExportFormat(formatName)
+	if err != nil {
+		return fmt.Errorf("--export-format: %w", err)
+	}
+	if *flagExportFormat != "" {
+		// Any explicit format is only useful on a clean stdout.
+		*flagExport = true
+	}
+	if *flagExportFD != 0 {
+		f, err := openExportFD(*flagExportFD)
 		if err != nil {
 			return err
 		}
-
-		// Cache the token for other AWS tools
-		if cacheErr := config.WriteSSOCache(p.StartURL, p.Region, token.AccessToken, token.ExpiresAt); cacheErr != nil {
-			fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not write SSO cache: "+cacheErr.Error()))
+		defer f.Close()
+		exportOut = f
+		*flagExport = true
+	}
+	if onlyKeys, err = credentials.ParseKeySet(*flagOnly); err != nil {
+		return fmt.Errorf("--only: %w", err)
+	}
+	if onlyKeys != nil {
+		if !*flagExport && *flagEnvFile == "" {
+			return fmt.Errorf("--only requires --export, --export-format, or --env-file")
 		}
-
-		// Fetch temporary credentials (reuse same config)
-		creds, err := fetchCredentials(ctx, cfg, p, token)
+		if *flagExport && format == credentials.ExportJSON {
+			return fmt.Errorf("--only cannot be combined with the json export format")
+		}
+		if err := checkOnlyKeys(onlyKeys, format, *flagExport, *flagEnvFile != ""); err != nil {
+			return fmt.Errorf("--only: %w", err)
+		}
+	}
+	if *flagIdP != "" && auth.IdPHint(*flagIdP) == "" {
+		return fmt.Errorf("--idp: unknown identity provider %q (known: %s)", *flagIdP, strings.Join(auth.KnownIdPs(), ", "))
+	}
+	if *flagMaxAccounts < 0 {
+		return fmt.Errorf("--max-accounts must not be negative")
+	}
+	if _, err := usage.ParseOrder(*flagSort); err != nil {
+		return fmt.Errorf("--sort: %w", err)
+	}
+	if *flagNameTemplate != "" {
+		if err := profile.ValidateNameTemplate(*flagNameTemplate); err != nil {
+			return fmt.Errorf("--name-template: %w", err)
+		}
+	}
+	if *flagRoleFilter != "" {
+		re, err := regexp.Compile(*flagRoleFilter)
 		if err != nil {
-			return err
+			return fmt.Errorf("--role-filter: %w", err)
 		}
+		roleFilter = re
+	}
 
-		return exportCredentials(p, creds)
+	if *flagJSON {
+		if *flagProfile == "" && *flagStartURL == "" {
+			return fmt.Errorf("--json requires --profile or --start-url")
+		}
+		if *flagExport || *flagPrintURL {
+			return fmt.Errorf("--json cannot be combined with --export, --export-format, or --print-url")
+		}
 	}
 
-	// Token came from cache or discovery flow — need a config for this profile's region
-	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(p.Region))
-	if err != nil {
-		return fmt.Errorf("failed to load AWS config: %w", err)
+	if *flagCredProcess {
+		if *flagProfile == "" && *flagStartURL == "" {
+			return fmt.Errorf("--credential-process requires --profile or --start-url")
+		}
+		if *flagExport || *flagPrintURL || *flagJSON || *flagOpenConsole {
+			return fmt.Errorf("--credential-process cannot be combined with --export, --export-format, --print-url, --json, or --open-console")
+		}
 	}
 
-	// Fetch temporary credentials
-	creds, err := fetchCredentials(ctx, cfg, p, token)
-	if err != nil {
+	if *flagEnvFile != "" && *flagProfile == "" && *flagStartURL == "" {
+		return fmt.Errorf("--env-file requires --profile or --start-url")
+	}
+
+	if err := configureEndpoints(); err != nil {
 		return err
 	}
 
-	// Export credentials
-	return exportCredentials(p, creds)
-}
+	if *flagOpenConsole && (*flagExport || *flagJSON) {
+		return fmt.Errorf("--open-console cannot be combined with --export, --export-format, or --json")
+	}
+	if *flagDestination != "" && !*flagOpenConsole {
+		return fmt.Errorf("--destination requires --open-console")
+	}
+	if *flagRegion != "" && !*flagOpenConsole && *flagStartURL == "" {
+		return fmt.Errorf("--region requires --open-console or --start-url")
+	}
+	if *flagSSORegion != "" && *flagStartURL == "" {
+		return fmt.Errorf("--sso-region requires --start-url")
+	}
 
-// resolveProfile determines which SSO profile to use.
-// It may also return a token if authentication happened during discovery.
-func resolveProfile(ctx context.Context) (*profile.SSOProfile, *auth.TokenResult, error) {
-	// --configure flag: run discovery flow
-	if *flagConfigure {
-		return runDiscoveryFlow(ctx)
+	// JSON mode keeps stdout for the summary; only prompts a login needs
+	// (the device auth box), warnings, and errors reach stderr.
+	ui.Quiet = *flagQuiet || *flagJSON || *flagCredProcess
+
+	// In export and print-url modes, redirect all display output to stderr so
+	// stdout stays clean for shell eval or for the bare verification URL. TUI components use ui.Output.
+	// Also set lipgloss renderer to stderr so it detects colors from the
+	// TTY (stderr) rather than the pipe (stdout).
+	if *flagExport || *flagPrintURL || *flagJSON || *flagCredProcess {
+		ui.Output = os.Stderr
+		lipgloss.SetDefaultRenderer(lipgloss.NewRenderer(os.Stderr))
+		ui.InitStyles()
 	}
 
-	// --profile flag: look up by name
-	if *flagProfile != "" {
-		p, err := lookupProfile(*flagProfile)
+	// Export output usually ends up in logs next to the eval'd commands, so
+	// keep the banner to a single line there.
+	printBanner(ui.Info(), *flagExport)
+
+	// A named sso-session replaces the start URL / region prompt in discovery.
+	var conn *ui.SSOConnection
+	if *flagSSOSession != "" {
+		conn, err = ssoSessionConnection(*flagSSOSession)
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
-		return p, nil, nil
 	}
 
-	// Default: load saved profiles and let user pick
-	profiles, err := config.LoadProfiles()
+	// Determine which profile to use: from flags alone in credentials-only
+	// mode, otherwise from the config file.
+	p, err := credentialsOnlyProfile()
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load profiles: %w", err)
+		return err
 	}
+	credentialsOnly := p != nil
+	var token *auth.TokenResult
+	if !credentialsOnly {
+		p, token, err = resolveProfile(ctx, conn)
+		if err != nil {
+			return err
+		}
 
-	// No saved profiles: run discovery flow
-	if len(profiles) == 0 {
-		fmt.Fprintln(ui.Output, ui.WarningStyle.Render("No saved SSO profiles found. Let's discover your accounts!"))
-		fmt.Fprintln(ui.Output)
-		return runDiscoveryFlow(ctx)
+		// nil profile with nil error means discovery just saved profiles — nothing more to do
+		if p == nil {
+			printChanges()
+			return nil
+		}
 	}
 
-	// Single profile: ask to use it or run discovery
-	if len(profiles) == 1 {
-		p, err := handleSingleProfile(profiles[0])
+	if *flagConfirmSwitch && !*flagYes && !*flagCredProcess {
+		ok, err := confirmSwitch(credentialsSection(p))
 		if err != nil {
-			return nil, nil, err
+			return err
 		}
-		if p == nil {
-			return runDiscoveryFlow(ctx)
+		if !ok {
+			fmt.Fprintln(ui.Info(), ui.MutedStyle.Render("  Keeping current credentials"))
+			return nil
 		}
-		return p, nil, nil
 	}
 
-	// Multiple profiles: fuzzy selector
-	p, err := selectProfile(profiles)
-	if err != nil {
-		return nil, nil, err
+	if err := profile.ValidateDurationSeconds(p.DurationSeconds); err != nil {
+		return fmt.Errorf("profile %q: %w", p.Name, err)
+	}
+	if p.DurationSeconds != 0 {
+		fmt.Fprintln(os.Stderr, ui.WarningStyle.Render(fmt.Sprintf(
+			"Warning: duration_seconds is set for %q but SSO GetRoleCredentials ignores it; the session length comes from the permission set", p.Name)))
 	}
 
-	// If user chose "new", run discovery
-	if p == nil {
-		return runDiscoveryFlow(ctx)
+	if err := resolveSSORegion(p); err != nil {
+		return err
 	}
-	return p, nil, nil
-}
 
-// lookupProfile finds a saved profile by name.
-func lookupProfile(name string) (*profile.SSOProfile, error) {
-	profiles, err := config.LoadProfiles()
+	// Load AWS config once for both auth and credential fetching
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(p.SSOClientRegion()))
 	if err != nil {
-		return nil, fmt.Errorf("failed to load profiles: %w", err)
+		return fmt.Errorf("failed to load AWS config: %w", err)
 	}
 
-	for _, p := range profiles {
-		if p.Name == name {
-			return &p, nil
+	// roleLoggedIn records a login made for --role-select, which
+	// obtainCredentials then doesn't see.
+	roleLoggedIn := false
+	if *flagRoleSelect {
+		var relogin credentials.ReloginFunc
+		if token == nil {
+			if cached := cachedToken(p); cached != nil {
+				token = &auth.TokenResult{AccessToken: cached.AccessToken, ExpiresAt: cached.ExpiresAt}
+				relogin = reloginRejected(cfg, p, func(fresh *auth.TokenResult) {
+					token, roleLoggedIn = fresh, true
+				})
+			} else {
+				token, err = loginAndCache(ctx, cfg, p)
+				if err != nil {
+					return err
+				}
+				roleLoggedIn = true
+			}
+		}
+		if err := selectLiveRole(ctx, credentials.NewSSOClientFromConfig(cfg), token.AccessToken, p, relogin, ui.SelectRole); err != nil {
+			return err
 		}
 	}
-	return nil, fmt.Errorf("profile %q not found in ~/.aws/config", name)
-}
-
-// handleSingleProfile handles the case where exactly one profile exists.
-func handleSingleProfile(p profile.SSOProfile) (*profile.SSOProfile, error) {
-	fmt.Fprintf(ui.Output, "%s %s\n\n",
-		ui.SubtitleStyle.Render("Found profile:"),
-		ui.SuccessStyle.Render(p.DisplayName()),
-	)
 
-	useExisting, err := ui.Confirm("Use this profile?")
+	creds, loggedIn, err := obtainCredentials(ctx, cfg, p, token)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	loggedIn = loggedIn || roleLoggedIn
 
-	if useExisting {
-		return &p, nil
+	if *flagCredProcess {
+		out, err := credentials.FormatCredentialProcess(creds)
+		if err != nil {
+			return err
+		}
+		fmt.Println(out)
+	} else if *flagOpenConsole {
+		if err := openConsole(ctx, p, creds); err != nil {
+			return err
+		}
+	} else if err := exportCredentials(p, creds, format); err != nil {
+		return err
 	}
-	// Return nil to signal "configure new" — caller handles discovery
-	return nil, nil
-}
 
-// selectProfile runs the fuzzy selector for multiple profiles.
-// Returns nil profile if user chose "configure new".
-func selectProfile(profiles []profile.SSOProfile) (*profile.SSOProfile, error) {
-	result, err := ui.RunProfileSelector(profiles)
-	if err != nil {
-		return nil, err
+	if *flagEnvFile != "" {
+		region := p.Region
+		if region == "" {
+			region = p.SSOClientRegion()
+		}
+		if err := credentials.AppendEnvFile(*flagEnvFile, creds, region, onlyKeys); err != nil {
+			return err
+		}
+		fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Credentials appended to "+*flagEnvFile))
+		changes = append(changes, fileChange{Path: *flagEnvFile, What: "appended credentials for profile " + p.Name})
 	}
+	printChanges()
 
-	if result.IsNew {
-		return nil, nil
+	if *flagJSON {
+		out, err := json.MarshalIndent(newRunSummary(p, creds, loggedIn), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
 	}
-	return result.Profile, nil
-}
 
-// runDiscoveryFlow guides the user through SSO setup using auto-discovery.
-// It asks for minimal info (URL + region), authenticates, discovers ALL accounts
-// and roles, lets the user multi-select which to import, saves them all, then
-// drops into the normal profile selector to pick one to use now.
-func runDiscoveryFlow(ctx context.Context) (*profile.SSOProfile, *auth.TokenResult, error) {
-	// Step 1: Ask for SSO Start URL and Region
-	conn, err := ui.RunSSOConnectionForm(nil)
-	if err != nil {
-		return nil, nil, err
+	// Record the login locally for --sort (never leaves this machine).
+	// Credentials-only profiles are not saved, so there is nothing to sort.
+	if credentialsOnly {
+		return nil
 	}
-
-	// Load AWS config once for both OIDC and SSO clients
-	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(conn.Region))
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load AWS config: %w", err)
+	if err := usage.RecordUse(p.Name, p.AccountID); err != nil {
+		fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not record profile usage: "+err.Error()))
 	}
+	return nil
+}
 
-	// Step 2: Authenticate via SSO OIDC
-	oidcClient := auth.NewOIDCClientFromConfig(cfg)
+// checkOnlyKeys rejects an --only naming variables that none of the selected
+// outputs print, or leaving one of them with nothing to print, so e.g.
+// `eval "$(saws --export --only AWS_REGION)"` fails instead of setting nothing.
+func checkOnlyKeys(only credentials.KeySet, format credentials.ExportFormat, export, envFile bool) error {
+	type output struct {
+		name string
+		keys []string
+	}
+	var outputs []output
+	if export {
+		outputs = append(outputs, output{"the " + string(format) + " export format", credentials.FormatKeys(format)})
+	}
+	if envFile {
+		outputs = append(outputs, output{"--env-file", credentials.EnvFileKeys})
+	}
 
-	token, err := auth.Authenticate(
-		ctx,
-		oidcClient,
-		conn.StartURL,
-		func(info auth.DeviceAuthInfo) {
-			fmt.Fprintln(ui.Output)
-			fmt.Fprintln(ui.Output, ui.BoxStyle.Render(
-				ui.FormatKeyValue("Verification URL: ", info.VerificationURI)+"\n"+
-					ui.FormatKeyValue("User Code:        ", info.UserCode)+"\n\n"+
-					ui.MutedStyle.Render("A browser window should open automatically.\nIf not, open the URL above and enter the code."),
-			))
-			fmt.Fprintln(ui.Output)
-		},
-		func(status string) {
-			fmt.Fprintln(ui.Output, ui.MutedStyle.Render("  "+status))
-		},
-	)
-	if err != nil {
-		return nil, nil, err
+	var printed []string
+	for _, o := range outputs {
+		if !only.Overlaps(o.keys) {
+			return fmt.Errorf("nothing left for %s to print (it prints %s)", o.name, strings.Join(o.keys, ", "))
+		}
+		printed = append(printed, o.keys...)
 	}
+	if extra := only.Outside(printed); len(extra) > 0 {
+		return fmt.Errorf("%s not printed by the selected output", strings.Join(extra, ", "))
+	}
+	return nil
+}
 
-	fmt.Fprintln(ui.Output, ui.SuccessStyle.Render("  Authentication successful!"))
-	fmt.Fprintln(ui.Output)
+// addFileFlags registers --config-file and --credentials-file on the flag set
+// of a subcommand that reads or writes the AWS files. Call configureFiles
+// after parsing.
+func addFileFlags(fs *flag.FlagSet) {
+	fs.StringVar(flagConfigFile, "config-file", *flagConfigFile, "Use this AWS config file instead of AWS_CONFIG_FILE or ~/.aws/config")
+	fs.StringVar(flagCredsFile, "credentials-file", *flagCredsFile, "Use this credentials file instead of AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials")
+}
 
-	// Cache the token for other AWS tools
-	if cacheErr := config.WriteSSOCache(conn.StartURL, conn.Region, token.AccessToken, token.ExpiresAt); cacheErr != nil {
-		fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not write SSO cache: "+cacheErr.Error()))
-	}
+// addBannerFlag registers --no-banner on the flag set of a subcommand that
+// prints the banner.
+func addBannerFlag(fs *flag.FlagSet) {
+	fs.BoolVar(flagNoBanner, "no-banner", *flagNoBanner, "Do not print the banner (or set SAWS_NO_BANNER=1)")
+}
 
-	// Step 3: Discover all accounts
-	ssoClient := credentials.NewSSOClientFromConfig(cfg)
+// addEndpointFlags registers --fips, --ipv4, and --proxy on a subcommand's
+// flag set, bound to the global flags so configureEndpoints sees them either
+// before or after the subcommand name.
+func addEndpointFlags(fs *flag.FlagSet) {
+	fs.BoolVar(flagFIPS, "fips", *flagFIPS, "Use FIPS endpoints for the SSO and SSO OIDC APIs")
+	fs.BoolVar(flagIPv4, "ipv4", *flagIPv4, "Only use IPv4 for SSO calls (or set SAWS_FORCE_IPV4=1)")
+	fs.StringVar(flagProxy, "proxy", *flagProxy, "Send SSO calls through this proxy URL, whatever HTTPS_PROXY says")
+}
 
-	fmt.Fprintln(ui.Output, ui.MutedStyle.Render("  Discovering accounts..."))
+// configureFiles points the config layer at --config-file and
+// --credentials-file, after checking their directories exist.
+func configureFiles() error {
+	for _, f := range []struct {
+		name   string
+		value  string
+		target *string
+	}{
+		{"--config-file", *flagConfigFile, &config.ConfigFile},
+		{"--credentials-file", *flagCredsFile, &config.CredentialsFile},
+	} {
+		if f.value == "" {
+			continue
+		}
+		if err := config.CheckParentDir(f.value); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+		*f.target = f.value
+	}
+	return nil
+}
 
-	discoveredAccounts, err := credentials.ListAccounts(ctx, ssoClient, token.AccessToken)
+// configureEndpoints applies --fips, --ipv4, --proxy, and the
+// SAWS_SSO_ENDPOINT and SAWS_OIDC_ENDPOINT overrides to the SSO and SSO OIDC
+// clients.
+func configureEndpoints() error {
+	ssoEndpoint := os.Getenv("SAWS_SSO_ENDPOINT")
+	oidcEndpoint := os.Getenv("SAWS_OIDC_ENDPOINT")
+	if err := validateEndpoint(ssoEndpoint); err != nil {
+		return fmt.Errorf("SAWS_SSO_ENDPOINT: %w", err)
+	}
+	if err := validateEndpoint(oidcEndpoint); err != nil {
+		return fmt.Errorf("SAWS_OIDC_ENDPOINT: %w", err)
+	}
+	credentials.Endpoint, credentials.UseFIPS = ssoEndpoint, *flagFIPS
+	auth.Endpoint, auth.UseFIPS = oidcEndpoint, *flagFIPS
+	proxy, err := parseProxy(*flagProxy)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to discover accounts: %w", err)
+		return fmt.Errorf("--proxy: %w", err)
 	}
-
-	if len(discoveredAccounts) == 0 {
-		return nil, nil, fmt.Errorf("no AWS accounts found for this SSO user")
+	if ipv4Only() || proxy != nil {
+		client := awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+			adjustTransport(tr, proxy)
+		})
+		credentials.HTTPClient, auth.HTTPClient = client, client
 	}
+	return nil
+}
 
-	fmt.Fprintln(ui.Output, ui.SuccessStyle.Render(fmt.Sprintf("  Found %d account(s)", len(discoveredAccounts))))
-
-	// Step 4: Discover roles for ALL accounts (in parallel)
-	fmt.Fprintln(ui.Output, ui.MutedStyle.Render("  Discovering roles..."))
-
-	type accountRoles struct {
-		account credentials.DiscoveredAccount
-		roles   []credentials.DiscoveredRole
+// parseProxy parses a --proxy URL. An empty value means no proxy override.
+func parseProxy(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
 	}
-
-	results := make([]accountRoles, len(discoveredAccounts))
-	g, gctx := errgroup.WithContext(ctx)
-	g.SetLimit(5) // keep below SSO API rate limits
-
-	for i, acct := range discoveredAccounts {
-		results[i].account = acct
-		g.Go(func() error {
-			roles, err := credentials.ListAccountRoles(gctx, ssoClient, token.AccessToken, acct.AccountID)
-			if err != nil {
-				return fmt.Errorf("failed to discover roles for account %s: %w", acct.AccountID, err)
-			}
-			results[i].roles = roles
-			return nil
-		})
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
 	}
-	if err := g.Wait(); err != nil {
-		return nil, nil, err
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy URL must start with http://, https://, or socks5://, got %q", raw)
 	}
-
-	var allProfiles []profile.SSOProfile
-	for _, r := range results {
-		for _, role := range r.roles {
-			allProfiles = append(allProfiles, profile.SSOProfile{
-				StartURL:    conn.StartURL,
-				Region:      conn.Region,
-				AccountID:   r.account.AccountID,
-				AccountName: r.account.AccountName,
-				RoleName:    role.RoleName,
-			})
-		}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
 	}
+	return u, nil
+}
 
-	if len(allProfiles) == 0 {
-		return nil, nil, fmt.Errorf("no roles found across any accounts")
+// adjustTransport applies --ipv4 and a non-nil --proxy to tr. The proxy is
+// used for every request, whatever HTTPS_PROXY and NO_PROXY say.
+func adjustTransport(tr *http.Transport, proxy *url.URL) {
+	if ipv4Only() {
+		forceNetwork(tr, "tcp4")
 	}
+	if proxy != nil {
+		tr.Proxy = http.ProxyURL(proxy)
+	}
+}
 
-	// Generate unique profile names
-	names := ui.GenerateUniqueProfileNames(allProfiles)
-	for i := range allProfiles {
-		allProfiles[i].Name = names[i]
+// proxyHint suggests proxy settings when err looks like a network failure a
+// corporate proxy would explain. It is empty for other errors.
+func proxyHint(err error) string {
+	if !auth.IsProxyRelated(err) {
+		return ""
 	}
+	if *flagProxy != "" || os.Getenv("HTTPS_PROXY") != "" || os.Getenv("https_proxy") != "" {
+		return "A proxy is configured; check that it is reachable and that NO_PROXY does not exclude AWS hosts."
+	}
+	return "Behind a corporate proxy? Set HTTPS_PROXY (and NO_PROXY for internal hosts) or pass --proxy <url>."
+}
 
-	fmt.Fprintln(ui.Output, ui.SuccessStyle.Render(fmt.Sprintf("  Found %d profile(s) across %d account(s)", len(allProfiles), len(discoveredAccounts))))
-	fmt.Fprintln(ui.Output)
+// ipv4Only reports whether SSO calls must only dial IPv4 addresses, via
+// --ipv4 or SAWS_FORCE_IPV4=1.
+func ipv4Only() bool {
+	return *flagIPv4 || os.Getenv("SAWS_FORCE_IPV4") == "1"
+}
 
-	// Step 5: Let user multi-select which profiles to import
-	discovered := make([]ui.DiscoveredProfile, len(allProfiles))
-	for i, p := range allProfiles {
-		discovered[i] = ui.DiscoveredProfile{Profile: p, Name: p.Name}
+// forceNetwork makes tr dial every connection over network (e.g. "tcp4")
+// instead of the dual-stack "tcp".
+func forceNetwork(tr *http.Transport, network string) {
+	dial := tr.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
 	}
+	tr.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+		return dial(ctx, network, addr)
+	}
+}
 
-	selected, err := ui.RunProfileImportSelector(discovered)
+// validateEndpoint checks that an endpoint override, if set, is an absolute
+// https URL without a query or fragment.
+func validateEndpoint(endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+	u, err := url.Parse(endpoint)
 	if err != nil {
-		return nil, nil, err
+		return fmt.Errorf("invalid endpoint URL: %w", err)
 	}
-
-	// Step 6: Save all selected profiles in one batch
-	profilesToSave := make([]profile.SSOProfile, len(selected))
-	for i, d := range selected {
-		p := d.Profile
-		p.Name = d.Name
-		profilesToSave[i] = p
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("endpoint must be an https:// URL, got %q", endpoint)
 	}
-	if err := config.SaveProfiles(profilesToSave); err != nil {
-		return nil, nil, fmt.Errorf("failed to save profiles: %w", err)
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("endpoint must not have a query or fragment, got %q", endpoint)
 	}
+	return nil
+}
+
+// cachedOrLogin returns a valid cached SSO token for p, logging in if there
+// is none.
+func cachedOrLogin(ctx context.Context, cfg aws.Config, p *profile.SSOProfile) (*auth.TokenResult, error) {
+	if cached := cachedToken(p); cached != nil {
+		return &auth.TokenResult{AccessToken: cached.AccessToken, ExpiresAt: cached.ExpiresAt}, nil
+	}
+	return loginAndCache(ctx, cfg, p)
+}
+
+// cachedToken returns the valid cached SSO token for p, or nil if there is
+// none or it expires within --reauth-threshold.
+func cachedToken(p *profile.SSOProfile) *config.SSOToken {
+	cached := config.ReadSSOCacheForProfile(p)
+	if cached == nil {
+		return nil
+	}
+	if needsReauth(cached.ExpiresAt, *flagReauth, time.Now()) {
+		fmt.Fprintln(ui.Info(), ui.MutedStyle.Render(fmt.Sprintf(
+			"  Cached SSO token has %s, under --reauth-threshold; logging in again", formatRemaining(time.Until(cached.ExpiresAt)))))
+		return nil
+	}
+	return cached
+}
+
+// needsReauth reports whether a token expiring at expires has less than
+// threshold left at now. A zero threshold never asks for a new login.
+func needsReauth(expires time.Time, threshold time.Duration, now time.Time) bool {
+	return threshold > 0 && expires.Sub(now) < threshold
+}
+
+// reauthThresholdWarning warns when a token fresh from login, expiring at
+// expires, is already under threshold, so every later run would log in
+// again. It is empty otherwise.
+func reauthThresholdWarning(threshold time.Duration, expires, now time.Time) string {
+	if !needsReauth(expires, threshold, now) {
+		return ""
+	}
+	return fmt.Sprintf("Warning: --reauth-threshold %s is longer than a new SSO token lasts (%s); every run will log in again",
+		threshold, expires.Sub(now).Round(time.Minute))
+}
+
+// selectLiveRole lists the roles currently granted in p's account and sets
+// p.RoleName to the one choose picks, so --role-select sees newly granted
+// roles without re-running discovery. A single role is used without asking.
+// If SSO rejects the token, a non-nil relogin is used once to get a fresh
+// one, as for GetCredentialsWithRelogin.
+func selectLiveRole(ctx context.Context, client credentials.SSOClient, accessToken string, p *profile.SSOProfile, relogin credentials.ReloginFunc, choose func(roles []string, current string) (string, error)) error {
+	roles, err := credentials.ListAccountRoles(ctx, client, accessToken, p.AccountID)
+	if err != nil && relogin != nil && credentials.IsAuthError(err) {
+		fresh, loginErr := relogin(ctx)
+		if loginErr != nil {
+			return loginErr
+		}
+		roles, err = credentials.ListAccountRoles(ctx, client, fresh, p.AccountID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to list roles for account %s: %w", p.AccountID, err)
+	}
+	if len(roles) == 0 {
+		return fmt.Errorf("no roles are currently available in account %s", p.AccountID)
+	}
+
+	names := make([]string, len(roles))
+	for i, r := range roles {
+		names[i] = r.RoleName
+	}
+	if len(names) == 1 {
+		p.RoleName = names[0]
+		return nil
+	}
+
+	role, err := choose(names, p.RoleName)
+	if err != nil {
+		return err
+	}
+	p.RoleName = role
+	return nil
+}
+
+// openConsole opens a federated AWS console session for p's credentials in
+// the browser, landing on --destination or the console home for --region
+// (the profile's region by default).
+func openConsole(ctx context.Context, p *profile.SSOProfile, creds *credentials.AWSCredentials) error {
+	region := p.Region
+	if *flagRegion != "" {
+		if err := profile.ValidateRegion(*flagRegion); err != nil {
+			return fmt.Errorf("--region: %w", err)
+		}
+		region = *flagRegion
+	}
+
+	consoleURL, err := credentials.ConsoleURL(ctx, http.DefaultClient, creds, credentials.ConsoleDestination(*flagDestination, region))
+	if err != nil {
+		return err
+	}
+	if err := auth.OpenBrowser(consoleURL); err != nil {
+		return fmt.Errorf("cannot open the AWS console: %w", err)
+	}
+	fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Opened the AWS console for "+p.Name))
+	return nil
+}
+
+// activeCredentialsSection returns the credentials file section AWS tools are
+// currently using: AWS_PROFILE if set, otherwise "default".
+func activeCredentialsSection() string {
+	if env := os.Getenv("AWS_PROFILE"); env != "" {
+		return env
+	}
+	return "default"
+}
+
+// needsSwitchConfirm reports whether logging in as target would replace the
+// still-valid credentials of a different active profile.
+func needsSwitchConfirm(active, target string, activeExpires, now time.Time) bool {
+	return active != target && activeExpires.After(now)
+}
+
+// credentialsSection returns the credentials file section p's credentials are
+// written to: --creds-profile if set, otherwise the profile name.
+func credentialsSection(p *profile.SSOProfile) string {
+	if *flagCredsProfile != "" {
+		return *flagCredsProfile
+	}
+	return p.Name
+}
+
+// confirmSwitch asks before replacing still-valid credentials of another
+// section with target's. It returns true when there is nothing to confirm.
+func confirmSwitch(target string) (bool, error) {
+	active := activeCredentialsSection()
+	expires, ok := config.CredentialsExpiration(active)
+	if !ok || !needsSwitchConfirm(active, target, expires, time.Now()) {
+		return true, nil
+	}
+	return ui.Confirm(fmt.Sprintf("Credentials for %s are still valid (%s). Switch to %s?",
+		active, formatRemaining(time.Until(expires)), target))
+}
+
+// obtainCredentials fetches role credentials for p. It uses token if given,
+// otherwise a valid cached SSO token, and only then a fresh device login.
+// loggedIn reports whether a device login was needed along the way.
+func obtainCredentials(ctx context.Context, cfg aws.Config, p *profile.SSOProfile, token *auth.TokenResult) (creds *credentials.AWSCredentials, loggedIn bool, err error) {
+	// If no token yet, check the SSO cache for a valid one. The cache is keyed
+	// by start URL, so always look it up for the selected profile's own URL.
+	fromCache := false
+	if token == nil {
+		if cached := cachedToken(p); cached != nil {
+			fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Using cached SSO token (still valid)"))
+			fmt.Fprintln(ui.Info())
+			token = &auth.TokenResult{
+				AccessToken: cached.AccessToken,
+				ExpiresAt:   cached.ExpiresAt,
+			}
+			fromCache = true
+		}
+	}
+
+	// Authenticate via SSO OIDC if we still don't have a token
+	if token == nil {
+		token, err = loginAndCache(ctx, cfg, p)
+		if err != nil {
+			return nil, false, err
+		}
+		loggedIn = true
+	}
+
+	// A cached token can pass the expiry check but still be rejected by AWS
+	// (e.g. revoked server-side). In that case drop it and log in again once.
+	var relogin credentials.ReloginFunc
+	if fromCache {
+		relogin = reloginRejected(cfg, p, func(*auth.TokenResult) { loggedIn = true })
+	}
+
+	// Fetch temporary credentials
+	creds, err = fetchCredentials(ctx, cfg, p, token, relogin)
+	return creds, loggedIn, err
+}
+
+// runSummary is the --json description of a completed login.
+type runSummary struct {
+	Profile            string    `json:"profile"`
+	AccountID          string    `json:"account_id"`
+	AccountName        string    `json:"account_name,omitempty"`
+	Role               string    `json:"role"`
+	Region             string    `json:"region"`
+	CredentialsSection string    `json:"credentials_section"`
+	Expiration         time.Time `json:"expiration"`
+	AuthRequired       bool      `json:"auth_required"`
+}
+
+// newRunSummary describes the login of p. authRequired reports whether a
+// device login was needed rather than a cached SSO token.
+func newRunSummary(p *profile.SSOProfile, creds *credentials.AWSCredentials, authRequired bool) runSummary {
+	section := p.Name
+	if *flagCredsProfile != "" {
+		section = *flagCredsProfile
+	}
+	return runSummary{
+		Profile:            p.Name,
+		AccountID:          p.AccountID,
+		AccountName:        p.AccountName,
+		Role:               p.RoleName,
+		Region:             p.Region,
+		CredentialsSection: section,
+		Expiration:         creds.Expiration.UTC(),
+		AuthRequired:       authRequired,
+	}
+}
+
+// credentialsOnlyProfile builds the profile for credentials-only mode from
+// --start-url, --sso-region, --account-id, --role, and an optional --region,
+// so ~/.aws/config is never read or written. It returns nil when --start-url
+// is not set. The profile is named by --profile, or after the account and
+// role.
+func credentialsOnlyProfile() (*profile.SSOProfile, error) {
+	if *flagStartURL == "" {
+		// Without --start-url, --account-id picks a saved profile instead.
+		if *flagRole != "" && *flagAccountID == "" {
+			return nil, fmt.Errorf("--role requires --account-id or --start-url")
+		}
+		return nil, nil
+	}
+	if *flagConfigure || *flagRoleSelect || *flagSelect != "" || *flagSelectAccount != "" || *flagSSOSession != "" {
+		return nil, fmt.Errorf("--start-url cannot be combined with --configure, --role-select, --select, --select-account, or --sso-session")
+	}
+	if err := profile.ValidateStartURL(*flagStartURL); err != nil {
+		return nil, fmt.Errorf("--start-url: %w", err)
+	}
+	if err := profile.ValidateRegion(*flagSSORegion); err != nil {
+		return nil, fmt.Errorf("--sso-region: %w", err)
+	}
+	region := *flagSSORegion
+	if *flagRegion != "" {
+		if err := profile.ValidateRegion(*flagRegion); err != nil {
+			return nil, fmt.Errorf("--region: %w", err)
+		}
+		region = *flagRegion
+	}
+	if err := profile.ValidateAccountID(*flagAccountID); err != nil {
+		return nil, fmt.Errorf("--account-id: %w", err)
+	}
+	if err := profile.ValidateRoleName(*flagRole); err != nil {
+		return nil, fmt.Errorf("--role: %w", err)
+	}
+
+	name := *flagProfile
+	if name == "" {
+		name = profile.SuggestProfileName(*flagAccountID, *flagRole)
+	}
+	return &profile.SSOProfile{
+		Name:      name,
+		StartURL:  strings.TrimSpace(*flagStartURL),
+		Region:    region,
+		SSORegion: *flagSSORegion,
+		AccountID: *flagAccountID,
+		RoleName:  *flagRole,
+	}, nil
+}
+
+// ssoSessionConnection resolves an [sso-session <name>] block from the AWS
+// config file into the connection details discovery needs.
+func ssoSessionConnection(name string) (*ui.SSOConnection, error) {
+	s, err := config.LoadSSOSession(name)
+	if err != nil {
+		return nil, err
+	}
+	return &ui.SSOConnection{StartURL: s.StartURL, Region: s.Region}, nil
+}
+
+// resolveProfile determines which SSO profile to use.
+// It may also return a token if authentication happened during discovery.
+// conn, if non-nil, is used for discovery instead of prompting for it.
+func resolveProfile(ctx context.Context, conn *ui.SSOConnection) (*profile.SSOProfile, *auth.TokenResult, error) {
+	// --configure flag: run discovery flow
+	if *flagConfigure {
+		return runDiscoveryFlow(ctx, conn)
+	}
+
+	// --profile flag: look up by name
+	name, fromEnv := requestedProfile()
+	if name != "" && !fromEnv {
+		p, err := lookupProfile(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, nil, nil
+	}
+
+	// Default: load saved profiles and let user pick
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load profiles: %w", err)
+	}
+
+	// --account-id without --start-url: the saved profile for that account
+	if *flagAccountID != "" {
+		p, err := profileForAccount(profiles, *flagAccountID, *flagRole)
+		if err != nil {
+			return nil, nil, err
+		}
+		return p, nil, nil
+	}
+
+	// AWS_PROFILE naming a saws-managed profile acts like an implicit --profile.
+	// Anything else (e.g. a static-key profile) falls through to the selector.
+	if fromEnv {
+		for _, p := range profiles {
+			if p.Name == name {
+				fmt.Fprintln(ui.Info(), ui.MutedStyle.Render("  Using profile "+name+" from AWS_PROFILE"))
+				fmt.Fprintln(ui.Info())
+				return &p, nil, nil
+			}
+		}
+	}
+
+	// No saved profiles: run discovery flow
+	if len(profiles) == 0 && *flagSelectAccount != "" {
+		return nil, nil, fmt.Errorf("account %q not found among saved profiles", *flagSelectAccount)
+	}
+	if len(profiles) == 0 {
+		fmt.Fprintln(ui.Output, ui.WarningStyle.Render("No saved SSO profiles found. Let's discover your accounts!"))
+		fmt.Fprintln(ui.Output)
+		return runDiscoveryFlow(ctx, conn)
+	}
+
+	// Single profile: ask to use it or run discovery
+	if len(profiles) == 1 && *flagSelectAccount == "" {
+		p, err := handleSingleProfile(profiles[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		if p == nil {
+			return runDiscoveryFlow(ctx, conn)
+		}
+		return p, nil, nil
+	}
+
+	// Multiple profiles: fuzzy selector, reopened with the updated profiles
+	// after a rediscovery started from it
+	for {
+		p, rediscover, err := selectProfile(profiles)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !rediscover {
+			// If user chose "new", run discovery
+			if p == nil {
+				return runDiscoveryFlow(ctx, conn)
+			}
+			return p, nil, nil
+		}
+
+		if _, _, err := runDiscoveryFlow(ctx, conn); err != nil {
+			return nil, nil, err
+		}
+		profiles, err = config.LoadProfiles()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load profiles: %w", err)
+		}
+	}
+}
+
+// profileForAccount returns the saved profile for accountID, narrowed to
+// role when it is set. Without a role the account must have exactly one
+// saved profile, so the role is never guessed.
+func profileForAccount(profiles []profile.SSOProfile, accountID, role string) (*profile.SSOProfile, error) {
+	var matches []profile.SSOProfile
+	for _, p := range profiles {
+		if p.AccountID == accountID && (role == "" || p.RoleName == role) {
+			matches = append(matches, p)
+		}
+	}
+	names := make([]string, len(matches))
+	for i, p := range matches {
+		names[i] = p.Name
+	}
+	switch {
+	case len(matches) == 1:
+		return &matches[0], nil
+	case len(matches) == 0 && role != "":
+		return nil, fmt.Errorf("no saved profile for account %s with role %s", accountID, role)
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no saved profile for account %s", accountID)
+	case role != "":
+		return nil, fmt.Errorf("several saved profiles for account %s with role %s (%s); pick one with --profile", accountID, role, strings.Join(names, ", "))
+	default:
+		return nil, fmt.Errorf("account %s has %d saved profiles (%s); pick one with --role", accountID, len(matches), strings.Join(names, ", "))
+	}
+}
+
+// noAltScreen reports whether selectors should render inline, via
+// --no-alt-screen or SAWS_NO_ALT_SCREEN=1.
+func noAltScreen() bool {
+	return *flagNoAltScreen || os.Getenv("SAWS_NO_ALT_SCREEN") == "1"
+}
+
+// noBanner reports whether the banner is turned off, via --no-banner or
+// SAWS_NO_BANNER=1.
+func noBanner() bool {
+	return *flagNoBanner || os.Getenv("SAWS_NO_BANNER") == "1"
+}
+
+// printBanner writes the banner to w, or its one-line form if compact,
+// unless the banner is turned off.
+func printBanner(w io.Writer, compact bool) {
+	switch {
+	case noBanner():
+	case compact:
+		fmt.Fprint(w, ui.CompactBanner())
+	default:
+		fmt.Fprint(w, ui.Banner())
+	}
+}
+
+// requestedProfile returns the profile name requested via --profile or,
+// failing that, the AWS_PROFILE environment variable; fromEnv reports the
+// latter. --select and --select-account opt out of AWS_PROFILE so the selector
+// can still be used, and so does an AWS_PROFILE that saws exported itself
+// (as the shell wrapper does after every login), since the user didn't pick it.
+func requestedProfile() (name string, fromEnv bool) {
+	if *flagProfile != "" {
+		return *flagProfile, false
+	}
+	if *flagSelect != "" || *flagSelectAccount != "" {
+		return "", false
+	}
+	if env := os.Getenv("AWS_PROFILE"); env != "" && env != os.Getenv(credentials.ExportedProfileVar) {
+		return env, true
+	}
+	return "", false
+}
+
+// lookupProfile finds a saved profile by name.
+func lookupProfile(name string) (*profile.SSOProfile, error) {
+	p, err := config.FindProfile(name)
+	var ambiguous *config.AmbiguousProfileError
+	if errors.As(err, &ambiguous) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load profiles: %w", err)
+	}
+	if p == nil {
+		return nil, fmt.Errorf("profile %q not found in ~/.aws/config", name)
+	}
+	return p, nil
+}
+
+// handleSingleProfile handles the case where exactly one profile exists.
+func handleSingleProfile(p profile.SSOProfile) (*profile.SSOProfile, error) {
+	fmt.Fprintf(ui.Output, "%s %s\n\n",
+		ui.SubtitleStyle.Render("Found profile:"),
+		ui.SuccessStyle.Render(p.DisplayName()),
+	)
+
+	if *flagYes {
+		return &p, nil
+	}
+
+	useExisting, err := ui.Confirm("Use this profile?")
+	if err != nil {
+		return nil, err
+	}
+
+	if useExisting {
+		return &p, nil
+	}
+	// Return nil to signal "configure new" — caller handles discovery
+	return nil, nil
+}
+
+// rememberedRole returns the profile last used in the account, or nil if
+// none is recorded or it is no longer saved.
+func rememberedRole(g *profile.AccountGroup, store *usage.Store) *profile.SSOProfile {
+	name := store.LastRole(g.AccountID)
+	for i := range g.Roles {
+		if name != "" && g.Roles[i].Name == name {
+			return &g.Roles[i]
+		}
+	}
+	return nil
+}
+
+// selectProfile runs the fuzzy selector for multiple profiles.
+// Returns nil profile if user chose "configure new", and rediscover if they
+// asked to run discovery and then pick again.
+func selectProfile(profiles []profile.SSOProfile) (p *profile.SSOProfile, rediscover bool, err error) {
+	store, err := usage.Load()
+	if err != nil {
+		return nil, false, err
+	}
+	if order, _ := usage.ParseOrder(*flagSort); order != usage.OrderNone {
+		profiles = store.SortProfiles(profiles, order)
+	}
+
+	// --select-account: skip the account level, or the TUI entirely for a
+	// single-role account or, with --remember-role, a remembered role
+	if *flagSelectAccount != "" {
+		g, ok := profile.FindAccountGroup(profile.GroupByAccount(profiles), *flagSelectAccount)
+		if !ok {
+			return nil, false, fmt.Errorf("account %q not found among saved profiles", *flagSelectAccount)
+		}
+		if len(g.Roles) == 1 {
+			return &g.Roles[0], false, nil
+		}
+		if p := rememberedRole(g, store); p != nil && *flagRememberRole {
+			return p, false, nil
+		}
+	}
+
+	// --select with --yes: skip the TUI when the filter is unambiguous
+	if *flagSelect != "" && *flagYes {
+		if matches := ui.FilterProfiles(profiles, *flagSelect); len(matches) == 1 {
+			return &matches[0], false, nil
+		}
+	}
+
+	result, err := ui.RunProfileSelector(profiles, ui.SelectorOptions{
+		InitialFilter: *flagSelect,
+		Account:       *flagSelectAccount,
+		ShowEmail:     *flagAccountEmail,
+		LastRoles:     store.LastRoles,
+		RememberRole:  *flagRememberRole,
+		Delete:        config.DeleteProfile,
+		Compact:       *flagCompact,
+		IdleTimeout:   *flagIdleTimeout,
+		NoAltScreen:   noAltScreen(),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if result.IsNew {
+		return nil, false, nil
+	}
+	return result.Profile, result.Rediscover, nil
+}
+
+// resolveSSORegion fills in the SSO region of a profile loaded without one
+// (for example an sso-session reference that did not resolve) from the
+// region its start URL was last logged in with, falling back to its region.
+func resolveSSORegion(p *profile.SSOProfile) error {
+	if !p.SSORegionMissing {
+		return nil
+	}
+	if r := config.CachedSSORegion(p.StartURL); r != "" {
+		p.SSORegion = r
+		return nil
+	}
+	if p.SSOClientRegion() != "" {
+		return nil
+	}
+	return fmt.Errorf("profile %q has no sso_region or region and no cached login records one; set sso_region for it in ~/.aws/config", p.Name)
+}
+
+// settingsRegionFallback wraps a cached-region lookup so start URLs without
+// a cached region fall back to the configured default region, and then to
+// lastRegion, the SSO region used for the previous setup.
+func settingsRegionFallback(cached func(startURL string) string, lastRegion string) func(startURL string) string {
+	return func(startURL string) string {
+		if r := cached(startURL); r != "" {
+			return r
+		}
+		if userSettings.Region != "" {
+			return userSettings.Region
+		}
+		return lastRegion
+	}
+}
+
+// lastUsedRegion returns the SSO region recorded by the last discovery, or ""
+// if there is none.
+func lastUsedRegion() string {
+	store, err := usage.Load()
+	if err != nil {
+		return ""
+	}
+	return store.LastRegion
+}
+
+// runDiscoveryFlow guides the user through SSO setup using auto-discovery.
+// It asks for minimal info (URL + region), authenticates, discovers ALL accounts
+// and roles, lets the user multi-select which to import, saves them all, then
+// drops into the normal profile selector to pick one to use now.
+func runDiscoveryFlow(ctx context.Context, conn *ui.SSOConnection) (*profile.SSOProfile, *auth.TokenResult, error) {
+	// Step 1: Ask for SSO Start URL and Region, unless already known
+	if conn == nil {
+		var err error
+		conn, err = ui.RunSSOConnectionForm(nil, settingsRegionFallback(config.CachedSSORegion, lastUsedRegion()))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Step 2: Authenticate via SSO OIDC, offering to pick the region again
+	// when its endpoint cannot be reached
+	var cfg aws.Config
+	var token *auth.TokenResult
+	for {
+		// Load AWS config once for both OIDC and SSO clients
+		var err error
+		cfg, err = awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(conn.Region))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load AWS config: %w", err)
+		}
+
+		token, err = auth.Authenticate(
+			ctx,
+			auth.NewOIDCClientFromConfig(cfg),
+			conn.StartURL,
+			warnRegionMismatch(showDeviceAuth(os.Stdout), conn.Region),
+			showStatus,
+			authOptions()...,
+		)
+		if err == nil {
+			break
+		}
+		if !auth.IsEndpointError(err) {
+			return nil, nil, err
+		}
+		fmt.Fprintln(ui.Output, ui.WarningStyle.Render(fmt.Sprintf("  Could not reach AWS SSO in %s; the SSO region may be wrong", conn.Region)))
+		region, pickErr := ui.SelectRegion(conn.Region)
+		if pickErr != nil {
+			return nil, nil, err
+		}
+		conn = &ui.SSOConnection{StartURL: conn.StartURL, Region: region}
+	}
+
+	fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Authentication successful!"))
+	fmt.Fprintln(ui.Info())
+
+	// The region worked, so offer it first next time.
+	if err := usage.RecordRegion(conn.Region); err != nil {
+		fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not record SSO region: "+err.Error()))
+	}
+
+	// Cache the token for other AWS tools
+	if cacheErr := cacheToken(conn.StartURL, conn.Region, token); cacheErr != nil {
+		fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not write SSO cache: "+cacheErr.Error()))
+	}
+
+	// Step 3: Discover all accounts and their roles
+	ssoClient := credentials.NewSSOClientFromConfig(cfg)
+
+	fmt.Fprintln(ui.Info(), ui.MutedStyle.Render("  Discovering accounts..."))
+
+	result, err := discovery.Discover(ctx, ssoClient, token.AccessToken, conn.StartURL, conn.Region, discovery.Options{
+		OnAccounts: func(accounts []credentials.DiscoveredAccount) {
+			fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render(fmt.Sprintf("  Found %d account(s)", len(accounts))))
+			fmt.Fprintln(ui.Info(), ui.MutedStyle.Render("  Discovering roles..."))
+		},
+		MaxAccounts:  *flagMaxAccounts,
+		Concurrency:  userSettings.Concurrency,
+		NameTemplate: *flagNameTemplate,
+	})
+	if err != nil {
+		if hint := discoveryHint(err, conn); hint != "" {
+			fmt.Fprintln(ui.Output)
+			fmt.Fprintln(ui.Output, hint)
+		}
+		return nil, nil, err
+	}
+	allProfiles := result.Profiles
+
+	if result.Truncated() {
+		fmt.Fprintln(ui.Output, ui.WarningStyle.Render(fmt.Sprintf(
+			"  Account limit reached: searched %d of %d accounts (--max-accounts %d)", result.AccountCount, result.TotalAccounts, *flagMaxAccounts)))
+		fmt.Fprintln(ui.Output, ui.MutedStyle.Render("  Raise --max-accounts to include the rest, or type in the import selector to narrow the list"))
+	}
+
+	fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render(fmt.Sprintf("  Found %d profile(s) across %d account(s)", len(allProfiles), result.AccountCount)))
+	if result.EmptyAccounts > 0 {
+		fmt.Fprintln(ui.Info(), ui.MutedStyle.Render(fmt.Sprintf("  %d account(s) had no roles available to you", result.EmptyAccounts)))
+	}
+	fmt.Fprintln(ui.Info())
+
+	if len(userSettings.ExcludeRoles) > 0 {
+		kept := discovery.ExcludeRoles(allProfiles, userSettings.ExcludeRoles)
+		if len(kept) == 0 {
+			fmt.Fprintln(ui.Output, ui.WarningStyle.Render("  Every discovered role is excluded by the exclude_roles setting"))
+			return nil, nil, nil
+		}
+		if skipped := len(allProfiles) - len(kept); skipped > 0 {
+			fmt.Fprintln(ui.Info(), ui.MutedStyle.Render(fmt.Sprintf("  Skipped %d role(s) matching exclude_roles", skipped)))
+			fmt.Fprintln(ui.Info())
+		}
+		allProfiles = kept
+	}
+
+	if roleFilter != nil {
+		allProfiles = discovery.FilterRoles(allProfiles, roleFilter)
+		if len(allProfiles) == 0 {
+			fmt.Fprintln(ui.Output, ui.WarningStyle.Render(fmt.Sprintf("  No discovered role matches --role-filter %q", *flagRoleFilter)))
+			return nil, nil, nil
+		}
+		fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render(fmt.Sprintf("  %d of them match --role-filter", len(allProfiles))))
+		fmt.Fprintln(ui.Info())
+	}
+
+	if *flagMerge {
+		existing, err := config.LoadProfiles()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load profiles: %w", err)
+		}
+		allProfiles = discovery.NewProfiles(allProfiles, existing)
+		if len(allProfiles) == 0 {
+			fmt.Fprintln(ui.Output, ui.SuccessStyle.Render("  No new roles: every discovered account/role is already saved"))
+			return nil, nil, nil
+		}
+		fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render(fmt.Sprintf("  %d of them are new", len(allProfiles))))
+		fmt.Fprintln(ui.Info())
+	}
+
+	// Step 5: Let user multi-select which profiles to import
+	discovered := make([]ui.DiscoveredProfile, len(allProfiles))
+	for i, p := range allProfiles {
+		discovered[i] = ui.DiscoveredProfile{Profile: p, Name: p.Name}
+	}
+
+	selected, err := ui.RunProfileImportSelector(discovered, ui.SelectorOptions{
+		IdleTimeout: *flagIdleTimeout,
+		NoAltScreen: noAltScreen(),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Step 6: Save all valid selected profiles in one batch
+	profilesToSave := selectedProfiles(selected, ui.Output)
+	if len(profilesToSave) == 0 {
+		fmt.Fprintln(ui.Output, ui.MutedStyle.Render("  Nothing saved"))
+		return nil, nil, nil
+	}
+
+	// Step 7: Confirm before touching ~/.aws/config
+	fmt.Fprintln(ui.Output)
+	fmt.Fprint(ui.Output, formatSaveSummary(profilesToSave))
+	fmt.Fprintln(ui.Output)
+	if !*flagYes {
+		ok, err := ui.Confirm(fmt.Sprintf("Save %d profile(s) to ~/.aws/config?", len(profilesToSave)))
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			fmt.Fprintln(ui.Output, ui.MutedStyle.Render("  Nothing saved"))
+			return nil, nil, nil
+		}
+	}
+
+	if err := config.SaveProfiles(profilesToSave); err != nil {
+		return nil, nil, fmt.Errorf("failed to save profiles: %w", err)
+	}
+
+	recordChange(config.Path, fmt.Sprintf("saved %d profile(s)", len(profilesToSave)))
+
+	fmt.Fprintln(ui.Info())
+	fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render(fmt.Sprintf("  Saved %d profile(s) to ~/.aws/config", len(profilesToSave))))
+	fmt.Fprintln(ui.Info())
+	fmt.Fprintln(ui.Info(), ui.SubtitleStyle.Render("Run saws again to select a profile and log in."))
+	fmt.Fprintln(ui.Info())
+
+	// Return nil profile + nil error to signal "done, nothing more to do"
+	return nil, nil, nil
+}
+
+// selectedProfiles turns the import selection into profiles named as edited,
+// skipping (and reporting on w) any that fail validation so a bad selection
+// never writes a malformed section to ~/.aws/config.
+func selectedProfiles(selected []ui.DiscoveredProfile, w io.Writer) []profile.SSOProfile {
+	var profiles []profile.SSOProfile
+	for _, d := range selected {
+		p := d.Profile
+		p.Name = d.Name
+		if err := p.Validate(); err != nil {
+			fmt.Fprintln(w, ui.WarningStyle.Render(fmt.Sprintf("  Skipping invalid profile %q: %v", p.Name, err)))
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// discoveryHint explains discovery failures that come from how IAM Identity
+// Center is set up rather than from saws: the login worked, but no accounts
+// came back or listing them was denied. It returns "" for other errors.
+func discoveryHint(err error, conn *ui.SSOConnection) string {
+	var why string
+	var apiErr smithy.APIError
+	switch {
+	case errors.Is(err, discovery.ErrNoAccounts):
+		why = "Login succeeded, but SSO returned no accounts. Usually no permission set is\n" +
+			"assigned to you yet, or your access does not allow sso:ListAccounts."
+	case errors.As(err, &apiErr) && (apiErr.ErrorCode() == "ForbiddenException" || strings.HasPrefix(apiErr.ErrorCode(), "AccessDenied")):
+		why = "Login succeeded, but listing accounts was denied; your access likely lacks\n" +
+			"sso:ListAccounts."
+	default:
+		return ""
+	}
+	manual := fmt.Sprintf("saws --start-url %s --sso-region %s --account-id <id> --role <role>", conn.StartURL, conn.Region)
+	return ui.WarningStyle.Render(why) + "\n" +
+		ui.MutedStyle.Render("If you know the account and role, log in to it directly:") + "\n" +
+		ui.MutedStyle.Render("  "+manual)
+}
+
+// formatSaveSummary renders the profiles about to be saved by discovery, one
+// per line with their account and role.
+func formatSaveSummary(profiles []profile.SSOProfile) string {
+	var b strings.Builder
+	b.WriteString(ui.SubtitleStyle.Render(fmt.Sprintf("About to save %d profile(s):", len(profiles))) + "\n")
+	w := tabwriter.NewWriter(&b, 0, 0, 3, ' ', 0)
+	for _, p := range profiles {
+		account := p.AccountID
+		if p.AccountName != "" {
+			account = p.AccountName + " (" + p.AccountID + ")"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", p.Name, account, p.RoleName)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// fileChange is one file saws wrote during a run, with what changed in it.
+type fileChange struct {
+	Path string
+	What string
+}
+
+// changes collects the files written during this run, for the summary
+// printed at the end.
+var changes []fileChange
+
+// recordChange notes that the file at path, resolved by pathFn, was written.
+// A path that cannot be resolved is left out of the summary.
+func recordChange(pathFn func() (string, error), what string) {
+	path, err := pathFn()
+	if err != nil {
+		return
+	}
+	changes = append(changes, fileChange{Path: path, What: what})
+}
+
+// formatChanges renders the files written during a run, one per line with a
+// description of the change, or "" when nothing was written.
+func formatChanges(changes []fileChange) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(ui.SubtitleStyle.Render("Files changed:") + "\n")
+	w := tabwriter.NewWriter(&b, 0, 0, 3, ' ', 0)
+	for _, c := range changes {
+		fmt.Fprintf(w, "  %s\t%s\n", c.Path, c.What)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// printChanges shows the files written during this run. Export modes keep
+// their output to the credentials themselves, so it is skipped there.
+func printChanges() {
+	if *flagExport {
+		return
+	}
+	if out := formatChanges(changes); out != "" {
+		fmt.Fprint(ui.Info(), out)
+		fmt.Fprintln(ui.Info())
+	}
+}
+
+// deviceAuthIdPHint returns identity-provider guidance for the device auth
+// box, from --idp or detected from the login URLs.
+func deviceAuthIdPHint(info auth.DeviceAuthInfo) string {
+	idp := *flagIdP
+	if idp == "" {
+		idp = auth.DetectIdP(info.StartURL, info.VerificationURI)
+	}
+	return auth.IdPHint(idp)
+}
+
+// warnRegionMismatch wraps a device authorization callback to also warn when
+// the verification URL names a different SSO region than region, a common
+// cause of credential fetches failing after the login itself succeeded.
+func warnRegionMismatch(show func(auth.DeviceAuthInfo), region string) func(auth.DeviceAuthInfo) {
+	return func(info auth.DeviceAuthInfo) {
+		show(info)
+		if derived := auth.RegionMismatch(info.VerificationURI, region); derived != "" {
+			fmt.Fprintln(os.Stderr, ui.WarningStyle.Render(fmt.Sprintf(
+				"Warning: the verification URL is in %s but the chosen SSO region is %s; profiles may fail to fetch credentials", derived, region)))
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+}
+
+// showDeviceAuth returns the callback that presents the device authorization
+// details. The styled box goes to ui.Output; with --print-url the bare
+// verification URL is also written to urlOut (stdout) for automation.
+func showDeviceAuth(urlOut io.Writer) func(auth.DeviceAuthInfo) {
+	return func(info auth.DeviceAuthInfo) {
+		if *flagPrintURL {
+			fmt.Fprintln(urlOut, info.VerificationURI)
+		}
+
+		hint := "A browser window should open automatically.\nIf not, open the URL above and enter the code."
+		if *flagPrintURL {
+			hint = "Open the URL above to approve this login."
+		}
+		if *flagManualCode {
+			hint += "\nEnter the user code above when asked."
+		}
+
+		if idpHint := deviceAuthIdPHint(info); idpHint != "" {
+			hint += "\n" + idpHint
+		}
+
+		fmt.Fprintln(ui.Output)
+		fmt.Fprintln(ui.Output, ui.BoxStyle.Render(
+			ui.FormatKeyValue("Verification URL: ", info.VerificationURI)+"\n"+
+				ui.FormatKeyValue("User Code:        ", info.UserCode)+"\n\n"+
+				ui.MutedStyle.Render(hint),
+		))
+		fmt.Fprintln(ui.Output)
+		// Block letters are only legible on a terminal; in logs they are noise.
+		if *flagBigCode && ui.OutputIsTerminal() {
+			fmt.Fprintln(ui.Output, ui.BigCode(info.UserCode))
+			fmt.Fprintln(ui.Output)
+		}
+	}
+}
+
+// showStatus reports auth progress as informational output.
+func showStatus(status string) {
+	fmt.Fprintln(ui.Info(), ui.MutedStyle.Render("  "+status))
+}
+
+// authOptions returns the auth options implied by the command-line flags.
+func authOptions() []auth.Option {
+	opts := []auth.Option{auth.WithTimeout(userSettings.AuthTimeout)}
+	if *flagManualCode {
+		opts = append(opts, auth.WithManualCode())
+	}
+	if userSettings.ConfirmBrowser {
+		opts = append(opts, auth.WithBrowserConfirm(func() (bool, error) {
+			return ui.ConfirmDefault("Open browser to approve?", true)
+		}))
+	}
+	if *flagPrintURL {
+		opts = append(opts, auth.WithoutBrowser())
+	} else {
+		// Let 'o' reopen the browser while waiting for approval.
+		opts = append(opts, auth.WithWaiter(func(ctx context.Context, verificationURI string, poll func(context.Context) error) error {
+			return ui.WaitForApproval(ctx, verificationURI, auth.OpenBrowser, poll)
+		}))
+	}
+	return opts
+}
+
+// authenticate performs the SSO OIDC device auth flow using a pre-loaded AWS config.
+func authenticate(ctx context.Context, cfg aws.Config, p *profile.SSOProfile) (*auth.TokenResult, error) {
+	oidcClient := auth.NewOIDCClientFromConfig(cfg)
+
+	token, err := auth.Authenticate(
+		ctx,
+		oidcClient,
+		p.StartURL,
+		showDeviceAuth(os.Stdout),
+		showStatus,
+		authOptions()...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Authentication successful!"))
+	fmt.Fprintln(ui.Info())
+	return token, nil
+}
+
+// loginAndCache runs the device auth flow for p and caches the resulting token
+// for other AWS tools. Cache write failures are reported but not fatal.
+func loginAndCache(ctx context.Context, cfg aws.Config, p *profile.SSOProfile) (*auth.TokenResult, error) {
+	token, err := authenticate(ctx, cfg, p)
+	if err != nil {
+		return nil, err
+	}
+	if warning := reauthThresholdWarning(*flagReauth, token.ExpiresAt, time.Now()); warning != "" {
+		fmt.Fprintln(os.Stderr, ui.WarningStyle.Render(warning))
+	}
+
+	if cacheErr := cacheToken(p.StartURL, p.SSOClientRegion(), token); cacheErr != nil {
+		fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not write SSO cache: "+cacheErr.Error()))
+	}
+	return token, nil
+}
+
+// cacheToken writes token to the SSO cache in the full AWS CLI schema, with
+// the client registration and any refresh token, so the AWS CLI can reuse it.
+func cacheToken(startURL, region string, token *auth.TokenResult) error {
+	err := config.WriteSSOToken(config.SSOToken{
+		StartURL:              startURL,
+		Region:                region,
+		AccessToken:           token.AccessToken,
+		ExpiresAt:             token.ExpiresAt,
+		ClientID:              token.ClientID,
+		ClientSecret:          token.ClientSecret,
+		RegistrationExpiresAt: token.RegistrationExpiresAt,
+		RefreshToken:          token.RefreshToken,
+	})
+	if err == nil {
+		recordChange(func() (string, error) { return config.SSOCacheFilepath(startURL) }, "cached SSO token for "+startURL)
+	}
+	return err
+}
+
+// reloginRejected returns a ReloginFunc that drops p's cached SSO token
+// after SSO rejected it and logs in again, handing the new token to done.
+func reloginRejected(cfg aws.Config, p *profile.SSOProfile, done func(*auth.TokenResult)) credentials.ReloginFunc {
+	return func(ctx context.Context) (string, error) {
+		fmt.Fprintln(ui.Output, ui.WarningStyle.Render("  Cached SSO token was rejected, re-authenticating..."))
+		if err := config.DeleteSSOCache(p.StartURL); err != nil {
+			fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not remove SSO cache: "+err.Error()))
+		}
+		fresh, err := loginAndCache(ctx, cfg, p)
+		if err != nil {
+			return "", err
+		}
+		done(fresh)
+		return fresh.AccessToken, nil
+	}
+}
+
+// fetchCredentials retrieves temporary AWS credentials using a pre-loaded AWS config.
+// If relogin is non-nil it is used once to replace a token that SSO rejects.
+func fetchCredentials(ctx context.Context, cfg aws.Config, p *profile.SSOProfile, token *auth.TokenResult, relogin credentials.ReloginFunc) (*credentials.AWSCredentials, error) {
+	ssoClient := credentials.NewSSOClientFromConfig(cfg)
+
+	creds, err := credentials.GetCredentialsWithRelogin(ctx, ssoClient, token.AccessToken, p.AccountID, p.RoleName, relogin)
+	if err != nil {
+		return nil, err
+	}
+
+	return creds, nil
+}
+
+// exportOut, when set by --export-fd, receives the export output instead of
+// stdout.
+var exportOut io.Writer
+
+// openExportFD returns the already-open file descriptor fd for --export-fd.
+// Descriptors 0-2 are rejected: stdout is the default, and the others would
+// mix the exports with input or display output.
+func openExportFD(fd int) (*os.File, error) {
+	if fd < 3 {
+		return nil, fmt.Errorf("--export-fd must be 3 or higher (use --export for stdout)")
+	}
+	f := os.NewFile(uintptr(fd), fmt.Sprintf("fd %d", fd))
+	if f == nil {
+		return nil, fmt.Errorf("--export-fd %d: not a valid file descriptor", fd)
+	}
+	if _, err := f.Stat(); err != nil {
+		return nil, fmt.Errorf("--export-fd %d is not open: %w", fd, err)
+	}
+	return f, nil
+}
+
+// warnLoosePerm tells the user that saws is tightening the mode of a file
+// it is about to write.
+func warnLoosePerm(path string, had, want os.FileMode) {
+	fmt.Fprintln(os.Stderr, ui.WarningStyle.Render(fmt.Sprintf("Warning: %s had permissions %04o; restricting to %04o", path, had, want)))
+}
+
+// settingsExportFormat returns the configured export_format. It only changes
+// the default; unlike the flag, it doesn't switch on --export. The shell
+// wrapper evals whatever --export prints, so it always gets the shell format.
+func settingsExportFormat() string {
+	if shell.IsWrapped() {
+		return ""
+	}
+	return userSettings.ExportFormat
+}
+
+// exportCredentials writes credentials to the credentials file and outputs them.
+// In --export mode, export commands go to stdout (for eval) and display goes to
+// ui.Output (which is stderr in export mode).
+func exportCredentials(p *profile.SSOProfile, creds *credentials.AWSCredentials, format credentials.ExportFormat) error {
+	// Always write to ~/.aws/credentials
+	credsSection := credentialsSection(p)
+	// Sections not named after a saved profile must survive check --fix.
+	write := config.WriteCredentials
+	if credsSection != p.Name || *flagStartURL != "" {
+		write = config.WriteStandaloneCredentials
+	}
+	if err := write(credsSection, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, creds.Expiration); err != nil {
+		fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not write to ~/.aws/credentials: "+err.Error()))
+	} else {
+		if credsSection != p.Name {
+			fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Credentials written to ~/.aws/credentials as ["+credsSection+"]"))
+		} else {
+			fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Credentials written to ~/.aws/credentials"))
+		}
+		recordChange(config.CredentialsPath, "updated credentials for profile "+credsSection)
+	}
+	if *flagCLICache {
+		if err := config.WriteCLICache(p, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, creds.Expiration); err != nil {
+			fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not write to ~/.aws/cli/cache: "+err.Error()))
+		} else {
+			recordChange(func() (string, error) { return config.CLICacheFilepath(p) }, "cached CLI credentials for profile "+p.Name)
+		}
+	}
+
+	// Export mode: export commands on stdout, styled display on stderr
+	if *flagExport {
+		out, err := credentials.FormatExport(creds, p.Name, format, onlyKeys, *flagLegacyToken)
+		if err != nil {
+			return err
+		}
+		w := io.Writer(os.Stdout)
+		if exportOut != nil {
+			w = exportOut
+		}
+		fmt.Fprintln(w, out)
+		fmt.Fprintln(ui.Info(), credentials.FormatDisplay(creds, p.Name))
+		fmt.Fprintln(ui.Info())
+		if format == credentials.ExportShell {
+			fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Credentials exported to shell environment"))
+		} else {
+			fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Credentials printed as "+string(format)))
+		}
+		fmt.Fprintln(ui.Info())
+		return nil
+	}
+
+	// Interactive mode: show styled output
+	fmt.Fprintln(ui.Info(), credentials.FormatDisplay(creds, p.Name))
+	fmt.Fprintln(ui.Info())
+
+	if shell.IsWrapped() {
+		fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render("  Credentials exported to shell environment"))
+		fmt.Fprintln(ui.Info())
+		return nil
+	}
+
+	// Not wrapped: suggest using AWS_PROFILE (works now that SSO cache is populated)
+	fmt.Fprintln(ui.Info(), ui.SubtitleStyle.Render("To use this profile in other tools:"))
+	fmt.Fprintln(ui.Info())
+	fmt.Fprintln(ui.Info(), ui.MutedStyle.Render("  export AWS_PROFILE="+p.Name))
+	fmt.Fprintln(ui.Info())
+	fmt.Fprintln(ui.Info(), ui.SubtitleStyle.Render("Or set up auto-export with:"))
+	fmt.Fprintln(ui.Info())
+	fmt.Fprintln(ui.Info(), ui.MutedStyle.Render("  saws init"))
+	fmt.Fprintln(ui.Info())
+
+	return nil
+}
+
+// runLogin handles `saws login [flags]`, an explicit form of the default
+// credential flow that accepts the same flags (e.g. saws login --print-url).
+func runLogin(args []string) error {
+	if err := flag.CommandLine.Parse(args); err != nil {
+		return err
+	}
+	return run()
+}
+
+// runInit handles the `saws init [shell]` subcommand.
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	rcFlag := fs.String("rc", "", "Install into this rc file instead of the shell's default")
+	list := fs.Bool("list", false, "List supported shells, their rc files, and whether the wrapper is installed")
+	sync := fs.Bool("sync", false, "Update the wrapper in every rc file that already has it")
+	addBannerFlag(fs)
+	// Accept the shell name before or after the flags.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = append(args[1:], args[0])
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *list {
+		return writeShellList(os.Stdout)
+	}
+	if *sync {
+		return runInitSync()
+	}
+
+	printBanner(os.Stdout, false)
+
+	var sh shell.Shell
+	var err error
+
+	if fs.NArg() > 0 {
+		sh, err = shell.ParseShell(fs.Arg(0))
+	} else {
+		sh, err = shell.DetectShell()
+	}
+	if err != nil {
+		return err
+	}
+
+	binaryPath, err := shell.BinaryPath()
+	if err != nil {
+		return err
+	}
+
+	rcPath := *rcFlag
+	if rcPath == "" {
+		rcPath, err = shell.RCFile(sh)
+		if err != nil {
+			return err
+		}
+	}
+
+	wasInstalled := shell.IsInstalled(rcPath)
+	changed, err := shell.Install(sh, binaryPath, rcPath)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		fmt.Println(ui.SuccessStyle.Render("Shell wrapper in " + rcPath + " is already up to date"))
+		return nil
+	}
+
+	if wasInstalled {
+		fmt.Println(ui.SuccessStyle.Render("Shell wrapper updated in " + rcPath))
+	} else {
+		fmt.Println(ui.SuccessStyle.Render("Shell wrapper installed in " + rcPath))
+	}
+	fmt.Println()
+	fmt.Println(ui.SubtitleStyle.Render("To activate, restart your shell or run:"))
+	fmt.Println()
+
+	switch sh {
+	case shell.Fish:
+		fmt.Println(ui.MutedStyle.Render("  source " + rcPath))
+	default:
+		fmt.Println(ui.MutedStyle.Render("  source " + rcPath))
+	}
+	fmt.Println()
+	fmt.Println(ui.MutedStyle.Render("After that, just type " +
+		ui.ValueStyle.Render("saws") +
+		ui.MutedStyle.Render(" and credentials will be auto-exported to your shell.")))
+	fmt.Println()
+
+	return nil
+}
+
+// runInitSync handles `saws init --sync`, updating the wrapper everywhere it
+// is installed.
+func runInitSync() error {
+	binaryPath, err := shell.BinaryPath()
+	if err != nil {
+		return err
+	}
+	updated, err := shell.Sync(binaryPath)
+	if err != nil {
+		return err
+	}
+	if len(updated) == 0 {
+		fmt.Println(ui.SuccessStyle.Render("Every installed shell wrapper is already up to date"))
+		return nil
+	}
+	fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("Updated the shell wrapper in %d file(s):", len(updated))))
+	for _, rcPath := range updated {
+		fmt.Println("  " + rcPath)
+	}
+	return nil
+}
+
+// writeShellList writes each supported shell with the rc file `saws init`
+// would use for it and whether the wrapper is installed there.
+func writeShellList(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "SHELL\tRC FILE\tINSTALLED")
+	for _, name := range shell.SupportedShells() {
+		rcPath, err := shell.RCFile(shell.Shell(name))
+		if err != nil {
+			return err
+		}
+		installed := "no"
+		if shell.IsInstalled(rcPath) {
+			installed = "yes"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, rcPath, installed)
+	}
+	return w.Flush()
+}
+
+// pingTimeout bounds each reachability check made by `saws ping`.
+const pingTimeout = 10 * time.Second
+
+// runPing handles `saws ping [--start-url X] [--region Y]`, checking that the
+// SSO OIDC endpoint for the region (and the start URL, when given) can be
+// reached, without registering a client or logging in.
+func runPing(args []string) error {
+	fs := flag.NewFlagSet("ping", flag.ContinueOnError)
+	startURL := fs.String("start-url", "", "SSO start URL to check as well")
+	region := fs.String("region", "", "SSO region (default: the start URL's cached region, then the settings file)")
+	fs.StringVar(flagProxy, "proxy", *flagProxy, "Check through this proxy URL, whatever HTTPS_PROXY says")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := configureEndpoints(); err != nil {
+		return err
+	}
+	proxy, _ := parseProxy(*flagProxy)
+
+	r := *region
+	if r == "" {
+		r = settingsRegionFallback(config.CachedSSORegion, "")(*startURL)
+	}
+	if r == "" {
+		return fmt.Errorf("no SSO region known; pass --region")
+	}
+
+	targets := []string{auth.OIDCEndpoint(r)}
+	if *startURL != "" {
+		targets = append(targets, *startURL)
+	}
+
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	adjustTransport(tr, proxy)
+	client := &http.Client{Timeout: pingTimeout, Transport: tr}
+	ctx := context.Background()
+	failed := false
+	for _, target := range targets {
+		if err := auth.CheckReachable(ctx, client, target); err != nil {
+			fmt.Println(ui.ErrorStyle.Render("✗ " + err.Error()))
+			if hint := proxyHint(err); hint != "" && !failed {
+				fmt.Println(ui.MutedStyle.Render("  " + hint))
+			}
+			failed = true
+			continue
+		}
+		fmt.Println(ui.SuccessStyle.Render("✓ " + target + " is reachable"))
+	}
+	if failed {
+		return fmt.Errorf("SSO endpoints are not reachable")
+	}
+	return nil
+}
+
+// runWireCredentialProcess handles `saws wire-credential-process [--filter
+// glob] [--unwire]`, pointing credential_process in each saws-managed profile
+// at this binary, or removing the lines it added.
+func runWireCredentialProcess(args []string) error {
+	fs := flag.NewFlagSet("wire-credential-process", flag.ContinueOnError)
+	filter := fs.String("filter", "", "Only change profiles whose name matches this glob")
+	unwire := fs.Bool("unwire", false, "Remove the credential_process lines saws added")
+	addFileFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := configureFiles(); err != nil {
+		return err
+	}
+	if _, err := path.Match(*filter, ""); err != nil {
+		return fmt.Errorf("--filter: %w", err)
+	}
+	match := func(name string) bool {
+		ok, _ := path.Match(*filter, name)
+		return *filter == "" || ok
+	}
+
+	var changed []string
+	var err error
+	if *unwire {
+		changed, err = config.UnwireCredentialProcess(match)
+	} else {
+		binaryPath, berr := shell.BinaryPath()
+		if berr != nil {
+			return berr
+		}
+		changed, err = config.WireCredentialProcess(func(name string) string {
+			return credentialProcessCommand(binaryPath, name)
+		}, match)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(changed) == 0 {
+		fmt.Println(ui.MutedStyle.Render("No profiles changed"))
+		return nil
+	}
+	verb := "Wired"
+	if *unwire {
+		verb = "Unwired"
+	}
+	fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("%s credential_process for %d profile(s):", verb, len(changed))))
+	for _, name := range changed {
+		fmt.Println("  " + name)
+	}
+	if !*unwire {
+		fmt.Println(ui.MutedStyle.Render("Use them with AWS_PROFILE=<profile>-process; the SSO profiles themselves are unchanged"))
+	}
+	return nil
+}
+
+// credentialProcessCommand returns the credential_process value that runs
+// binaryPath for the named profile. AWS tools split the value like a shell,
+// so a path containing spaces is quoted.
+func credentialProcessCommand(binaryPath, name string) string {
+	if strings.ContainsAny(binaryPath, " \t") {
+		binaryPath = `"` + binaryPath + `"`
+	}
+	return fmt.Sprintf("%s --credential-process --profile %s", binaryPath, name)
+}
+
+// runCompletion handles `saws completion [--dynamic] [shell]`, printing a
+// completion script that looks up profile names on demand through
+// `saws __complete`. Dynamic scripts are the only kind, so --dynamic is the
+// default and only spells it out.
+func runCompletion(args []string) error {
+	fs := flag.NewFlagSet("completion", flag.ContinueOnError)
+	dynamic := fs.Bool("dynamic", true, "Complete profile names live through saws __complete (the default and only mode)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*dynamic {
+		return fmt.Errorf("--dynamic=false: static completion scripts are not supported")
+	}
+	args = fs.Args()
+
+	var sh shell.Shell
+	var err error
+	if len(args) > 0 {
+		sh, err = shell.ParseShell(args[0])
+	} else {
+		sh, err = shell.DetectShell()
+	}
+	if err != nil {
+		return err
+	}
+	binaryPath, err := shell.BinaryPath()
+	if err != nil {
+		return err
+	}
+	fmt.Print(shell.CompletionScript(sh, binaryPath))
+	return nil
+}
+
+// runComplete handles the hidden `saws __complete profile [partial]` used
+// by completion scripts, printing matching profile names one per line. It
+// prints nothing on errors so a broken config never garbles the shell.
+func runComplete(args []string) error {
+	if len(args) == 0 || args[0] != "profile" {
+		return nil
+	}
+	partial := ""
+	if len(args) > 1 {
+		partial = args[1]
+	}
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(profiles))
+	for i, p := range profiles {
+		names[i] = p.Name
+	}
+	for _, name := range completeProfiles(names, partial) {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// completeProfiles returns the names starting with partial, followed by
+// those only containing it, ignoring case.
+func completeProfiles(names []string, partial string) []string {
+	partial = strings.ToLower(partial)
+	var prefix, substring []string
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, partial):
+			prefix = append(prefix, name)
+		case strings.Contains(lower, partial):
+			substring = append(substring, name)
+		}
+	}
+	return append(prefix, substring...)
+}
+
+// runSessions handles the `saws sessions` subcommand, listing every cached
+// SSO token with its region and remaining validity.
+func runSessions(_ []string) error {
+	tokens, err := config.ListSSOCache()
+	if err != nil {
+		return err
+	}
+
+	if len(tokens) == 0 {
+		fmt.Println(ui.MutedStyle.Render("No cached SSO sessions found."))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "START URL\tREGION\tVALIDITY")
+	for _, t := range tokens {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", t.StartURL, t.Region, formatRemaining(time.Until(t.ExpiresAt)))
+	}
+	return w.Flush()
+}
+
+// runList handles `saws list [--stale-only] [--since d]`, printing saved
+// profiles with their account, role, credentials status, and note.
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	staleOnly := fs.Bool("stale-only", false, "Only list profiles whose credentials are missing or stale")
+	since := fs.Duration("since", 0, "Only count credentials as stale once expired this long (e.g. 24h)")
+	addFileFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := configureFiles(); err != nil {
+		return err
+	}
+	if *since < 0 {
+		return fmt.Errorf("--since must not be negative")
+	}
+
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to load profiles: %w", err)
+	}
+	if len(profiles) == 0 {
+		fmt.Println(ui.MutedStyle.Render("No saved SSO profiles found."))
+		return nil
+	}
+
+	now := time.Now()
+	var listed []profile.SSOProfile
+	var statuses []string
+	for _, p := range profiles {
+		expires, ok := config.CredentialsExpiration(p.Name)
+		status, stale := credentialStatus(expires, ok, now, *since)
+		if *staleOnly && !stale {
+			continue
+		}
+		listed = append(listed, p)
+		statuses = append(statuses, status)
+	}
+	if len(listed) == 0 {
+		fmt.Println(ui.SuccessStyle.Render("No stale profiles."))
+		return nil
+	}
+	return writeProfileList(os.Stdout, listed, statuses)
+}
+
+// credentialStatus describes the credentials last written for a profile,
+// given their recorded expiry (ok is false when there is none). They are
+// stale when missing or expired for at least since.
+func credentialStatus(expires time.Time, ok bool, now time.Time, since time.Duration) (status string, stale bool) {
+	switch {
+	case !ok:
+		return "no credentials", true
+	case expires.After(now):
+		return formatRemaining(expires.Sub(now)), false
+	default:
+		ago := now.Sub(expires)
+		return "expired " + formatAgo(ago) + " ago", ago >= since
+	}
+}
+
+// formatAgo renders a past duration coarsely, e.g. "40m", "5h", or "3d".
+func formatAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	default:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+}
+
+// writeProfileList writes profiles as an aligned table, with statuses[i]
+// describing the credentials of profiles[i].
+func writeProfileList(out io.Writer, profiles []profile.SSOProfile, statuses []string) error {
+	w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tACCOUNT\tROLE\tCREDENTIALS\tNOTE")
+	for i, p := range profiles {
+		account := p.AccountID
+		if p.AccountName != "" {
+			account = p.AccountName + " (" + p.AccountID + ")"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.Name, account, p.RoleName, statuses[i], p.Note)
+	}
+	return w.Flush()
+}
+
+// whichInfo is what `saws which` reports about a profile.
+type whichInfo struct {
+	Profile      string     `json:"profile"`
+	StartURL     string     `json:"start_url"`
+	SSORegion    string     `json:"sso_region"`
+	Region       string     `json:"region"`
+	AccountID    string     `json:"account_id"`
+	AccountName  string     `json:"account_name,omitempty"`
+	Role         string     `json:"role"`
+	Note         string     `json:"note,omitempty"`
+	TokenValid   bool       `json:"token_valid"`
+	TokenExpires *time.Time `json:"token_expires,omitempty"`
+}
+
+// newWhichInfo describes p and its cached SSO token, which may be nil.
+func newWhichInfo(p *profile.SSOProfile, token *config.SSOToken) whichInfo {
+	info := whichInfo{
+		Profile:     p.Name,
+		StartURL:    p.StartURL,
+		SSORegion:   p.SSOClientRegion(),
+		Region:      p.Region,
+		AccountID:   p.AccountID,
+		AccountName: p.AccountName,
+		Role:        p.RoleName,
+		Note:        p.Note,
+	}
+	if token != nil {
+		expires := token.ExpiresAt.UTC()
+		info.TokenValid = true
+		info.TokenExpires = &expires
+	}
+	return info
+}
+
+// formatWhich renders info as a key/value block.
+func formatWhich(info whichInfo, now time.Time) string {
+	token := "none (a login will be needed)"
+	if info.TokenValid {
+		token = "valid, " + formatRemaining(info.TokenExpires.Sub(now))
+	}
+	accountName := info.AccountName
+	if accountName == "" {
+		accountName = "-"
+	}
+
+	rows := [][2]string{
+		{"Profile:      ", info.Profile},
+		{"Start URL:    ", info.StartURL},
+		{"SSO region:   ", info.SSORegion},
+		{"Region:       ", info.Region},
+		{"Account ID:   ", info.AccountID},
+		{"Account name: ", accountName},
+		{"Role:         ", info.Role},
+	}
+	if info.Note != "" {
+		rows = append(rows, [2]string{"Note:         ", info.Note})
+	}
+	rows = append(rows, [2]string{"SSO token:    ", token})
+
+	var b strings.Builder
+	for _, r := range rows {
+		b.WriteString(ui.FormatKeyValue(r[0], r[1]) + "\n")
+	}
+	return b.String()
+}
+
+// runWhich handles `saws which <name> [--json]`, showing what a saved profile
+// resolves to and whether its start URL has a valid cached SSO token. It
+// never logs in.
+func runWhich(args []string) error {
+	fs := flag.NewFlagSet("which", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the details as JSON")
+	// Accept the profile name before or after the flags.
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		args = append(args[1:], args[0])
+	}
+	addFileFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := configureFiles(); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: saws which <profile> [--json]")
+	}
+
+	p, err := lookupProfile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	info := newWhichInfo(p, config.ReadSSOCacheForProfile(p))
+
+	if *asJSON {
+		out, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	fmt.Print(formatWhich(info, time.Now()))
+	return nil
+}
+
+// runNote handles `saws note <name> [text]`, setting a profile's note, or
+// clearing it when no text is given.
+func runNote(args []string) error {
+	fs := flag.NewFlagSet("note", flag.ContinueOnError)
+	addFileFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := configureFiles(); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) < 1 {
+		return fmt.Errorf("usage: saws note <profile> [text]")
+	}
+	note := strings.TrimSpace(strings.Join(args[1:], " "))
+	if strings.ContainsAny(note, "\r\n") {
+		return fmt.Errorf("note must be a single line")
+	}
+	if err := config.SetProfileNote(args[0], note); err != nil {
+		return err
+	}
+	if note == "" {
+		fmt.Println(ui.SuccessStyle.Render("Cleared note for " + args[0]))
+	} else {
+		fmt.Println(ui.SuccessStyle.Render("Saved note for " + args[0]))
+	}
+	return nil
+}
+
+// formatRemaining renders a remaining duration as e.g. "7h12m left", or
+// "expired" for non-positive durations.
+func formatRemaining(d time.Duration) string {
+	if d <= 0 {
+		return "expired"
+	}
+	d = d.Truncate(time.Minute)
+	if d < time.Minute {
+		return "<1m left"
+	}
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	if h == 0 {
+		return fmt.Sprintf("%dm left", m)
+	}
+	return fmt.Sprintf("%dh%02dm left", h, m)
+}
+
+// runForgetUsage handles the `saws forget-usage` subcommand, deleting the
+// local profile usage statistics used by --sort.
+func runForgetUsage(_ []string) error {
+	if err := usage.Forget(); err != nil {
+		return err
+	}
+	fmt.Println(ui.SuccessStyle.Render("Profile usage history cleared"))
+	return nil
+}
+
+// runCache handles "saws cache show", which prints where SSO tokens are cached
+// so they can be inspected when debugging a login.
+func runCache(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return fmt.Errorf("usage: saws cache show [--profile <name>] [--cat]")
+	}
+
+	fs := flag.NewFlagSet("cache show", flag.ContinueOnError)
+	name := fs.String("profile", "", "Show the cache file for this saved profile")
+	cat := fs.Bool("cat", false, "Also print the cache file contents with tokens redacted")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if *name == "" {
+		if *cat {
+			return fmt.Errorf("--cat requires --profile")
+		}
+		dir, err := config.SSOCacheDir()
+		if err != nil {
+			return err
+		}
+		fmt.Println(dir)
+		return nil
+	}
+
+	p, err := lookupProfile(*name)
+	if err != nil {
+		return err
+	}
+	path, err := config.SSOCacheFilepath(p.StartURL)
+	if err != nil {
+		return err
+	}
+	fmt.Println(path)
+	if !*cat {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read SSO cache file: %w", err)
+	}
+	redacted, err := redactCacheJSON(data)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(redacted))
+	return nil
+}
+
+// cacheSecretKeys are SSO cache fields that must never be printed in full.
+var cacheSecretKeys = []string{"accessToken", "refreshToken", "clientSecret"}
+
+// redactCacheJSON re-indents an SSO cache file with its secrets truncated.
+// Values short enough that TruncateToken would leave them whole are replaced.
+func redactCacheJSON(data []byte) ([]byte, error) {
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("cannot parse SSO cache file: %w", err)
+	}
+	for _, k := range cacheSecretKeys {
+		v, ok := fields[k].(string)
+		if !ok {
+			continue
+		}
+		if len(v) <= 40 {
+			fields[k] = "<redacted>"
+		} else {
+			fields[k] = credentials.TruncateToken(v)
+		}
+	}
+	return json.MarshalIndent(fields, "", "  ")
+}
+
+// watchRefreshMargin is how long before expiry saws watch fetches new
+// credentials, and watchMinWait keeps a short-lived session from spinning.
+const (
+	watchRefreshMargin = 5 * time.Minute
+	watchMinWait       = 30 * time.Second
+)
+
+// watchClock abstracts time for watchCredentials so tests can drive it.
+type watchClock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
 
-	fmt.Fprintln(ui.Output)
-	fmt.Fprintln(ui.Output, ui.SuccessStyle.Render(fmt.Sprintf("  Saved %d profile(s) to ~/.aws/config", len(selected))))
-	fmt.Fprintln(ui.Output)
-	fmt.Fprintln(ui.Output, ui.SubtitleStyle.Render("Run saws again to select a profile and log in."))
-	fmt.Fprintln(ui.Output)
+// watchCredentials calls refresh, which returns the new credentials' expiry,
+// and then again shortly before each expiry until ctx is cancelled.
+func watchCredentials(ctx context.Context, clock watchClock, refresh func(ctx context.Context) (time.Time, error)) error {
+	for {
+		expires, err := refresh(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
 
-	// Return nil profile + nil error to signal "done, nothing more to do"
-	return nil, nil, nil
+		wait := expires.Sub(clock.Now()) - watchRefreshMargin
+		if wait < watchMinWait {
+			wait = watchMinWait
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-clock.After(wait):
+		}
+	}
 }
 
-// authenticate performs the SSO OIDC device auth flow using a pre-loaded AWS config.
-func authenticate(ctx context.Context, cfg aws.Config, p *profile.SSOProfile) (*auth.TokenResult, error) {
-	oidcClient := auth.NewOIDCClientFromConfig(cfg)
+// runWatch handles "saws watch --profile <name>": it keeps the profile's
+// section in ~/.aws/credentials fresh until interrupted.
+func runWatch(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	name := fs.String("profile", "", "Saved profile to keep refreshed")
+	addBannerFlag(fs)
+	addFileFlags(fs)
+	addEndpointFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := configureFiles(); err != nil {
+		return err
+	}
+	if err := configureEndpoints(); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("usage: saws watch --profile <name>")
+	}
 
-	token, err := auth.Authenticate(
-		ctx,
-		oidcClient,
-		p.StartURL,
-		func(info auth.DeviceAuthInfo) {
-			fmt.Fprintln(ui.Output)
-			fmt.Fprintln(ui.Output, ui.BoxStyle.Render(
-				ui.FormatKeyValue("Verification URL: ", info.VerificationURI)+"\n"+
-					ui.FormatKeyValue("User Code:        ", info.UserCode)+"\n\n"+
-					ui.MutedStyle.Render("A browser window should open automatically.\nIf not, open the URL above and enter the code."),
-			))
-			fmt.Fprintln(ui.Output)
-		},
-		func(status string) {
-			fmt.Fprintln(ui.Output, ui.MutedStyle.Render("  "+status))
-		},
-	)
+	p, err := lookupProfile(*name)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	if err := resolveSSORegion(p); err != nil {
+		return err
 	}
 
-	fmt.Fprintln(ui.Output, ui.SuccessStyle.Render("  Authentication successful!"))
-	fmt.Fprintln(ui.Output)
-	return token, nil
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(p.SSOClientRegion()))
+	if err != nil {
+		return fmt.Errorf("failed to load AWS config: %w", err)
+	}
+
+	printBanner(ui.Info(), false)
+	fmt.Fprintln(ui.Info(), ui.MutedStyle.Render("  Keeping ~/.aws/credentials fresh for "+p.Name+" (Ctrl-C to stop)"))
+	fmt.Fprintln(ui.Info())
+
+	return watchCredentials(ctx, realClock{}, func(ctx context.Context) (time.Time, error) {
+		creds, _, err := obtainCredentials(ctx, cfg, p, nil)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if err := config.WriteCredentials(p.Name, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, creds.Expiration); err != nil {
+			return time.Time{}, fmt.Errorf("could not write to ~/.aws/credentials: %w", err)
+		}
+		fmt.Fprintln(ui.Info(), ui.SuccessStyle.Render(fmt.Sprintf("  %s  Refreshed %s, valid until %s",
+			time.Now().Format("15:04:05"), p.Name, creds.Expiration.Local().Format("15:04:05"))))
+		return creds.Expiration, nil
+	})
 }
 
-// fetchCredentials retrieves temporary AWS credentials using a pre-loaded AWS config.
-func fetchCredentials(ctx context.Context, cfg aws.Config, p *profile.SSOProfile, token *auth.TokenResult) (*credentials.AWSCredentials, error) {
-	ssoClient := credentials.NewSSOClientFromConfig(cfg)
+// runValidate handles "saws validate": a read-only lint of the saws-managed
+// profiles in ~/.aws/config. It fails if any profile has a problem.
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	addFileFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := configureFiles(); err != nil {
+		return err
+	}
 
-	creds, err := credentials.GetCredentials(ctx, ssoClient, token.AccessToken, p.AccountID, p.RoleName)
+	issues, err := config.ValidateConfig()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return creds, nil
+	if len(issues) == 0 {
+		fmt.Println(ui.SuccessStyle.Render("All saws profiles are valid"))
+		return nil
+	}
+
+	for _, is := range issues {
+		fmt.Println(ui.ErrorStyle.Render("  ✗ "+is.Profile) + ui.MutedStyle.Render(": "+is.Problem))
+	}
+	return fmt.Errorf("%d problem(s) found in the AWS config", len(issues))
 }
 
-// exportCredentials writes credentials to the credentials file and outputs them.
-// In --export mode, export commands go to stdout (for eval) and display goes to
-// ui.Output (which is stderr in export mode).
-func exportCredentials(p *profile.SSOProfile, creds *credentials.AWSCredentials) error {
-	// Always write to ~/.aws/credentials
-	if err := config.WriteCredentials(p.Name, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken); err != nil {
-		fmt.Fprintln(os.Stderr, ui.WarningStyle.Render("Warning: could not write to ~/.aws/credentials: "+err.Error()))
-	} else {
-		fmt.Fprintln(ui.Output, ui.SuccessStyle.Render("  Credentials written to ~/.aws/credentials"))
+// runCheck handles `saws check [--fix]`, reporting where ~/.aws/credentials
+// and the saws profiles have drifted apart. --fix removes orphaned
+// credentials sections; expired or missing credentials need a login.
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fix := fs.Bool("fix", false, "Remove credentials sections that no longer have a profile")
+	addFileFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := configureFiles(); err != nil {
+		return err
 	}
 
-	// Export mode: export commands on stdout, styled display on stderr
-	if *flagExport {
-		fmt.Println(credentials.FormatExportCommands(creds, p.Name))
-		fmt.Fprintln(ui.Output, credentials.FormatDisplay(creds, p.Name))
-		fmt.Fprintln(ui.Output)
-		fmt.Fprintln(ui.Output, ui.SuccessStyle.Render("  Credentials exported to shell environment"))
-		fmt.Fprintln(ui.Output)
+	report, err := config.CheckDrift(time.Now())
+	if err != nil {
+		return err
+	}
+	if report.Clean() {
+		fmt.Println(ui.SuccessStyle.Render("~/.aws/credentials and ~/.aws/config agree"))
 		return nil
 	}
 
-	// Interactive mode: show styled output
-	fmt.Fprintln(ui.Output, credentials.FormatDisplay(creds, p.Name))
-	fmt.Fprintln(ui.Output)
+	for _, name := range report.Orphaned {
+		fmt.Println(ui.WarningStyle.Render("  ! "+name) + ui.MutedStyle.Render(": credentials section has no saws profile"))
+	}
+	for _, name := range report.Missing {
+		fmt.Println(ui.MutedStyle.Render("  - " + name + ": no credentials written yet"))
+	}
+	for _, name := range report.Expired {
+		fmt.Println(ui.MutedStyle.Render("  - " + name + ": credentials expired"))
+	}
 
-	if shell.IsWrapped() {
-		fmt.Fprintln(ui.Output, ui.SuccessStyle.Render("  Credentials exported to shell environment"))
-		fmt.Fprintln(ui.Output)
+	if len(report.Orphaned) == 0 {
 		return nil
 	}
+	if !*fix {
+		fmt.Println()
+		fmt.Println(ui.MutedStyle.Render("Run saws check --fix to remove the orphaned credentials sections."))
+		return fmt.Errorf("%d orphaned credentials section(s) found", len(report.Orphaned))
+	}
+	if err := config.PruneCredentials(report.Orphaned); err != nil {
+		return err
+	}
+	fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("Removed %d orphaned credentials section(s)", len(report.Orphaned))))
+	return nil
+}
 
-	// Not wrapped: suggest using AWS_PROFILE (works now that SSO cache is populated)
-	fmt.Fprintln(ui.Output, ui.SubtitleStyle.Render("To use this profile in other tools:"))
-	fmt.Fprintln(ui.Output)
-	fmt.Fprintln(ui.Output, ui.MutedStyle.Render("  export AWS_PROFILE="+p.Name))
-	fmt.Fprintln(ui.Output)
-	fmt.Fprintln(ui.Output, ui.SubtitleStyle.Render("Or set up auto-export with:"))
-	fmt.Fprintln(ui.Output)
-	fmt.Fprintln(ui.Output, ui.MutedStyle.Render("  saws init"))
-	fmt.Fprintln(ui.Output)
+// runDiff handles "saws diff [--start-url url]": for each start URL of the
+// saved profiles it discovers the roles currently granted (logging in if no
+// cached token is valid) and reports new, revoked, and unchanged account/role
+// pairs. It fails if there are any differences, for periodic checks.
+func runDiff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	startURL := fs.String("start-url", "", "Only compare profiles for this SSO start URL")
+	addFileFlags(fs)
+	addEndpointFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := configureFiles(); err != nil {
+		return err
+	}
+	if err := configureEndpoints(); err != nil {
+		return err
+	}
+
+	saved, err := config.LoadProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to load profiles: %w", err)
+	}
+	urls, byURL := profilesByStartURL(saved, *startURL)
+	if len(urls) == 0 {
+		return fmt.Errorf("no saved profiles to compare")
+	}
+
+	ctx := context.Background()
+	differences := 0
+	for _, u := range urls {
+		profiles := byURL[u]
+		p := &profiles[0]
+		if err := resolveSSORegion(p); err != nil {
+			return err
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(p.SSOClientRegion()))
+		if err != nil {
+			return fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		token, err := cachedOrLogin(ctx, cfg, p)
+		if err != nil {
+			return err
+		}
+		result, err := discovery.Discover(ctx, credentials.NewSSOClientFromConfig(cfg), token.AccessToken, u, p.SSOClientRegion(), discovery.Options{
+			Concurrency: userSettings.Concurrency,
+		})
+		var discovered []profile.SSOProfile
+		switch {
+		case err == nil:
+			discovered = result.Profiles
+		case errors.Is(err, discovery.ErrNoAccounts), errors.Is(err, discovery.ErrNoRoles):
+			// Nothing granted any more: every saved profile is revoked.
+		default:
+			return err
+		}
+
+		d := discovery.Compare(discovered, profiles)
+		fmt.Println(ui.SubtitleStyle.Render(u))
+		fmt.Print(formatDiff(d))
+		differences += len(d.New) + len(d.Revoked)
+	}
 
+	if differences > 0 {
+		return fmt.Errorf("%d difference(s) between granted roles and saved profiles", differences)
+	}
 	return nil
 }
 
-// runInit handles the `saws init [shell]` subcommand.
-func runInit(args []string) error {
-	fmt.Print(ui.Banner())
+// profilesByStartURL groups profiles by start URL, returning the URLs in
+// first-seen order. A non-empty only keeps just that start URL.
+func profilesByStartURL(profiles []profile.SSOProfile, only string) ([]string, map[string][]profile.SSOProfile) {
+	byURL := map[string][]profile.SSOProfile{}
+	var urls []string
+	for _, p := range profiles {
+		if only != "" && p.StartURL != only {
+			continue
+		}
+		if _, ok := byURL[p.StartURL]; !ok {
+			urls = append(urls, p.StartURL)
+		}
+		byURL[p.StartURL] = append(byURL[p.StartURL], p)
+	}
+	return urls, byURL
+}
 
-	var sh shell.Shell
-	var err error
+// inventoryAccount is one account in saws inventory output.
+type inventoryAccount struct {
+	AccountID   string   `json:"account_id"`
+	AccountName string   `json:"account_name,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	Roles       []string `json:"roles"`
+}
 
-	if len(args) > 0 {
-		sh, err = shell.ParseShell(args[0])
-	} else {
-		sh, err = shell.DetectShell()
+// inventorySession is the accounts and roles granted through one start URL.
+type inventorySession struct {
+	StartURL string             `json:"start_url"`
+	Region   string             `json:"region"`
+	Accounts []inventoryAccount `json:"accounts"`
+}
+
+// runInventory handles "saws inventory [--json] [--start-url url --region r]":
+// it discovers every account and role granted through the saved start URLs
+// (or just --start-url) and reports them without saving anything.
+func runInventory(args []string) error {
+	fs := flag.NewFlagSet("inventory", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "Print the inventory as JSON on stdout")
+	startURL := fs.String("start-url", "", "Only report this SSO start URL")
+	region := fs.String("region", "", "SSO region for a --start-url with no saved profiles")
+	addFileFlags(fs)
+	addEndpointFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
 	}
-	if err != nil {
+	if err := configureFiles(); err != nil {
+		return err
+	}
+	if err := configureEndpoints(); err != nil {
 		return err
 	}
+	if *asJSON {
+		// Keep stdout for the JSON; a login prompt goes to stderr.
+		ui.Output = os.Stderr
+	}
 
-	binaryPath, err := shell.BinaryPath()
+	saved, err := config.LoadProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to load profiles: %w", err)
+	}
+	urls, byURL := profilesByStartURL(saved, *startURL)
+	if *startURL != "" && len(urls) == 0 {
+		if *region == "" {
+			return fmt.Errorf("--start-url %s has no saved profiles; pass --region too", *startURL)
+		}
+		urls = []string{*startURL}
+		byURL[*startURL] = []profile.SSOProfile{{Name: "inventory", StartURL: *startURL, Region: *region, SSORegion: *region}}
+	}
+	if len(urls) == 0 {
+		return fmt.Errorf("no saved profiles; pass --start-url and --region")
+	}
+
+	ctx := context.Background()
+	var sessions []inventorySession
+	for _, u := range urls {
+		p := &byURL[u][0]
+		if err := resolveSSORegion(p); err != nil {
+			return err
+		}
+		cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(p.SSOClientRegion()))
+		if err != nil {
+			return fmt.Errorf("failed to load AWS config: %w", err)
+		}
+		token, err := cachedOrLogin(ctx, cfg, p)
+		if err != nil {
+			return err
+		}
+		s, err := buildInventory(ctx, credentials.NewSSOClientFromConfig(cfg), token.AccessToken, u, p.SSOClientRegion())
+		if err != nil {
+			return err
+		}
+		sessions = append(sessions, s)
+	}
+
+	if *asJSON {
+		out, err := json.MarshalIndent(sessions, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	fmt.Print(formatInventory(sessions))
+	return nil
+}
+
+// buildInventory discovers the accounts and roles granted through startURL.
+// Accounts with no roles for the user are not listed.
+func buildInventory(ctx context.Context, client credentials.SSOClient, accessToken, startURL, region string) (inventorySession, error) {
+	s := inventorySession{StartURL: startURL, Region: region, Accounts: []inventoryAccount{}}
+	result, err := discovery.Discover(ctx, client, accessToken, startURL, region, discovery.Options{
+		Concurrency: userSettings.Concurrency,
+	})
+	if errors.Is(err, discovery.ErrNoAccounts) || errors.Is(err, discovery.ErrNoRoles) {
+		return s, nil
+	}
 	if err != nil {
+		return s, err
+	}
+
+	index := map[string]int{}
+	for _, p := range result.Profiles {
+		i, ok := index[p.AccountID]
+		if !ok {
+			i = len(s.Accounts)
+			index[p.AccountID] = i
+			s.Accounts = append(s.Accounts, inventoryAccount{
+				AccountID:   p.AccountID,
+				AccountName: p.AccountName,
+				Email:       p.AccountEmail,
+			})
+		}
+		s.Accounts[i].Roles = append(s.Accounts[i].Roles, p.RoleName)
+	}
+	return s, nil
+}
+
+// formatInventory renders sessions as an indented account and role list.
+func formatInventory(sessions []inventorySession) string {
+	var b strings.Builder
+	for _, s := range sessions {
+		fmt.Fprintln(&b, ui.SubtitleStyle.Render(fmt.Sprintf("%s (%s): %d account(s)", s.StartURL, s.Region, len(s.Accounts))))
+		for _, a := range s.Accounts {
+			line := "  " + a.AccountID
+			if a.AccountName != "" {
+				line += "  " + a.AccountName
+			}
+			if a.Email != "" {
+				line += "  " + ui.MutedStyle.Render(a.Email)
+			}
+			fmt.Fprintln(&b, line)
+			for _, r := range a.Roles {
+				fmt.Fprintln(&b, "    - "+r)
+			}
+		}
+	}
+	return b.String()
+}
+
+// formatDiff renders a discovery diff: new pairs with +, revoked profiles
+// with -, and a count of the unchanged ones.
+func formatDiff(d discovery.Diff) string {
+	label := func(p profile.SSOProfile) string {
+		account := p.AccountID
+		if p.AccountName != "" {
+			account = p.AccountName + " (" + p.AccountID + ")"
+		}
+		return account + " / " + p.RoleName
+	}
+
+	var b strings.Builder
+	for _, p := range d.New {
+		fmt.Fprintln(&b, ui.SuccessStyle.Render("  + "+label(p))+ui.MutedStyle.Render(" (new, not saved)"))
+	}
+	for _, p := range d.Revoked {
+		fmt.Fprintln(&b, ui.ErrorStyle.Render("  - "+p.Name)+ui.MutedStyle.Render(" ("+label(p)+", no longer granted)"))
+	}
+	fmt.Fprintln(&b, ui.MutedStyle.Render(fmt.Sprintf("  %d unchanged", len(d.Unchanged))))
+	return b.String()
+}
+
+// runMigrate implements `saws migrate`: it moves the start URL and SSO region
+// of saws profiles into shared [sso-session] blocks. It only reports what
+// would change unless --apply is given.
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "Show what would change without writing (the default)")
+	apply := fs.Bool("apply", false, "Write the migrated config, keeping a .bak copy of the original")
+	addFileFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *dryRun && *apply {
+		return fmt.Errorf("--dry-run cannot be combined with --apply")
+	}
+	if err := configureFiles(); err != nil {
 		return err
 	}
 
-	rcPath, err := shell.RCFile(sh)
+	m, err := config.MigrateToSSOSessions(*apply)
 	if err != nil {
 		return err
 	}
+	if !m.Changed() {
+		fmt.Println(ui.SuccessStyle.Render("No inline saws profiles to migrate"))
+		return nil
+	}
+
+	migrated := 0
+	for _, s := range m.Sessions {
+		if len(s.Profiles) == 0 {
+			continue
+		}
+		migrated += len(s.Profiles)
+		label := "[sso-session " + s.Name + "]"
+		if s.Existing {
+			label += " (existing)"
+		}
+		fmt.Println(ui.KeyStyle.Render(label) + ui.MutedStyle.Render(" "+s.StartURL+" ("+s.Region+")"))
+		for _, p := range s.Profiles {
+			fmt.Println("  " + p)
+		}
+	}
 
-	// Check if already installed
-	if shell.IsInstalled(rcPath) {
-		fmt.Println(ui.WarningStyle.Render("Shell wrapper already installed in " + rcPath))
-		fmt.Println(ui.MutedStyle.Render("Updating to latest version..."))
+	if !*apply {
 		fmt.Println()
+		fmt.Print(ui.Diff(string(m.Before), string(m.After)))
+		fmt.Println()
+		fmt.Println(ui.MutedStyle.Render(fmt.Sprintf("Dry run: %d profile(s) would be migrated. Re-run with --apply to write the config.", migrated)))
+		return nil
+	}
+	fmt.Println()
+	fmt.Println(ui.SuccessStyle.Render(fmt.Sprintf("Migrated %d profile(s); original saved to %s", migrated, m.Backup)))
+	return nil
+}
+
+// rotateResult is the outcome of refreshing one profile in rotate-all.
+type rotateResult struct {
+	Profile string
+	Expires time.Time
+	Err     error
+}
+
+// rotateProfiles fetches and writes credentials for each profile using the
+// cached SSO token for its start URL, read once per start URL. It never logs
+// in; failures are recorded per profile and do not stop the run.
+func rotateProfiles(ctx context.Context, profiles []profile.SSOProfile, clientFor func(region string) (credentials.SSOClient, error)) []rotateResult {
+	tokens := map[string]*config.SSOToken{}
+	results := make([]rotateResult, 0, len(profiles))
+	for i := range profiles {
+		p := &profiles[i]
+		tok, seen := tokens[p.StartURL]
+		if !seen {
+			tok = config.ReadSSOCacheForProfile(p)
+			tokens[p.StartURL] = tok
+		}
+		expires, err := rotateProfile(ctx, p, tok, clientFor)
+		results = append(results, rotateResult{Profile: p.Name, Expires: expires, Err: err})
+	}
+	return results
+}
+
+// rotateProfile refreshes and writes one profile's credentials with tok.
+func rotateProfile(ctx context.Context, p *profile.SSOProfile, tok *config.SSOToken, clientFor func(region string) (credentials.SSOClient, error)) (time.Time, error) {
+	if tok == nil {
+		return time.Time{}, fmt.Errorf("no valid cached SSO token for %s", p.StartURL)
+	}
+	if err := resolveSSORegion(p); err != nil {
+		return time.Time{}, err
+	}
+	client, err := clientFor(p.SSOClientRegion())
+	if err != nil {
+		return time.Time{}, err
 	}
+	creds, err := credentials.GetCredentials(ctx, client, tok.AccessToken, p.AccountID, p.RoleName)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if err := config.WriteCredentials(p.Name, creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, creds.Expiration); err != nil {
+		return time.Time{}, fmt.Errorf("could not write to ~/.aws/credentials: %w", err)
+	}
+	return creds.Expiration, nil
+}
 
-	if err := shell.Install(sh, binaryPath, rcPath); err != nil {
+// runRotateAll handles "saws rotate-all [--filter glob]": it refreshes the
+// credentials of every saved profile from existing SSO sessions, for
+// cache-warming in CI. It fails if any profile could not be refreshed.
+func runRotateAll(args []string) error {
+	fs := flag.NewFlagSet("rotate-all", flag.ContinueOnError)
+	filter := fs.String("filter", "", "Only refresh profiles whose name matches this glob")
+	addFileFlags(fs)
+	addEndpointFlags(fs)
+	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	if err := configureFiles(); err != nil {
+		return err
+	}
+	if err := configureEndpoints(); err != nil {
+		return err
+	}
+	if _, err := path.Match(*filter, ""); err != nil {
+		return fmt.Errorf("--filter: %w", err)
+	}
 
-	fmt.Println(ui.SuccessStyle.Render("Shell wrapper installed in " + rcPath))
-	fmt.Println()
-	fmt.Println(ui.SubtitleStyle.Render("To activate, restart your shell or run:"))
-	fmt.Println()
+	all, err := config.LoadProfiles()
+	if err != nil {
+		return fmt.Errorf("failed to load profiles: %w", err)
+	}
+	var profiles []profile.SSOProfile
+	for _, p := range all {
+		if ok, _ := path.Match(*filter, p.Name); *filter == "" || ok {
+			profiles = append(profiles, p)
+		}
+	}
+	if len(profiles) == 0 {
+		return fmt.Errorf("no saved profiles to refresh")
+	}
 
-	switch sh {
-	case shell.Fish:
-		fmt.Println(ui.MutedStyle.Render("  source " + rcPath))
-	default:
-		fmt.Println(ui.MutedStyle.Render("  source " + rcPath))
+	ctx := context.Background()
+	clients := map[string]credentials.SSOClient{}
+	clientFor := func(region string) (credentials.SSOClient, error) {
+		if c, ok := clients[region]; ok {
+			return c, nil
+		}
+		c, err := credentials.NewSSOClient(ctx, region)
+		if err != nil {
+			return nil, err
+		}
+		clients[region] = c
+		return c, nil
 	}
-	fmt.Println()
-	fmt.Println(ui.MutedStyle.Render("After that, just type " +
-		ui.ValueStyle.Render("saws") +
-		ui.MutedStyle.Render(" and credentials will be auto-exported to your shell.")))
-	fmt.Println()
 
+	failed := 0
+	for _, r := range rotateProfiles(ctx, profiles, clientFor) {
+		if r.Err != nil {
+			failed++
+			fmt.Println(ui.ErrorStyle.Render("  ✗ "+r.Profile) + ui.MutedStyle.Render(": "+r.Err.Error()))
+			continue
+		}
+		fmt.Println(ui.SuccessStyle.Render("  ✓ "+r.Profile) + ui.MutedStyle.Render(" valid until "+r.Expires.Local().Format("15:04")))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d profile(s) could not be refreshed", failed, len(profiles))
+	}
 	return nil
 }
@@ -0,0 +1,1698 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+	ssotypes "github.com/aws/aws-sdk-go-v2/service/sso/types"
+	"github.com/aws/smithy-go"
+
+	"github.com/lvstb/saws/internal/auth"
+	"github.com/lvstb/saws/internal/config"
+	"github.com/lvstb/saws/internal/credentials"
+	"github.com/lvstb/saws/internal/discovery"
+	"github.com/lvstb/saws/internal/profile"
+	"github.com/lvstb/saws/internal/shell"
+	"github.com/lvstb/saws/internal/ui"
+	"github.com/lvstb/saws/internal/usage"
+)
+
+// withFlag sets a boolean flag for the duration of a test.
+func withFlag(t *testing.T, f *bool, v bool) {
+	t.Helper()
+	orig := *f
+	*f = v
+	t.Cleanup(func() { *f = orig })
+}
+
+// captureOutput redirects ui.Output to a buffer for the duration of a test.
+func captureOutput(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	orig := ui.Output
+	var buf bytes.Buffer
+	ui.Output = &buf
+	t.Cleanup(func() { ui.Output = orig })
+	return &buf
+}
+
+func TestShowDeviceAuthPrintURL(t *testing.T) {
+	ui.InitStyles()
+	withFlag(t, flagPrintURL, true)
+	display := captureOutput(t)
+
+	info := auth.DeviceAuthInfo{
+		VerificationURI: "https://device.sso.us-east-1.amazonaws.com/?user_code=ABCD-EFGH",
+		UserCode:        "ABCD-EFGH",
+	}
+
+	var stdout bytes.Buffer
+	showDeviceAuth(&stdout)(info)
+
+	if got, want := stdout.String(), info.VerificationURI+"\n"; got != want {
+		t.Errorf("stdout = %q, want exactly %q", got, want)
+	}
+	if !bytes.Contains(display.Bytes(), []byte("ABCD-EFGH")) {
+		t.Error("display output missing user code")
+	}
+}
+
+func TestShowDeviceAuthDefault(t *testing.T) {
+	ui.InitStyles()
+	withFlag(t, flagPrintURL, false)
+	captureOutput(t)
+
+	var stdout bytes.Buffer
+	showDeviceAuth(&stdout)(auth.DeviceAuthInfo{VerificationURI: "https://example.com", UserCode: "CODE"})
+
+	if stdout.Len() != 0 {
+		t.Errorf("stdout = %q, want empty without --print-url", stdout.String())
+	}
+}
+
+// withStringFlag sets a string flag for the duration of a test.
+func withStringFlag(t *testing.T, f *string, v string) {
+	t.Helper()
+	orig := *f
+	*f = v
+	t.Cleanup(func() { *f = orig })
+}
+
+func TestPrintBanner(t *testing.T) {
+	ui.InitStyles()
+	t.Setenv("SAWS_NO_BANNER", "")
+
+	var buf bytes.Buffer
+	printBanner(&buf, true)
+	if !strings.Contains(buf.String(), "AWS SSO Credential Helper") {
+		t.Errorf("banner = %q, want it printed by default", buf.String())
+	}
+
+	withFlag(t, flagNoBanner, true)
+	for _, compact := range []bool{false, true} {
+		buf.Reset()
+		printBanner(&buf, compact)
+		if buf.Len() != 0 {
+			t.Errorf("printBanner(compact=%v) with --no-banner wrote %q, want nothing", compact, buf.String())
+		}
+	}
+
+	withFlag(t, flagNoBanner, false)
+	t.Setenv("SAWS_NO_BANNER", "1")
+	buf.Reset()
+	printBanner(&buf, false)
+	if buf.Len() != 0 {
+		t.Errorf("printBanner() with SAWS_NO_BANNER=1 wrote %q, want nothing", buf.String())
+	}
+}
+
+func TestRequestedProfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		flagProfile string
+		flagSelect  string
+		env         string
+		wantName    string
+		wantFromEnv bool
+	}{
+		{"nothing set", "", "", "", "", false},
+		{"env only", "", "", "dev-admin", "dev-admin", true},
+		{"flag wins over env", "prod-admin", "", "dev-admin", "prod-admin", false},
+		{"select ignores env", "", "prod", "dev-admin", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withStringFlag(t, flagProfile, tt.flagProfile)
+			withStringFlag(t, flagSelect, tt.flagSelect)
+			t.Setenv("AWS_PROFILE", tt.env)
+			t.Setenv(credentials.ExportedProfileVar, "")
+
+			name, fromEnv := requestedProfile()
+			if name != tt.wantName || fromEnv != tt.wantFromEnv {
+				t.Errorf("requestedProfile() = (%q, %v), want (%q, %v)", name, fromEnv, tt.wantName, tt.wantFromEnv)
+			}
+		})
+	}
+}
+
+func TestNoAltScreen(t *testing.T) {
+	withFlag(t, flagNoAltScreen, false)
+	t.Setenv("SAWS_NO_ALT_SCREEN", "")
+	if noAltScreen() {
+		t.Error("noAltScreen() = true with nothing set")
+	}
+
+	t.Setenv("SAWS_NO_ALT_SCREEN", "1")
+	if !noAltScreen() {
+		t.Error("noAltScreen() = false with SAWS_NO_ALT_SCREEN=1")
+	}
+
+	t.Setenv("SAWS_NO_ALT_SCREEN", "")
+	withFlag(t, flagNoAltScreen, true)
+	if !noAltScreen() {
+		t.Error("noAltScreen() = false with --no-alt-screen")
+	}
+}
+
+func TestRedactCacheJSON(t *testing.T) {
+	longToken := strings.Repeat("a", 20) + "SECRETMIDDLE" + strings.Repeat("z", 20)
+	data := []byte(`{"startUrl":"https://test.awsapps.com/start","region":"us-east-1",` +
+		`"accessToken":"` + longToken + `","refreshToken":"short-refresh","expiresAt":"2030-01-01T00:00:00Z"}`)
+
+	out, err := redactCacheJSON(data)
+	if err != nil {
+		t.Fatalf("redactCacheJSON() error = %v", err)
+	}
+	got := string(out)
+
+	if strings.Contains(got, longToken) || strings.Contains(got, "SECRETMIDDLE") {
+		t.Errorf("output contains the full access token:\n%s", got)
+	}
+	if !strings.Contains(got, strings.Repeat("a", 20)+"...") {
+		t.Errorf("output missing truncated access token:\n%s", got)
+	}
+	if strings.Contains(got, "short-refresh") {
+		t.Errorf("output contains the short refresh token:\n%s", got)
+	}
+	if !strings.Contains(got, "https://test.awsapps.com/start") {
+		t.Errorf("output missing non-secret fields:\n%s", got)
+	}
+}
+
+func TestRedactCacheJSONInvalid(t *testing.T) {
+	if _, err := redactCacheJSON([]byte("not json")); err == nil {
+		t.Error("expected error for malformed cache file")
+	}
+}
+
+// fakeClock is a watchClock whose timers fire only when the test says so.
+type fakeClock struct {
+	now    time.Time
+	waits  []time.Duration
+	timers chan chan time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	c.timers <- ch
+	return ch
+}
+
+func TestWatchCredentialsRefreshesBeforeExpiry(t *testing.T) {
+	start := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start, timers: make(chan chan time.Time, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	refreshes := 0
+	refresh := func(context.Context) (time.Time, error) {
+		refreshes++
+		return clock.now.Add(time.Hour), nil
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- watchCredentials(ctx, clock, refresh) }()
+
+	// First fetch happens immediately; advance to the refresh point.
+	timer := <-clock.timers
+	clock.now = clock.now.Add(time.Hour - watchRefreshMargin)
+	timer <- clock.now
+
+	// Second fetch has scheduled its own timer; stop watching.
+	<-clock.timers
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("watchCredentials() error = %v", err)
+	}
+	if refreshes != 2 {
+		t.Errorf("refreshes = %d, want 2", refreshes)
+	}
+	if want := time.Hour - watchRefreshMargin; clock.waits[0] != want {
+		t.Errorf("first wait = %v, want %v", clock.waits[0], want)
+	}
+}
+
+func TestWatchCredentialsStopsOnError(t *testing.T) {
+	clock := &fakeClock{now: time.Now(), timers: make(chan chan time.Time, 1)}
+	wantErr := errors.New("boom")
+
+	err := watchCredentials(context.Background(), clock, func(context.Context) (time.Time, error) {
+		return time.Time{}, wantErr
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("watchCredentials() error = %v, want %v", err, wantErr)
+	}
+}
+
+func TestWatchCredentialsMinimumWait(t *testing.T) {
+	clock := &fakeClock{now: time.Now(), timers: make(chan chan time.Time, 1)}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		done <- watchCredentials(ctx, clock, func(context.Context) (time.Time, error) {
+			return clock.now.Add(time.Minute), nil // already inside the margin
+		})
+	}()
+
+	<-clock.timers
+	cancel()
+	<-done
+
+	if clock.waits[0] != watchMinWait {
+		t.Errorf("wait = %v, want minimum %v", clock.waits[0], watchMinWait)
+	}
+}
+
+func TestNeedsSwitchConfirm(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		active  string
+		target  string
+		expires time.Time
+		want    bool
+	}{
+		{"other profile still valid", "dev", "prod", now.Add(time.Hour), true},
+		{"other profile expired", "dev", "prod", now.Add(-time.Minute), false},
+		{"same profile", "prod", "prod", now.Add(time.Hour), false},
+		{"no recorded expiry", "dev", "prod", time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsSwitchConfirm(tt.active, tt.target, tt.expires, now); got != tt.want {
+				t.Errorf("needsSwitchConfirm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialsSection(t *testing.T) {
+	p := &profile.SSOProfile{Name: "dev-admin"}
+	if got := credentialsSection(p); got != "dev-admin" {
+		t.Errorf("credentialsSection() = %q, want dev-admin", got)
+	}
+
+	// With --creds-profile, refreshing the active section is not a switch.
+	withStringFlag(t, flagCredsProfile, "ci")
+	if got := credentialsSection(p); got != "ci" {
+		t.Errorf("credentialsSection() with --creds-profile = %q, want ci", got)
+	}
+	now := time.Now()
+	if needsSwitchConfirm("ci", credentialsSection(p), now.Add(time.Hour), now) {
+		t.Error("needsSwitchConfirm() = true when --creds-profile is the active section, want false")
+	}
+}
+
+func TestActiveCredentialsSection(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "")
+	if got := activeCredentialsSection(); got != "default" {
+		t.Errorf("activeCredentialsSection() = %q, want default", got)
+	}
+	t.Setenv("AWS_PROFILE", "dev-admin")
+	if got := activeCredentialsSection(); got != "dev-admin" {
+		t.Errorf("activeCredentialsSection() = %q, want dev-admin", got)
+	}
+}
+
+// fakeSSOClient returns credentials for any role except those listed in fail.
+type fakeSSOClient struct {
+	fail     map[string]bool     // account IDs whose GetRoleCredentials fails
+	roles    map[string][]string // role names listed per account ID
+	accounts []ssotypes.AccountInfo
+	rejected string // access token ListAccountRoles rejects as unauthorized
+}
+
+func (f *fakeSSOClient) GetRoleCredentials(ctx context.Context, params *sso.GetRoleCredentialsInput, optFns ...func(*sso.Options)) (*sso.GetRoleCredentialsOutput, error) {
+	if f.fail[aws.ToString(params.AccountId)] {
+		return nil, &smithy.GenericAPIError{Code: "ForbiddenException", Message: "no access"}
+	}
+	return &sso.GetRoleCredentialsOutput{
+		RoleCredentials: &ssotypes.RoleCredentials{
+			AccessKeyId:     aws.String("AKIA" + aws.ToString(params.AccountId)),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+			Expiration:      time.Now().Add(time.Hour).UnixMilli(),
+		},
+	}, nil
+}
+
+func (f *fakeSSOClient) ListAccounts(ctx context.Context, params *sso.ListAccountsInput, optFns ...func(*sso.Options)) (*sso.ListAccountsOutput, error) {
+	return &sso.ListAccountsOutput{AccountList: f.accounts}, nil
+}
+
+func (f *fakeSSOClient) ListAccountRoles(ctx context.Context, params *sso.ListAccountRolesInput, optFns ...func(*sso.Options)) (*sso.ListAccountRolesOutput, error) {
+	if f.rejected != "" && aws.ToString(params.AccessToken) == f.rejected {
+		return nil, &ssotypes.UnauthorizedException{Message: aws.String("Session token not found or invalid")}
+	}
+	out := &sso.ListAccountRolesOutput{}
+	for _, r := range f.roles[aws.ToString(params.AccountId)] {
+		out.RoleList = append(out.RoleList, ssotypes.RoleInfo{AccountId: params.AccountId, RoleName: aws.String(r)})
+	}
+	return out, nil
+}
+
+// setupTestAWSFiles points HOME and the AWS config/credentials files at a
+// temporary directory.
+func setupTestAWSFiles(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	return dir
+}
+
+func TestNeedsReauth(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		left      time.Duration
+		threshold time.Duration
+		want      bool
+	}{
+		{"no threshold", 10 * time.Minute, 0, false},
+		{"enough left", 3 * time.Hour, 2 * time.Hour, false},
+		{"under threshold", 90 * time.Minute, 2 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsReauth(now.Add(tt.left), tt.threshold, now); got != tt.want {
+				t.Errorf("needsReauth(%v left, %v) = %v, want %v", tt.left, tt.threshold, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReauthThresholdWarning(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	if w := reauthThresholdWarning(0, now.Add(time.Hour), now); w != "" {
+		t.Errorf("reauthThresholdWarning() without a threshold = %q, want none", w)
+	}
+	if w := reauthThresholdWarning(2*time.Hour, now.Add(8*time.Hour), now); w != "" {
+		t.Errorf("reauthThresholdWarning() for an 8h token = %q, want none", w)
+	}
+	w := reauthThresholdWarning(12*time.Hour, now.Add(8*time.Hour), now)
+	if !strings.Contains(w, "--reauth-threshold 12h0m0s") || !strings.Contains(w, "(8h0m0s)") {
+		t.Errorf("reauthThresholdWarning() = %q, want it to name the threshold and token lifetime", w)
+	}
+}
+
+func TestCachedTokenHonorsReauthThreshold(t *testing.T) {
+	setupTestAWSFiles(t)
+	captureOutput(t)
+
+	const startURL = "https://corp.awsapps.com/start"
+	if err := config.WriteSSOCache(startURL, "us-east-1", "short-lived", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("WriteSSOCache() error = %v", err)
+	}
+	p := &profile.SSOProfile{Name: "dev", StartURL: startURL, Region: "us-east-1"}
+
+	if cachedToken(p) == nil {
+		t.Fatal("cachedToken() = nil, want the cached token without a threshold")
+	}
+
+	orig := *flagReauth
+	*flagReauth = 2 * time.Hour
+	t.Cleanup(func() { *flagReauth = orig })
+	out := captureOutput(t)
+	if tok := cachedToken(p); tok != nil {
+		t.Errorf("cachedToken() = %+v, want nil so saws logs in again", tok)
+	}
+	if got := out.String(); !strings.Contains(got, "has 59m left, under --reauth-threshold") {
+		t.Errorf("cachedToken() output = %q, want the time left", got)
+	}
+}
+
+func TestResolveSSORegionFromCache(t *testing.T) {
+	dir := setupTestAWSFiles(t)
+
+	const startURL = "https://corp.awsapps.com/start"
+	// An expired token still records the region the start URL lives in.
+	if err := config.WriteSSOCache(startURL, "eu-central-1", "old-token", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("WriteSSOCache() error = %v", err)
+	}
+
+	// Each profile refers to an sso-session block that doesn't exist, so
+	// none has an sso_region.
+	cfg := `# managed by saws
+[profile dev]
+sso_session    = gone
+sso_start_url  = ` + startURL + `
+sso_account_id = 111111111111
+sso_role_name  = Admin
+region         = us-west-2
+
+# managed by saws
+[profile other]
+sso_session    = gone
+sso_start_url  = https://other.awsapps.com/start
+sso_account_id = 222222222222
+sso_role_name  = Admin
+region         = ap-southeast-2
+
+# managed by saws
+[profile lost]
+sso_session    = gone
+sso_start_url  = https://other.awsapps.com/start
+sso_account_id = 333333333333
+sso_role_name  = Admin
+`
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		t.Fatalf("LoadProfiles() error = %v", err)
+	}
+	byName := map[string]*profile.SSOProfile{}
+	for i := range profiles {
+		byName[profiles[i].Name] = &profiles[i]
+	}
+	for _, name := range []string{"dev", "other", "lost"} {
+		if p := byName[name]; p == nil || !p.SSORegionMissing {
+			t.Fatalf("LoadProfiles() profile %s = %+v, want it loaded with SSORegionMissing", name, p)
+		}
+	}
+
+	// The cached region wins over the operational region.
+	dev := byName["dev"]
+	if err := resolveSSORegion(dev); err != nil {
+		t.Fatalf("resolveSSORegion(dev) error = %v", err)
+	}
+	if dev.SSOClientRegion() != "eu-central-1" {
+		t.Errorf("dev SSOClientRegion() = %q, want eu-central-1 from the cache", dev.SSOClientRegion())
+	}
+
+	// Without a cached login, the operational region is the best guess.
+	other := byName["other"]
+	if err := resolveSSORegion(other); err != nil {
+		t.Fatalf("resolveSSORegion(other) error = %v", err)
+	}
+	if other.SSOClientRegion() != "ap-southeast-2" {
+		t.Errorf("other SSOClientRegion() = %q, want ap-southeast-2", other.SSOClientRegion())
+	}
+
+	err = resolveSSORegion(byName["lost"])
+	if err == nil || !strings.Contains(err.Error(), "sso_region") {
+		t.Errorf("resolveSSORegion(lost) error = %v, want a hint to set sso_region", err)
+	}
+}
+
+func TestRotateProfilesReportsPerProfile(t *testing.T) {
+	setupTestAWSFiles(t)
+
+	const orgURL = "https://org.awsapps.com/start"
+	if err := config.WriteSSOCache(orgURL, "us-east-1", "org-token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("WriteSSOCache() error = %v", err)
+	}
+
+	profiles := []profile.SSOProfile{
+		{Name: "dev", StartURL: orgURL, Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "denied", StartURL: orgURL, Region: "us-east-1", AccountID: "222222222222", RoleName: "Admin"},
+		{Name: "prod", StartURL: orgURL, Region: "us-east-1", AccountID: "333333333333", RoleName: "Admin"},
+		{Name: "other-org", StartURL: "https://other.awsapps.com/start", Region: "us-east-1", AccountID: "444444444444", RoleName: "Admin"},
+	}
+
+	client := &fakeSSOClient{fail: map[string]bool{"222222222222": true}}
+	results := rotateProfiles(context.Background(), profiles, func(string) (credentials.SSOClient, error) {
+		return client, nil
+	})
+
+	if len(results) != len(profiles) {
+		t.Fatalf("got %d results, want %d", len(results), len(profiles))
+	}
+	wantErr := map[string]bool{"dev": false, "denied": true, "prod": false, "other-org": true}
+	for _, r := range results {
+		if (r.Err != nil) != wantErr[r.Profile] {
+			t.Errorf("%s: err = %v, want error %v", r.Profile, r.Err, wantErr[r.Profile])
+		}
+	}
+
+	// Successful profiles were written even though one in between failed.
+	for _, name := range []string{"dev", "prod"} {
+		if _, ok := config.CredentialsExpiration(name); !ok {
+			t.Errorf("credentials for %s were not written", name)
+		}
+	}
+	if _, ok := config.CredentialsExpiration("denied"); ok {
+		t.Error("credentials written for a failed profile")
+	}
+}
+
+// captureStdout redirects os.Stdout while fn runs and returns what it wrote.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error = %v", err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	fn()
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading captured stdout: %v", err)
+	}
+	return string(out)
+}
+
+func TestExportCredentialsQuiet(t *testing.T) {
+	setupTestAWSFiles(t)
+	ui.InitStyles()
+	withFlag(t, flagExport, true)
+	origQuiet := ui.Quiet
+	ui.Quiet = true
+	t.Cleanup(func() { ui.Quiet = origQuiet })
+	display := captureOutput(t)
+
+	p := &profile.SSOProfile{Name: "dev"}
+	creds := &credentials.AWSCredentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      time.Now().Add(time.Hour),
+	}
+
+	var err error
+	stdout := captureStdout(t, func() {
+		err = exportCredentials(p, creds, credentials.ExportShell)
+	})
+	if err != nil {
+		t.Fatalf("exportCredentials() error = %v", err)
+	}
+
+	if display.Len() != 0 {
+		t.Errorf("display output under --quiet = %q, want empty", display.String())
+	}
+	if !strings.Contains(stdout, "export AWS_ACCESS_KEY_ID=AKIAEXAMPLE") {
+		t.Errorf("stdout = %q, want export commands", stdout)
+	}
+}
+
+func TestExportCredentialsToFD(t *testing.T) {
+	setupTestAWSFiles(t)
+	ui.InitStyles()
+	withFlag(t, flagExport, true)
+	captureOutput(t)
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe() error = %v", err)
+	}
+	defer r.Close()
+	f, err := openExportFD(int(w.Fd()))
+	if err != nil {
+		t.Fatalf("openExportFD() error = %v", err)
+	}
+	exportOut = f
+	t.Cleanup(func() { exportOut = nil })
+
+	p := &profile.SSOProfile{Name: "dev"}
+	creds := &credentials.AWSCredentials{
+		AccessKeyID:     "AKIAEXAMPLE",
+		SecretAccessKey: "secret",
+		SessionToken:    "token",
+		Expiration:      time.Now().Add(time.Hour),
+	}
+	stdout := captureStdout(t, func() {
+		err = exportCredentials(p, creds, credentials.ExportShell)
+	})
+	if err != nil {
+		t.Fatalf("exportCredentials() error = %v", err)
+	}
+	f.Close()
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading pipe: %v", err)
+	}
+	for _, want := range []string{"export AWS_ACCESS_KEY_ID=AKIAEXAMPLE", "export AWS_SESSION_TOKEN=token", "export AWS_PROFILE=dev"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("fd output = %q, want %q", got, want)
+		}
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want it left free", stdout)
+	}
+}
+
+func TestOpenExportFDRejectsInvalid(t *testing.T) {
+	for _, fd := range []int{0, 1, 2, 987654} {
+		if f, err := openExportFD(fd); err == nil {
+			f.Close()
+			t.Errorf("openExportFD(%d) succeeded, want an error", fd)
+		}
+	}
+}
+
+func TestRunSummaryCachedToken(t *testing.T) {
+	setupTestAWSFiles(t)
+	ui.InitStyles()
+	captureOutput(t)
+
+	p := &profile.SSOProfile{
+		Name:        "dev",
+		StartURL:    "https://example.awsapps.com/start",
+		Region:      "eu-west-1",
+		SSORegion:   "us-east-1",
+		AccountID:   "123456789012",
+		AccountName: "dev-account",
+		RoleName:    "ReadOnly",
+	}
+	if err := config.WriteSSOCache(p.StartURL, p.SSOClientRegion(), "cached-token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("WriteSSOCache() error = %v", err)
+	}
+
+	expiration := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("x-amz-sso_bearer_token"); got != "cached-token" {
+			t.Errorf("bearer token = %q, want cached-token", got)
+		}
+		fmt.Fprintf(w, `{"roleCredentials":{"accessKeyId":"AKIAEXAMPLE","secretAccessKey":"secret","sessionToken":"token","expiration":%d}}`, expiration.UnixMilli())
+	}))
+	defer srv.Close()
+
+	cfg := aws.Config{
+		Region:       p.SSOClientRegion(),
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	}
+	creds, loggedIn, err := obtainCredentials(context.Background(), cfg, p, nil)
+	if err != nil {
+		t.Fatalf("obtainCredentials() error = %v", err)
+	}
+
+	out, err := json.Marshal(newRunSummary(p, creds, loggedIn))
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	want := map[string]any{
+		"profile":             "dev",
+		"account_id":          "123456789012",
+		"account_name":        "dev-account",
+		"role":                "ReadOnly",
+		"region":              "eu-west-1",
+		"credentials_section": "dev",
+		"expiration":          "2030-01-02T03:04:05Z",
+		"auth_required":       false,
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %v, want %v", k, got[k], v)
+		}
+	}
+	if strings.Contains(string(out), "secret") || strings.Contains(string(out), "AKIAEXAMPLE") {
+		t.Errorf("summary leaks credentials: %s", out)
+	}
+}
+
+func TestSSOSessionConnection(t *testing.T) {
+	configPath := filepath.Join(setupTestAWSFiles(t), "config")
+	cfg := `[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-central-1
+sso_registration_scopes = sso:account:access
+
+[sso-session broken]
+sso_region = us-east-1
+`
+	if err := os.WriteFile(configPath, []byte(cfg), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := ssoSessionConnection("corp")
+	if err != nil {
+		t.Fatalf("ssoSessionConnection(corp) error = %v", err)
+	}
+	want := ui.SSOConnection{StartURL: "https://corp.awsapps.com/start", Region: "eu-central-1"}
+	if *conn != want {
+		t.Errorf("ssoSessionConnection(corp) = %+v, want %+v", *conn, want)
+	}
+
+	if _, err := ssoSessionConnection("missing"); err == nil {
+		t.Error("ssoSessionConnection(missing) error = nil, want not found")
+	}
+	if _, err := ssoSessionConnection("broken"); err == nil || !strings.Contains(err.Error(), "sso_start_url") {
+		t.Errorf("ssoSessionConnection(broken) error = %v, want missing sso_start_url", err)
+	}
+}
+
+func TestForceNetwork(t *testing.T) {
+	var gotNetwork, gotAddr string
+	tr := &http.Transport{DialContext: func(_ context.Context, network, addr string) (net.Conn, error) {
+		gotNetwork, gotAddr = network, addr
+		return nil, errors.New("not dialing in tests")
+	}}
+
+	forceNetwork(tr, "tcp4")
+	_, _ = tr.DialContext(context.Background(), "tcp", "oidc.us-east-1.amazonaws.com:443")
+	if gotNetwork != "tcp4" || gotAddr != "oidc.us-east-1.amazonaws.com:443" {
+		t.Errorf("dialed %s %s, want tcp4 to the same address", gotNetwork, gotAddr)
+	}
+}
+
+func TestConfigureEndpointsIPv4(t *testing.T) {
+	t.Setenv("SAWS_FORCE_IPV4", "")
+	t.Cleanup(func() { credentials.HTTPClient, auth.HTTPClient = nil, nil })
+
+	if err := configureEndpoints(); err != nil {
+		t.Fatalf("configureEndpoints() error = %v", err)
+	}
+	if credentials.HTTPClient != nil || auth.HTTPClient != nil {
+		t.Error("HTTP clients replaced without --ipv4, want the SDK default")
+	}
+
+	withFlag(t, flagIPv4, true)
+	if err := configureEndpoints(); err != nil {
+		t.Fatalf("configureEndpoints() error = %v", err)
+	}
+	if credentials.HTTPClient == nil || auth.HTTPClient == nil {
+		t.Error("HTTP clients not replaced with --ipv4")
+	}
+}
+
+func TestConfigureEndpointsProxy(t *testing.T) {
+	t.Setenv("SAWS_FORCE_IPV4", "")
+	t.Cleanup(func() { credentials.HTTPClient, auth.HTTPClient = nil, nil })
+	withStringFlag(t, flagProxy, "http://proxy.corp:3128")
+	// The proxy applies even where the environment says otherwise.
+	t.Setenv("HTTPS_PROXY", "http://other.corp:8080")
+
+	if err := configureEndpoints(); err != nil {
+		t.Fatalf("configureEndpoints() error = %v", err)
+	}
+	client, ok := auth.HTTPClient.(*awshttp.BuildableClient)
+	if !ok || credentials.HTTPClient != auth.HTTPClient {
+		t.Fatalf("HTTPClient = %T, want one shared buildable client", auth.HTTPClient)
+	}
+	req, _ := http.NewRequest(http.MethodPost, "https://oidc.us-east-1.amazonaws.com/token", nil)
+	got, err := client.GetTransport().Proxy(req)
+	if err != nil || got == nil || got.String() != "http://proxy.corp:3128" {
+		t.Errorf("Proxy() = %v, %v; want http://proxy.corp:3128", got, err)
+	}
+
+	withStringFlag(t, flagProxy, "proxy.corp:3128")
+	if err := configureEndpoints(); err == nil {
+		t.Error("configureEndpoints() with a scheme-less proxy = nil, want an error")
+	}
+}
+
+func TestSubcommandEndpoints(t *testing.T) {
+	setupTestAWSFiles(t)
+	ui.InitStyles()
+	t.Setenv("SAWS_FORCE_IPV4", "")
+	t.Setenv("SAWS_SSO_ENDPOINT", "")
+	t.Setenv("SAWS_OIDC_ENDPOINT", "")
+	withFlag(t, flagFIPS, false)
+	withFlag(t, flagIPv4, false)
+	withStringFlag(t, flagProxy, "")
+	t.Cleanup(func() {
+		credentials.Endpoint, credentials.UseFIPS, credentials.HTTPClient = "", false, nil
+		auth.Endpoint, auth.UseFIPS, auth.HTTPClient = "", false, nil
+	})
+
+	// With no saved profiles these fail before any SSO call, but only after
+	// the endpoints are set up.
+	if err := runDiff([]string{"--fips"}); err == nil {
+		t.Fatal("runDiff(--fips) with no saved profiles should fail")
+	}
+	if !credentials.UseFIPS || !auth.UseFIPS {
+		t.Error("runDiff(--fips) did not select the FIPS endpoints")
+	}
+
+	t.Setenv("SAWS_SSO_ENDPOINT", "http://sso.internal")
+	for name, run := range map[string]func([]string) error{
+		"watch": runWatch, "diff": runDiff, "inventory": runInventory, "rotate-all": runRotateAll,
+	} {
+		if err := run(nil); err == nil || !strings.Contains(err.Error(), "SAWS_SSO_ENDPOINT") {
+			t.Errorf("%s with an invalid SAWS_SSO_ENDPOINT error = %v, want it rejected", name, err)
+		}
+	}
+}
+
+func TestConfigureFiles(t *testing.T) {
+	setupTestAWSFiles(t)
+	t.Cleanup(func() { config.ConfigFile, config.CredentialsFile = "", "" })
+	dir := t.TempDir()
+	withStringFlag(t, flagConfigFile, filepath.Join(dir, "config"))
+	withStringFlag(t, flagCredsFile, filepath.Join(dir, "credentials"))
+
+	if err := configureFiles(); err != nil {
+		t.Fatalf("configureFiles() error = %v", err)
+	}
+	if got, _ := config.Path(); got != filepath.Join(dir, "config") {
+		t.Errorf("config.Path() = %q, want the --config-file path", got)
+	}
+	if got, _ := config.CredentialsPath(); got != filepath.Join(dir, "credentials") {
+		t.Errorf("config.CredentialsPath() = %q, want the --credentials-file path", got)
+	}
+
+	withStringFlag(t, flagCredsFile, filepath.Join(dir, "missing", "credentials"))
+	if err := configureFiles(); err == nil || !strings.Contains(err.Error(), "--credentials-file") {
+		t.Errorf("configureFiles() with a missing directory error = %v, want a --credentials-file error", err)
+	}
+}
+
+func TestSubcommandFileFlags(t *testing.T) {
+	setupTestAWSFiles(t)
+	t.Cleanup(func() { config.ConfigFile, config.CredentialsFile = "", "" })
+	withStringFlag(t, flagConfigFile, "")
+	withStringFlag(t, flagCredsFile, "")
+	ui.InitStyles()
+
+	dir := t.TempDir()
+	cfgPath := filepath.Join(dir, "config")
+	broken := "# managed by saws\n[profile dev]\nsso_start_url = https://corp.awsapps.com/start\nsso_region = us-east-1\nsso_account_id = 111111111111\n"
+	if err := os.WriteFile(cfgPath, []byte(broken), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The default config is empty, so only the --config-file file has the
+	// profile missing sso_role_name.
+	if err := runValidate(nil); err != nil {
+		t.Fatalf("runValidate() on the default config error = %v", err)
+	}
+	var err error
+	captureStdout(t, func() { err = runValidate([]string{"--config-file", cfgPath}) })
+	if err == nil {
+		t.Error("runValidate(--config-file) should report the broken profile in that file")
+	}
+
+	if err := runNote([]string{"--config-file", filepath.Join(dir, "missing", "config"), "dev", "hi"}); err == nil || !strings.Contains(err.Error(), "--config-file") {
+		t.Errorf("runNote() with a missing --config-file directory error = %v, want a --config-file error", err)
+	}
+}
+
+func TestValidateEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		wantErr  bool
+	}{
+		{"", false},
+		{"https://portal.sso-fips.us-gov-west-1.amazonaws.com", false},
+		{"https://vpce-123.oidc.eu-west-1.vpce.amazonaws.com/", false},
+		{"http://portal.sso.us-east-1.amazonaws.com", true},
+		{"portal.sso.us-east-1.amazonaws.com", true},
+		{"https://portal.sso.us-east-1.amazonaws.com?x=1", true},
+	}
+	for _, tt := range tests {
+		if err := validateEndpoint(tt.endpoint); (err != nil) != tt.wantErr {
+			t.Errorf("validateEndpoint(%q) error = %v, wantErr %v", tt.endpoint, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSettingsRegionFallback(t *testing.T) {
+	orig := userSettings
+	t.Cleanup(func() { userSettings = orig })
+	userSettings.Region = "eu-west-1"
+
+	cached := func(startURL string) string {
+		if startURL == "https://cached.awsapps.com/start" {
+			return "us-east-2"
+		}
+		return ""
+	}
+	lookup := settingsRegionFallback(cached, "ap-southeast-2")
+	if got := lookup("https://cached.awsapps.com/start"); got != "us-east-2" {
+		t.Errorf("cached start URL = %q, want cached region us-east-2", got)
+	}
+	if got := lookup("https://new.awsapps.com/start"); got != "eu-west-1" {
+		t.Errorf("new start URL = %q, want settings region eu-west-1", got)
+	}
+
+	// Without a configured default, the last-used region seeds the form.
+	userSettings.Region = ""
+	if got := lookup("https://new.awsapps.com/start"); got != "ap-southeast-2" {
+		t.Errorf("new start URL = %q, want last-used region ap-southeast-2", got)
+	}
+	if got := settingsRegionFallback(cached, "")("https://new.awsapps.com/start"); got != "" {
+		t.Errorf("no default or last-used region = %q, want none", got)
+	}
+}
+
+func TestLastUsedRegionSeedsForm(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	orig := userSettings
+	t.Cleanup(func() { userSettings = orig })
+	userSettings.Region = ""
+
+	if err := usage.RecordRegion("ca-central-1"); err != nil {
+		t.Fatalf("RecordRegion() error = %v", err)
+	}
+	lookup := settingsRegionFallback(func(string) string { return "" }, lastUsedRegion())
+	if got := lookup("https://new.awsapps.com/start"); got != "ca-central-1" {
+		t.Errorf("form default = %q, want last-used region ca-central-1", got)
+	}
+}
+
+func TestSelectLiveRole(t *testing.T) {
+	client := &fakeSSOClient{roles: map[string][]string{
+		"111111111111": {"Admin", "ReadOnly", "NewlyGranted"},
+		"222222222222": {"OnlyRole"},
+	}}
+
+	p := &profile.SSOProfile{Name: "acct", AccountID: "111111111111", RoleName: "Admin"}
+	var offered []string
+	var current string
+	err := selectLiveRole(context.Background(), client, "token", p, nil, func(roles []string, cur string) (string, error) {
+		offered, current = roles, cur
+		return "NewlyGranted", nil
+	})
+	if err != nil {
+		t.Fatalf("selectLiveRole() error = %v", err)
+	}
+	if strings.Join(offered, ",") != "Admin,ReadOnly,NewlyGranted" || current != "Admin" {
+		t.Errorf("offered %v with current %q, want live roles with saved role Admin", offered, current)
+	}
+	if p.RoleName != "NewlyGranted" {
+		t.Errorf("RoleName = %q, want NewlyGranted", p.RoleName)
+	}
+
+	single := &profile.SSOProfile{Name: "single", AccountID: "222222222222", RoleName: "Old"}
+	err = selectLiveRole(context.Background(), client, "token", single, nil, func([]string, string) (string, error) {
+		t.Error("choose should not be called for a single role")
+		return "", nil
+	})
+	if err != nil || single.RoleName != "OnlyRole" {
+		t.Errorf("single role: RoleName = %q, err = %v; want OnlyRole", single.RoleName, err)
+	}
+
+	none := &profile.SSOProfile{Name: "none", AccountID: "333333333333"}
+	if err := selectLiveRole(context.Background(), client, "token", none, nil, nil); err == nil {
+		t.Error("selectLiveRole() with no roles should fail")
+	}
+}
+
+func TestSelectLiveRoleRelogin(t *testing.T) {
+	client := &fakeSSOClient{
+		roles:    map[string][]string{"111111111111": {"Admin"}},
+		rejected: "revoked",
+	}
+	p := &profile.SSOProfile{Name: "acct", AccountID: "111111111111"}
+
+	logins := 0
+	relogin := func(context.Context) (string, error) {
+		logins++
+		return "fresh", nil
+	}
+	if err := selectLiveRole(context.Background(), client, "revoked", p, relogin, nil); err != nil {
+		t.Fatalf("selectLiveRole() with a revoked token error = %v", err)
+	}
+	if logins != 1 || p.RoleName != "Admin" {
+		t.Errorf("logins = %d, RoleName = %q; want one login and the live role Admin", logins, p.RoleName)
+	}
+
+	// Without relogin, the rejection is returned as before.
+	if err := selectLiveRole(context.Background(), client, "revoked", p, nil, nil); !credentials.IsAuthError(err) {
+		t.Errorf("selectLiveRole() without relogin error = %v, want the auth error", err)
+	}
+}
+
+func TestFormatSaveSummary(t *testing.T) {
+	ui.InitStyles()
+	got := formatSaveSummary([]profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", AccountName: "Production", RoleName: "AdministratorAccess"},
+		{Name: "sandbox-readonly", AccountID: "222222222222", RoleName: "ReadOnly"},
+	})
+
+	if !strings.Contains(got, "About to save 2 profile(s)") {
+		t.Errorf("summary missing count:\n%s", got)
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("summary has %d lines, want header + 2:\n%s", len(lines), got)
+	}
+	for i, want := range [][]string{
+		{"prod-admin", "Production (111111111111)", "AdministratorAccess"},
+		{"sandbox-readonly", "222222222222", "ReadOnly"},
+	} {
+		for _, field := range want {
+			if !strings.Contains(lines[i+1], field) {
+				t.Errorf("line %d = %q, missing %q", i+1, lines[i+1], field)
+			}
+		}
+	}
+	// Columns are aligned: the account column starts at the same offset.
+	if strings.Index(lines[1], "Production") != strings.Index(lines[2], "222222222222") {
+		t.Errorf("account column not aligned:\n%s", got)
+	}
+}
+
+func TestFormatChanges(t *testing.T) {
+	ui.InitStyles()
+	if got := formatChanges(nil); got != "" {
+		t.Errorf("formatChanges(nil) = %q, want empty", got)
+	}
+
+	got := formatChanges([]fileChange{
+		{Path: "/home/me/.aws/sso/cache/abc.json", What: "cached SSO token for https://corp.awsapps.com/start"},
+		{Path: "/home/me/.aws/credentials", What: "updated credentials for profile prod-admin"},
+	})
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("summary has %d lines, want header + 2:\n%s", len(lines), got)
+	}
+	if !strings.Contains(lines[0], "Files changed") {
+		t.Errorf("header = %q", lines[0])
+	}
+	for i, want := range [][]string{
+		{"/home/me/.aws/sso/cache/abc.json", "cached SSO token for https://corp.awsapps.com/start"},
+		{"/home/me/.aws/credentials", "updated credentials for profile prod-admin"},
+	} {
+		for _, field := range want {
+			if !strings.Contains(lines[i+1], field) {
+				t.Errorf("line %d = %q, missing %q", i+1, lines[i+1], field)
+			}
+		}
+	}
+	if strings.Index(lines[1], "cached") != strings.Index(lines[2], "updated") {
+		t.Errorf("description column not aligned:\n%s", got)
+	}
+}
+
+func TestSelectedProfilesSkipsInvalid(t *testing.T) {
+	ui.InitStyles()
+	valid := profile.SSOProfile{
+		StartURL:  "https://corp.awsapps.com/start",
+		Region:    "eu-west-1",
+		AccountID: "111111111111",
+		RoleName:  "AdministratorAccess",
+	}
+	broken := valid
+	broken.AccountID = ""
+	selected := []ui.DiscoveredProfile{
+		{Profile: valid, Name: "prod-admin"},
+		{Profile: valid, Name: ""},
+		{Profile: broken, Name: "no-account"},
+	}
+
+	var report bytes.Buffer
+	got := selectedProfiles(selected, &report)
+	if len(got) != 1 || got[0].Name != "prod-admin" {
+		t.Fatalf("selectedProfiles() = %+v, want only prod-admin", got)
+	}
+	out := report.String()
+	if !strings.Contains(out, `Skipping invalid profile ""`) || !strings.Contains(out, `Skipping invalid profile "no-account"`) {
+		t.Errorf("report does not name both skipped profiles:\n%s", out)
+	}
+	if strings.Contains(out, "prod-admin") {
+		t.Errorf("report mentions the valid profile:\n%s", out)
+	}
+}
+
+func TestFormatWhich(t *testing.T) {
+	setupTestAWSFiles(t)
+	ui.InitStyles()
+
+	p := &profile.SSOProfile{
+		Name:        "prod-admin",
+		StartURL:    "https://corp.awsapps.com/start",
+		Region:      "eu-west-1",
+		SSORegion:   "us-east-1",
+		AccountID:   "111111111111",
+		AccountName: "Production",
+		RoleName:    "Admin",
+	}
+	now := time.Now().Truncate(time.Second)
+
+	noToken := formatWhich(newWhichInfo(p, config.ReadSSOCacheForProfile(p)), now)
+	if !strings.Contains(noToken, "none (a login will be needed)") {
+		t.Errorf("without a cached token:\n%s", noToken)
+	}
+
+	if err := config.WriteSSOCache(p.StartURL, "us-east-1", "token", now.Add(2*time.Hour+30*time.Minute)); err != nil {
+		t.Fatalf("WriteSSOCache() error = %v", err)
+	}
+	info := newWhichInfo(p, config.ReadSSOCacheForProfile(p))
+	got := formatWhich(info, now)
+	for _, want := range []string{
+		"prod-admin", "https://corp.awsapps.com/start", "us-east-1", "eu-west-1",
+		"111111111111", "Production", "Admin", "valid, 2h30m left",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatWhich() missing %q:\n%s", want, got)
+		}
+	}
+	if !info.TokenValid || info.TokenExpires == nil {
+		t.Errorf("info = %+v, want a valid token with expiry", info)
+	}
+}
+
+func TestCompleteProfiles(t *testing.T) {
+	names := []string{"prod-admin", "dev-admin", "Prod-ReadOnly", "staging"}
+
+	tests := []struct {
+		partial string
+		want    string
+	}{
+		{"", "prod-admin,dev-admin,Prod-ReadOnly,staging"},
+		{"prod", "prod-admin,Prod-ReadOnly"},
+		{"admin", "prod-admin,dev-admin"},
+		{"d", "dev-admin,prod-admin,Prod-ReadOnly"},
+		{"nope", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(completeProfiles(names, tt.partial), ","); got != tt.want {
+			t.Errorf("completeProfiles(%q) = %s, want %s", tt.partial, got, tt.want)
+		}
+	}
+}
+
+func TestRunCompleteProfile(t *testing.T) {
+	setupTestAWSFiles(t)
+	if err := config.SaveProfiles([]profile.SSOProfile{
+		{Name: "prod-admin", StartURL: "https://org.awsapps.com/start", Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin"},
+		{Name: "dev-admin", StartURL: "https://org.awsapps.com/start", Region: "us-east-1", AccountID: "222222222222", RoleName: "Admin"},
+	}); err != nil {
+		t.Fatalf("SaveProfiles() error = %v", err)
+	}
+
+	out := captureStdout(t, func() {
+		if err := runComplete([]string{"profile", "dev"}); err != nil {
+			t.Errorf("runComplete() error = %v", err)
+		}
+	})
+	if out != "dev-admin\n" {
+		t.Errorf("runComplete() output = %q, want only dev-admin", out)
+	}
+}
+
+func TestCredentialsOnlyModeLeavesConfigAlone(t *testing.T) {
+	dir := setupTestAWSFiles(t)
+	ui.InitStyles()
+	captureOutput(t)
+	withStringFlag(t, flagStartURL, "https://example.awsapps.com/start")
+	withStringFlag(t, flagSSORegion, "eu-west-1")
+	withStringFlag(t, flagAccountID, "123456789012")
+	withStringFlag(t, flagRole, "ReadOnly")
+
+	p, err := credentialsOnlyProfile()
+	if err != nil {
+		t.Fatalf("credentialsOnlyProfile() error = %v", err)
+	}
+	if p.Name != "123456789012-readonly" || p.SSOClientRegion() != "eu-west-1" {
+		t.Errorf("credentialsOnlyProfile() = %+v, want a profile named after the account and role in eu-west-1", p)
+	}
+
+	if err := config.WriteSSOCache(p.StartURL, p.SSOClientRegion(), "cached-token", time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("WriteSSOCache() error = %v", err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"roleCredentials":{"accessKeyId":"ASIAEXAMPLE","secretAccessKey":"secret","sessionToken":"token","expiration":%d}}`, time.Now().Add(time.Hour).UnixMilli())
+	}))
+	defer srv.Close()
+
+	cfg := aws.Config{Region: p.SSOClientRegion(), BaseEndpoint: aws.String(srv.URL), Credentials: aws.AnonymousCredentials{}}
+	creds, _, err := obtainCredentials(context.Background(), cfg, p, nil)
+	if err != nil {
+		t.Fatalf("obtainCredentials() error = %v", err)
+	}
+	if err := exportCredentials(p, creds, credentials.ExportShell); err != nil {
+		t.Fatalf("exportCredentials() error = %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "config")); !os.IsNotExist(err) {
+		t.Errorf("config file exists after a credentials-only login (stat error %v)", err)
+	}
+	if _, ok := config.CredentialsExpiration(p.Name); !ok {
+		t.Error("credentials were not written")
+	}
+}
+
+func TestCredentialsOnlyProfileValidation(t *testing.T) {
+	withStringFlag(t, flagRole, "ReadOnly")
+	if _, err := credentialsOnlyProfile(); err == nil {
+		t.Error("credentialsOnlyProfile() accepted --role without --account-id or --start-url")
+	}
+	withStringFlag(t, flagRole, "")
+
+	// --account-id alone selects a saved profile instead.
+	withStringFlag(t, flagAccountID, "123456789012")
+	if p, err := credentialsOnlyProfile(); p != nil || err != nil {
+		t.Errorf("credentialsOnlyProfile() with only --account-id = %+v, %v; want nil, nil", p, err)
+	}
+
+	withStringFlag(t, flagStartURL, "https://example.awsapps.com/start")
+	withStringFlag(t, flagRegion, "eu-west-1")
+	if _, err := credentialsOnlyProfile(); err == nil || !strings.Contains(err.Error(), "--sso-region") {
+		t.Errorf("credentialsOnlyProfile() error = %v, want a missing --sso-region error", err)
+	}
+
+	withStringFlag(t, flagSSORegion, "eu-west-1")
+	if _, err := credentialsOnlyProfile(); err == nil || !strings.Contains(err.Error(), "--role") {
+		t.Errorf("credentialsOnlyProfile() error = %v, want a missing --role error", err)
+	}
+}
+
+func TestCredentialsOnlyProfileRegions(t *testing.T) {
+	withStringFlag(t, flagStartURL, "https://example.awsapps.com/start")
+	withStringFlag(t, flagAccountID, "123456789012")
+	withStringFlag(t, flagRole, "ReadOnly")
+	withStringFlag(t, flagSSORegion, "eu-west-1")
+
+	p, err := credentialsOnlyProfile()
+	if err != nil {
+		t.Fatalf("credentialsOnlyProfile() error = %v", err)
+	}
+	if p.SSOClientRegion() != "eu-west-1" || p.Region != "eu-west-1" {
+		t.Errorf("credentialsOnlyProfile() = %+v, want eu-west-1 for SSO and credentials", p)
+	}
+
+	// --region picks the credentials' region, not where SSO lives.
+	withStringFlag(t, flagRegion, "us-west-2")
+	p, err = credentialsOnlyProfile()
+	if err != nil {
+		t.Fatalf("credentialsOnlyProfile() error = %v", err)
+	}
+	if p.SSOClientRegion() != "eu-west-1" || p.Region != "us-west-2" {
+		t.Errorf("credentialsOnlyProfile() with --region = %+v, want SSO in eu-west-1 and region us-west-2", p)
+	}
+}
+
+func TestProfileForAccount(t *testing.T) {
+	profiles := []profile.SSOProfile{
+		{Name: "prod-admin", AccountID: "111111111111", RoleName: "AdministratorAccess"},
+		{Name: "prod-readonly", AccountID: "111111111111", RoleName: "ReadOnly"},
+		{Name: "sandbox-dev", AccountID: "222222222222", RoleName: "Developer"},
+	}
+
+	p, err := profileForAccount(profiles, "222222222222", "")
+	if err != nil || p == nil || p.Name != "sandbox-dev" {
+		t.Errorf("profileForAccount(single-role account) = %+v, %v; want sandbox-dev", p, err)
+	}
+
+	if _, err := profileForAccount(profiles, "111111111111", ""); err == nil || !strings.Contains(err.Error(), "--role") {
+		t.Errorf("profileForAccount(multi-role account) error = %v, want a hint to use --role", err)
+	}
+	p, err = profileForAccount(profiles, "111111111111", "ReadOnly")
+	if err != nil || p == nil || p.Name != "prod-readonly" {
+		t.Errorf("profileForAccount(multi-role account, ReadOnly) = %+v, %v; want prod-readonly", p, err)
+	}
+
+	if _, err := profileForAccount(profiles, "333333333333", ""); err == nil {
+		t.Error("profileForAccount(unknown account) = nil error, want not found")
+	}
+}
+
+func TestDiscoveryHint(t *testing.T) {
+	ui.InitStyles()
+	conn := &ui.SSOConnection{StartURL: "https://example.awsapps.com/start", Region: "eu-west-1"}
+
+	hint := discoveryHint(fmt.Errorf("discovery: %w", discovery.ErrNoAccounts), conn)
+	for _, want := range []string{"no accounts", "sso:ListAccounts", "saws --start-url https://example.awsapps.com/start --sso-region eu-west-1 --account-id"} {
+		if !strings.Contains(hint, want) {
+			t.Errorf("zero-accounts hint missing %q:\n%s", want, hint)
+		}
+	}
+
+	for _, code := range []string{"ForbiddenException", "AccessDeniedException"} {
+		err := fmt.Errorf("operation error SSO: ListAccounts, %w", &smithy.GenericAPIError{Code: code, Message: "access denied"})
+		hint = discoveryHint(err, conn)
+		if !strings.Contains(hint, "denied") || !strings.Contains(hint, "sso:ListAccounts") {
+			t.Errorf("%s hint = %q, want it to mention the missing permission", code, hint)
+		}
+	}
+
+	if hint := discoveryHint(errors.New("AccessDenied: not an SDK error"), conn); hint != "" {
+		t.Errorf("discoveryHint() for an untyped error = %q, want none", hint)
+	}
+
+	if hint := discoveryHint(errors.New("connection reset"), conn); hint != "" {
+		t.Errorf("discoveryHint() for a network error = %q, want none", hint)
+	}
+}
+
+func TestCredentialStatus(t *testing.T) {
+	now := time.Date(2030, 1, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		expires    time.Time
+		ok         bool
+		since      time.Duration
+		wantStatus string
+		wantStale  bool
+	}{
+		{"never written", time.Time{}, false, 0, "no credentials", true},
+		{"still valid", now.Add(2*time.Hour + 30*time.Minute), true, 0, "2h30m left", false},
+		{"just expired", now.Add(-10 * time.Minute), true, 0, "expired 10m ago", true},
+		{"expired within --since", now.Add(-5 * time.Hour), true, 24 * time.Hour, "expired 5h ago", false},
+		{"expired beyond --since", now.Add(-72 * time.Hour), true, 24 * time.Hour, "expired 3d ago", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, stale := credentialStatus(tt.expires, tt.ok, now, tt.since)
+			if status != tt.wantStatus || stale != tt.wantStale {
+				t.Errorf("credentialStatus() = %q, %v; want %q, %v", status, stale, tt.wantStatus, tt.wantStale)
+			}
+		})
+	}
+}
+
+func TestWriteShellList(t *testing.T) {
+	dir := setupTestAWSFiles(t)
+	zshrc := filepath.Join(dir, ".zshrc")
+	if _, err := shell.Install(shell.Zsh, "/usr/local/bin/saws", zshrc); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeShellList(&buf); err != nil {
+		t.Fatalf("writeShellList() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, name := range shell.SupportedShells() {
+		rcPath, _ := shell.RCFile(shell.Shell(name))
+		if !strings.HasPrefix(rcPath, dir) {
+			t.Errorf("rc file for %s = %s, want it under HOME", name, rcPath)
+		}
+		var line string
+		for _, l := range strings.Split(out, "\n") {
+			if strings.HasPrefix(l, name+" ") {
+				line = l
+			}
+		}
+		if !strings.Contains(line, rcPath) {
+			t.Errorf("list line for %s = %q, want its rc file %s", name, line, rcPath)
+		}
+		wantInstalled := "no"
+		if name == "zsh" {
+			wantInstalled = "yes"
+		}
+		if !strings.HasSuffix(strings.TrimSpace(line), wantInstalled) {
+			t.Errorf("list line for %s = %q, want installed %s", name, line, wantInstalled)
+		}
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	ui.InitStyles()
+	d := discovery.Diff{
+		New:       []profile.SSOProfile{{Name: "staging-dev", AccountID: "222222222222", AccountName: "Staging", RoleName: "Developer"}},
+		Revoked:   []profile.SSOProfile{{Name: "old-dev", AccountID: "333333333333", RoleName: "Developer"}},
+		Unchanged: []profile.SSOProfile{{Name: "prod-admin"}, {Name: "prod-readonly"}},
+	}
+	got := formatDiff(d)
+	for _, want := range []string{"+ Staging (222222222222) / Developer", "- old-dev", "333333333333 / Developer, no longer granted", "2 unchanged"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatDiff() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestBuildInventory(t *testing.T) {
+	client := &fakeSSOClient{
+		accounts: []ssotypes.AccountInfo{
+			{AccountId: aws.String("111111111111"), AccountName: aws.String("Production"), EmailAddress: aws.String("prod@example.com")},
+			{AccountId: aws.String("222222222222"), AccountName: aws.String("Staging"), EmailAddress: aws.String("staging@example.com")},
+		},
+		roles: map[string][]string{
+			"111111111111": {"Admin", "ReadOnly"},
+			"222222222222": {"Developer"},
+		},
+	}
+
+	s, err := buildInventory(context.Background(), client, "token", "https://org.awsapps.com/start", "eu-west-1")
+	if err != nil {
+		t.Fatalf("buildInventory() error = %v", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var got inventorySession
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	want := inventorySession{
+		StartURL: "https://org.awsapps.com/start",
+		Region:   "eu-west-1",
+		Accounts: []inventoryAccount{
+			{AccountID: "111111111111", AccountName: "Production", Email: "prod@example.com", Roles: []string{"Admin", "ReadOnly"}},
+			{AccountID: "222222222222", AccountName: "Staging", Email: "staging@example.com", Roles: []string{"Developer"}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("inventory = %s, want %+v", data, want)
+	}
+	if !strings.Contains(string(data), `"email":"prod@example.com"`) {
+		t.Errorf("inventory JSON = %s, want account emails", data)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	// A slow operation that only returns once its context is done, like an
+	// SSO call or login polling.
+	slow := func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Second):
+			return nil
+		}
+	}
+
+	start := time.Now()
+	err := withTimeout(20*time.Millisecond, slow)
+	if !errors.Is(err, errTimedOut) {
+		t.Fatalf("withTimeout() error = %v, want errTimedOut", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("withTimeout() took %s, want it aborted near the deadline", elapsed)
+	}
+	if got := exitCode(err); got != exitTimedOut {
+		t.Errorf("exitCode() = %d, want %d", got, exitTimedOut)
+	}
+
+	// Errors before the deadline pass through unchanged.
+	boom := errors.New("boom")
+	if err := withTimeout(time.Minute, func(context.Context) error { return boom }); err != boom {
+		t.Errorf("withTimeout() error = %v, want %v", err, boom)
+	}
+	if got := exitCode(boom); got != 1 {
+		t.Errorf("exitCode() = %d, want 1", got)
+	}
+
+	// Zero means no deadline.
+	if err := withTimeout(0, func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); ok {
+			return errors.New("unexpected deadline")
+		}
+		return nil
+	}); err != nil {
+		t.Errorf("withTimeout(0) error = %v", err)
+	}
+}
+
+func TestCheckOnlyKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		only    credentials.KeySet
+		format  credentials.ExportFormat
+		export  bool
+		envFile bool
+		wantErr string
+	}{
+		{"shell credentials", credentials.KeySet{"AWS_ACCESS_KEY_ID": true}, credentials.ExportShell, true, false, ""},
+		{"region alone in shell", credentials.KeySet{"AWS_REGION": true}, credentials.ExportShell, true, false, "nothing left"},
+		{"profile alone in dotenv", credentials.KeySet{"AWS_PROFILE": true}, credentials.ExportDotenv, true, false, "nothing left"},
+		{"expiration in shell", credentials.KeySet{"AWS_ACCESS_KEY_ID": true, "AWS_CREDENTIAL_EXPIRATION": true}, credentials.ExportShell, true, false, "AWS_CREDENTIAL_EXPIRATION not printed"},
+		{"region in env file", credentials.KeySet{"AWS_REGION": true}, credentials.ExportShell, false, true, ""},
+		{"profile in env file", credentials.KeySet{"AWS_PROFILE": true}, credentials.ExportShell, false, true, "nothing left"},
+		{"split across outputs", credentials.KeySet{"AWS_PROFILE": true, "AWS_REGION": true}, credentials.ExportShell, true, true, ""},
+		{"region with export and env file", credentials.KeySet{"AWS_REGION": true}, credentials.ExportShell, true, true, "nothing left for the shell export format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOnlyKeys(tt.only, tt.format, tt.export, tt.envFile)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("checkOnlyKeys() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("checkOnlyKeys() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMigrateDryRunFlag(t *testing.T) {
+	dir := setupTestAWSFiles(t)
+	ui.InitStyles()
+
+	cfgPath := filepath.Join(dir, "config")
+	inline := "# managed by saws\n[profile dev]\nsso_start_url = https://corp.awsapps.com/start\nsso_region = us-east-1\nsso_account_id = 111111111111\nsso_role_name = Admin\n"
+	if err := os.WriteFile(cfgPath, []byte(inline), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runMigrate([]string{"--dry-run", "--apply"}); err == nil {
+		t.Error("runMigrate(--dry-run --apply) should be rejected")
+	}
+
+	var err error
+	out := captureStdout(t, func() { err = runMigrate([]string{"--dry-run"}) })
+	if err != nil {
+		t.Fatalf("runMigrate(--dry-run) error = %v", err)
+	}
+	if !strings.Contains(out, "Dry run") {
+		t.Errorf("runMigrate(--dry-run) output = %q, want a dry run summary", out)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != inline {
+		t.Errorf("runMigrate(--dry-run) changed the config:\n%s", data)
+	}
+}
+
+// TestRequestedProfileWrappedShell evals what the shell wrapper evals after a
+// login and checks that the next bare saws still shows the selector, while a
+// profile the user exports afterwards is honoured.
+func TestRequestedProfileWrappedShell(t *testing.T) {
+	withStringFlag(t, flagProfile, "")
+	withStringFlag(t, flagSelect, "")
+	t.Setenv(shell.WrapperEnvVar, "1")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv(credentials.ExportedProfileVar, "")
+
+	creds := &credentials.AWSCredentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "token"}
+	for _, line := range strings.Split(credentials.FormatExportCommands(creds, "dev-admin", nil, false), "\n") {
+		key, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			t.Fatalf("unexpected export line %q", line)
+		}
+		t.Setenv(key, value)
+	}
+
+	if name, fromEnv := requestedProfile(); name != "" || fromEnv {
+		t.Errorf("requestedProfile() after a wrapped login = (%q, %v), want the selector", name, fromEnv)
+	}
+
+	t.Setenv("AWS_PROFILE", "prod-admin")
+	if name, fromEnv := requestedProfile(); name != "prod-admin" || !fromEnv {
+		t.Errorf("requestedProfile() after the user exports AWS_PROFILE = (%q, %v), want (prod-admin, true)", name, fromEnv)
+	}
+}
+
+func TestSettingsExportFormatWrapped(t *testing.T) {
+	orig := userSettings
+	t.Cleanup(func() { userSettings = orig })
+	userSettings.ExportFormat = "json"
+
+	t.Setenv(shell.WrapperEnvVar, "")
+	if got := settingsExportFormat(); got != "json" {
+		t.Errorf("settingsExportFormat() = %q, want the configured json", got)
+	}
+	t.Setenv(shell.WrapperEnvVar, "1")
+	if got := settingsExportFormat(); got != "" {
+		t.Errorf("settingsExportFormat() under the wrapper = %q, want the shell default", got)
+	}
+}
+
+func TestExportCredentialsStandaloneSurvivesCheck(t *testing.T) {
+	setupTestAWSFiles(t)
+	ui.InitStyles()
+	captureOutput(t)
+
+	if err := config.SaveProfile(profile.SSOProfile{Name: "dev", StartURL: "https://corp.awsapps.com/start", Region: "us-east-1", AccountID: "111111111111", RoleName: "Admin"}); err != nil {
+		t.Fatalf("SaveProfile() error = %v", err)
+	}
+	creds := &credentials.AWSCredentials{AccessKeyID: "ASIA", SecretAccessKey: "secret", SessionToken: "token", Expiration: time.Now().Add(time.Hour)}
+	export := func(p *profile.SSOProfile) {
+		t.Helper()
+		var err error
+		captureStdout(t, func() { err = exportCredentials(p, creds, credentials.ExportShell) })
+		if err != nil {
+			t.Fatalf("exportCredentials(%s) error = %v", p.Name, err)
+		}
+	}
+
+	// saws --profile dev --creds-profile work
+	withStringFlag(t, flagCredsProfile, "work")
+	export(&profile.SSOProfile{Name: "dev"})
+	withStringFlag(t, flagCredsProfile, "")
+
+	// saws --start-url ... --account-id 222222222222 --role ReadOnly
+	withStringFlag(t, flagStartURL, "https://corp.awsapps.com/start")
+	export(&profile.SSOProfile{Name: "222222222222-ReadOnly"})
+
+	report, err := config.CheckDrift(time.Now())
+	if err != nil {
+		t.Fatalf("CheckDrift() error = %v", err)
+	}
+	if len(report.Orphaned) != 0 {
+		t.Errorf("CheckDrift() orphans = %v, want the --creds-profile and credentials-only sections kept", report.Orphaned)
+	}
+}
+
+func TestRunCompletionDynamic(t *testing.T) {
+	var err error
+	out := captureStdout(t, func() { err = runCompletion([]string{"--dynamic", "bash"}) })
+	if err != nil {
+		t.Fatalf("runCompletion(--dynamic bash) error = %v", err)
+	}
+	if !strings.Contains(out, "__complete profile") {
+		t.Errorf("runCompletion(--dynamic bash) = %q, want a script calling saws __complete profile", out)
+	}
+
+	if err := runCompletion([]string{"--dynamic=false", "bash"}); err == nil {
+		t.Error("runCompletion(--dynamic=false) should be rejected")
+	}
+}
+
+func TestRunInitNoBanner(t *testing.T) {
+	dir := setupTestAWSFiles(t)
+	ui.InitStyles()
+	withFlag(t, flagNoBanner, false)
+	t.Setenv("SAWS_NO_BANNER", "")
+
+	rc := filepath.Join(dir, ".bashrc")
+	var err error
+	out := captureStdout(t, func() { err = runInit([]string{"--no-banner", "--rc", rc, "bash"}) })
+	if err != nil {
+		t.Fatalf("runInit(--no-banner bash) error = %v", err)
+	}
+	if strings.Contains(out, "AWS SSO Credential Helper") {
+		t.Errorf("runInit(--no-banner) printed the banner:\n%s", out)
+	}
+	if !shell.IsInstalled(rc) {
+		t.Error("runInit(--no-banner bash) did not install the wrapper")
+	}
+}